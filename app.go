@@ -1,9 +1,16 @@
 package harukap
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/allentom/haruka"
 	"github.com/allentom/harukap/config"
@@ -12,26 +19,59 @@ import (
 	youlog2 "github.com/project-xpolaris/youplustoolkit/youlog"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
 )
 
+// DefaultShutdownTimeout 优雅关闭时等待 HTTP / RPC 请求排空的默认时长
+const DefaultShutdownTimeout = 10 * time.Second
+
 type HarukaAppEngine struct {
-	ConfigProvider       *config.Provider
-	Plugins              []HarukaPlugin
-	LoggerPlugin         *youlog.Plugin
-	HttpService          *haruka.Engine
-	RPCService           *rpc.HarukaRPCService
+	ConfigProvider *config.Provider
+	Plugins        []HarukaPlugin
+	LoggerPlugin   *youlog.Plugin
+	HttpService    *haruka.Engine
+	RPCService     *rpc.HarukaRPCService
+	// AuthModule 应用的认证模块，auth.NewAuthModule 会设置该字段，
+	// RPCService 未设置 Authenticator 时用于 gRPC 认证
+	AuthModule           rpc.TokenAuthenticator
 	OnPluginInitComplete func()
+	// ShutdownTimeout 为空时读取 shutdown.timeout（毫秒），仍为空则使用 DefaultShutdownTimeout
+	ShutdownTimeout time.Duration
+
+	lifecycleLock      sync.Mutex
+	stopSignal         chan struct{}
+	stopOnce           sync.Once
+	shutdownOnce       sync.Once
+	shutdownHooks      []func() error
+	healthListeners    []func(report *HealthReport)
+	healthLock         sync.Mutex
+	healthCalls        map[PluginWithHealthCheck]*healthCall
+	ready              atomic.Bool
+	initializedPlugins []HarukaPlugin
+	configSchemas      []*config.Schema
+	dryRun             bool
+	httpServer         *http.Server
+	rpcServer          *grpc.Server
+	rpcHealth          *health.Server
 }
 
 func NewHarukaAppEngine() *HarukaAppEngine {
 	return &HarukaAppEngine{
-		Plugins: []HarukaPlugin{},
+		Plugins:    []HarukaPlugin{},
+		stopSignal: make(chan struct{}),
 	}
 }
 func (e *HarukaAppEngine) UsePlugin(plugins ...HarukaPlugin) {
 	e.Plugins = append(e.Plugins, plugins...)
 }
 
+// AddShutdownHook 注册关闭回调，在服务停止后、插件停止前按注册的逆序执行
+func (e *HarukaAppEngine) AddShutdownHook(hook func() error) {
+	e.lifecycleLock.Lock()
+	defer e.lifecycleLock.Unlock()
+	e.shutdownHooks = append(e.shutdownHooks, hook)
+}
+
 // GetPluginsConfig 汇总所有实现了 PluginWithConfig 的插件配置
 func (e *HarukaAppEngine) GetPluginsConfig() map[string]map[string]interface{} {
 	result := make(map[string]map[string]interface{})
@@ -46,19 +86,46 @@ func (e *HarukaAppEngine) GetPluginsConfig() map[string]map[string]interface{} {
 	}
 	return result
 }
-func (e *HarukaAppEngine) RunRPC() {
-	lis, err := net.Listen("tcp", e.ConfigProvider.Manager.GetString("rpc.addr"))
-	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+
+// newHttpServer 按 haruka.Engine.RunAndListen 的方式构建 http.Server，以便支持优雅关闭
+func (e *HarukaAppEngine) newHttpServer(addr string) *http.Server {
+	e.HttpService.Router.Middleware = e.HttpService.Middlewares
+	var router http.Handler
+	router = e.HttpService.Router.HandlerRouter
+	if e.HttpService.Cros != nil {
+		router = e.HttpService.Cros.Handler(e.HttpService.Router.HandlerRouter)
 	}
-	rpcServer := grpc.NewServer()
-	e.RPCService.OnRegister(rpcServer)
-	log.Printf("server listening at %v", lis.Addr())
-	if err := rpcServer.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	return &http.Server{
+		Addr:    addr,
+		Handler: router,
 	}
 }
-func (e *HarukaAppEngine) Run() {
+
+func (e *HarukaAppEngine) getStopSignal() chan struct{} {
+	e.lifecycleLock.Lock()
+	defer e.lifecycleLock.Unlock()
+	if e.stopSignal == nil {
+		e.stopSignal = make(chan struct{})
+	}
+	return e.stopSignal
+}
+
+func (e *HarukaAppEngine) getShutdownTimeout() time.Duration {
+	if e.ShutdownTimeout > 0 {
+		return e.ShutdownTimeout
+	}
+	if e.ConfigProvider != nil && e.ConfigProvider.Config() != nil {
+		timeout := e.ConfigProvider.Config().GetInt("shutdown.timeout")
+		if timeout > 0 {
+			return time.Duration(timeout) * time.Millisecond
+		}
+	}
+	return DefaultShutdownTimeout
+}
+
+// Run 初始化插件并启动服务，阻塞直到收到退出信号、Stop 或服务异常退出，返回前完成 Shutdown。
+// 服务异常退出（如端口被占用）时返回该错误，正常停止时返回 nil
+func (e *HarukaAppEngine) Run() error {
 	if e.LoggerPlugin == nil {
 		e.LoggerPlugin = &youlog.Plugin{}
 		err := e.LoggerPlugin.OnInit(e.ConfigProvider)
@@ -71,22 +138,180 @@ func (e *HarukaAppEngine) Run() {
 		"Application": e.LoggerPlugin.Logger.Application,
 		"Instance":    e.LoggerPlugin.Logger.Instance,
 	}).Info("init logger success")
+	bootLogger.Info("validate config")
+	if err := e.ValidateConfig(); err != nil {
+		bootLogger.Fatal(err.Error())
+	}
 	bootLogger.Info("init plugins")
-	for _, plugin := range e.Plugins {
+	plugins, err := SortPlugins(e.Plugins)
+	if err != nil {
+		bootLogger.Fatal(err.Error())
+	}
+	for _, plugin := range plugins {
 		err := plugin.OnInit(e)
 		if err != nil {
+			e.stopPlugins()
 			bootLogger.Fatal(err.Error())
 		}
+		e.initializedPlugins = append(e.initializedPlugins, plugin)
 	}
 
 	if e.OnPluginInitComplete != nil {
 		e.OnPluginInitComplete()
 	}
+	e.watchConfig()
 
+	serviceErr := make(chan error, 2)
 	if e.RPCService != nil {
 		bootLogger.Info("start rpc service")
-		go e.RunRPC()
+		e.syncRPCHealth()
+		// 在启动 goroutine 之前创建服务，避免此时开始的 Shutdown 漏掉 gRPC 服务
+		rpcLogger := e.LoggerPlugin.Logger.NewScope("rpc")
+		rpcServer, lis, err := e.listenRPC(rpcLogger)
+		if err != nil {
+			serviceErr <- fmt.Errorf("rpc service: %w", err)
+		} else {
+			go func() {
+				if err := e.serveRPC(rpcLogger, rpcServer, lis); err != nil {
+					serviceErr <- fmt.Errorf("rpc service: %w", err)
+				}
+			}()
+		}
 	}
 	bootLogger.Info("start http service")
-	e.HttpService.RunAndListen(e.ConfigProvider.Manager.GetString("addr"))
+	e.mountHealthHandlers()
+	addr := e.ConfigProvider.Config().GetString("addr")
+	e.lifecycleLock.Lock()
+	e.httpServer = e.newHttpServer(addr)
+	e.lifecycleLock.Unlock()
+	go func() {
+		e.HttpService.Logger.Info(fmt.Sprintf("application run in %s", addr))
+		err := e.httpServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serviceErr <- fmt.Errorf("http service: %w", err)
+		}
+	}()
+	e.ready.Store(true)
+	go e.runHealthMonitor()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	var runErr error
+	select {
+	case sig := <-quit:
+		bootLogger.Info(fmt.Sprintf("receive signal %s, shutting down", sig))
+	case runErr = <-serviceErr:
+		bootLogger.Error(fmt.Sprintf("service stopped: %s", runErr.Error()))
+	case <-e.getStopSignal():
+		bootLogger.Info("receive stop request, shutting down")
+	}
+	e.Shutdown()
+	return runErr
+}
+
+// watchConfig 为实现了 PluginWithConfigReload 的插件订阅配置变化，并监听配置文件。
+// 设置 config.watch 为 false 可关闭
+func (e *HarukaAppEngine) watchConfig() {
+	logger := e.LoggerPlugin.Logger.NewScope("config")
+	for _, plugin := range e.initializedPlugins {
+		rp, ok := plugin.(PluginWithConfigReload)
+		if !ok {
+			continue
+		}
+		for _, prefix := range rp.ConfigPrefixes() {
+			e.ConfigProvider.Subscribe(prefix, rp.OnConfigChange)
+		}
+	}
+	configer := e.ConfigProvider.Config()
+	if configer.IsSet("config.watch") && !configer.GetBool("config.watch") {
+		return
+	}
+	if e.ConfigProvider.ConfigFile() == "" {
+		return
+	}
+	if err := e.ConfigProvider.Watch(); err != nil {
+		logger.Error(fmt.Sprintf("watch config file failed: %s", err.Error()))
+		return
+	}
+	logger.WithFields(youlog2.Fields{
+		"file": e.ConfigProvider.ConfigFile(),
+	}).Info("watching config file")
+}
+
+// Stop 通知正在运行的 Run 退出，Run 会在返回前完成 Shutdown
+func (e *HarukaAppEngine) Stop() {
+	stopSignal := e.getStopSignal()
+	e.stopOnce.Do(func() {
+		close(stopSignal)
+	})
+}
+
+// Shutdown 在超时时间内排空 HTTP 与 RPC 请求，然后逆序执行关闭回调与插件的 OnShutdown
+func (e *HarukaAppEngine) Shutdown() {
+	e.shutdownOnce.Do(func() {
+		e.ready.Store(false)
+		e.Stop()
+		logger := e.LoggerPlugin.Logger.NewScope("shutdown")
+		ctx, cancel := context.WithTimeout(context.Background(), e.getShutdownTimeout())
+		defer cancel()
+
+		e.lifecycleLock.Lock()
+		httpServer := e.httpServer
+		rpcServer := e.rpcServer
+		rpcHealth := e.rpcHealth
+		hooks := e.shutdownHooks
+		e.lifecycleLock.Unlock()
+
+		if httpServer != nil {
+			logger.Info("stop http service")
+			if err := httpServer.Shutdown(ctx); err != nil {
+				logger.Error(fmt.Sprintf("drain http service failed: %s", err.Error()))
+				httpServer.Close()
+			}
+		}
+		if rpcServer != nil {
+			logger.Info("stop rpc service")
+			if rpcHealth != nil {
+				rpcHealth.Shutdown()
+			}
+			stopped := make(chan struct{})
+			go func() {
+				rpcServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				logger.Error("drain rpc service timeout, force stop")
+				rpcServer.Stop()
+			}
+		}
+		if e.ConfigProvider != nil {
+			e.ConfigProvider.StopWatch()
+		}
+		for i := len(hooks) - 1; i >= 0; i-- {
+			if err := hooks[i](); err != nil {
+				logger.Error(fmt.Sprintf("shutdown hook failed: %s", err.Error()))
+			}
+		}
+		e.stopPlugins()
+		logger.Info("shutdown complete")
+	})
+}
+
+// stopPlugins 按初始化的逆序停止已初始化的插件
+func (e *HarukaAppEngine) stopPlugins() {
+	logger := e.LoggerPlugin.Logger.NewScope("shutdown")
+	for i := len(e.initializedPlugins) - 1; i >= 0; i-- {
+		plugin := e.initializedPlugins[i]
+		sp, ok := plugin.(PluginWithShutdown)
+		if !ok {
+			continue
+		}
+		if err := sp.OnShutdown(); err != nil {
+			logger.Error(fmt.Sprintf("stop plugin %T failed: %s", plugin, err.Error()))
+		}
+	}
+	e.initializedPlugins = nil
 }
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"fmt"
 	"github.com/allentom/harukap"
 	"github.com/allentom/harukap/config"
 	srv "github.com/kardianos/service"
@@ -12,22 +13,27 @@ import (
 )
 
 type Wrapper struct {
+	Engine        *harukap.HarukaAppEngine
 	Config        *config.Provider
 	ServiceConfig *srv.Config
 	Service       AppService
+	// globalArgs 命令行传入的全局参数，安装服务时写入服务启动参数
+	globalArgs []string
 }
 
 func NewWrapper(engine *harukap.HarukaAppEngine) (*Wrapper, error) {
 	w := &Wrapper{
+		Engine: engine,
 		Config: engine.ConfigProvider,
 	}
 	err := w.InitService()
 	if err != nil {
 		return nil, err
 	}
-	w.Service = AppService{Program: func() {
-		engine.Run()
-	}}
+	w.Service = AppService{
+		Program: engine.Run,
+		OnStop:  engine.Stop,
+	}
 	return w, err
 }
 
@@ -36,30 +42,118 @@ func (w *Wrapper) InitService() error {
 	if err != nil {
 		return err
 	}
+	arguments := append([]string{}, w.globalArgs...)
 	w.ServiceConfig = &srv.Config{
-		Name:             w.Config.Manager.GetString("service.name"),
-		DisplayName:      w.Config.Manager.GetString("service.display"),
+		Name:             w.Config.Config().GetString("service.name"),
+		DisplayName:      w.Config.Config().GetString("service.display"),
 		WorkingDirectory: workPath,
-		Arguments:        []string{"run"},
+		Arguments:        append(arguments, "run"),
 	}
 	return nil
 }
 
+func globalFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "config",
+			Usage: "config file path",
+		},
+		&cli.StringFlag{
+			Name:  "log-level",
+			Usage: "minimum log level: debug, info, warn, error",
+		},
+		&cli.StringFlag{
+			Name:  "addr",
+			Usage: "http listen address, e.g. :8000",
+		},
+	}
+}
+
+// applyGlobalFlags 按全局参数重新加载配置，并记录参数供安装服务时使用
+func (w *Wrapper) applyGlobalFlags(context *cli.Context) error {
+	w.globalArgs = make([]string, 0)
+	if context.IsSet("config") {
+		configPath, err := filepath.Abs(context.String("config"))
+		if err != nil {
+			return err
+		}
+		// 显式指定的配置文件不存在时不能以默认配置启动
+		if _, err = os.Stat(configPath); err != nil {
+			return fmt.Errorf("config file %s: %w", configPath, err)
+		}
+		w.Config.ConfigPath = configPath
+		if err = w.Config.OnInit(); err != nil {
+			return err
+		}
+		w.globalArgs = append(w.globalArgs, "--config", configPath)
+	}
+	overrides := map[string]string{}
+	if context.IsSet("log-level") {
+		overrides["log.youlog.level"] = context.String("log-level")
+		w.globalArgs = append(w.globalArgs, "--log-level", context.String("log-level"))
+	}
+	if context.IsSet("addr") {
+		overrides["addr"] = context.String("addr")
+		w.globalArgs = append(w.globalArgs, "--addr", context.String("addr"))
+	}
+	if err := w.Config.SetOverrides(overrides); err != nil {
+		return err
+	}
+	return w.InitService()
+}
+
 type AppService struct {
-	Program func()
+	// Program 运行应用直到停止，正常停止时返回 nil
+	Program func() error
+	OnStop  func()
+
+	done chan struct{}
+	err  error
 }
 
 func (p *AppService) Start(s srv.Service) error {
-	go p.Program()
+	p.done = make(chan struct{})
+	go func() {
+		p.err = p.Program()
+		close(p.done)
+		// Program 返回错误（如 HTTP 服务异常）时以非零状态结束进程，由服务管理器按失败处理并重启。
+		// 正常停止（包括引擎自行处理的 SIGTERM）返回 nil，由服务管理器的 Stop 完成退出
+		if p.err != nil {
+			logrus.Error(p.err)
+			os.Exit(1)
+		}
+	}()
 	return nil
 }
 
+// Stop 通知引擎关闭，并等待 Program 完成关闭流程后返回
 func (p *AppService) Stop(s srv.Service) error {
+	if p.OnStop != nil {
+		p.OnStop()
+	}
+	if p.done != nil {
+		<-p.done
+	}
 	return nil
 }
 
+// RunService 在前台直接运行，由服务管理器启动时交给服务管理器控制启停
+func (w *Wrapper) RunService() error {
+	if srv.Interactive() {
+		return w.Service.Program()
+	}
+	s, err := srv.New(&w.Service, w.ServiceConfig)
+	if err != nil {
+		return err
+	}
+	if err = s.Run(); err != nil {
+		return err
+	}
+	return w.Service.err
+}
+
 func (w *Wrapper) InstallAsService() {
-	prg := &AppService{}
+	prg := &w.Service
 	s, err := srv.New(prg, w.ServiceConfig)
 	if err != nil {
 		logrus.Fatal(err)
@@ -75,7 +169,7 @@ func (w *Wrapper) InstallAsService() {
 
 func (w *Wrapper) UnInstall() {
 
-	prg := &AppService{}
+	prg := &w.Service
 	s, err := srv.New(prg, w.ServiceConfig)
 	if err != nil {
 		logrus.Fatal(err)
@@ -88,7 +182,7 @@ func (w *Wrapper) UnInstall() {
 }
 
 func (w *Wrapper) StartService() {
-	prg := &AppService{}
+	prg := &w.Service
 	s, err := srv.New(prg, w.ServiceConfig)
 	if err != nil {
 		logrus.Fatal(err)
@@ -99,7 +193,7 @@ func (w *Wrapper) StartService() {
 	}
 }
 func (w *Wrapper) StopService() {
-	prg := &AppService{}
+	prg := &w.Service
 	s, err := srv.New(prg, w.ServiceConfig)
 	if err != nil {
 		logrus.Fatal(err)
@@ -110,7 +204,7 @@ func (w *Wrapper) StopService() {
 	}
 }
 func (w *Wrapper) RestartService() {
-	prg := &AppService{}
+	prg := &w.Service
 	s, err := srv.New(prg, w.ServiceConfig)
 	if err != nil {
 		logrus.Fatal(err)
@@ -122,7 +216,10 @@ func (w *Wrapper) RestartService() {
 }
 func (w *Wrapper) RunApp() {
 	app := &cli.App{
-		Flags: []cli.Flag{},
+		// --set 的值可能包含逗号，不按逗号拆分
+		DisableSliceFlagSeparator: true,
+		Flags:                     globalFlags(),
+		Before:                    w.applyGlobalFlags,
 		Commands: []*cli.Command{
 			&cli.Command{
 				Name:  "service",
@@ -171,12 +268,20 @@ func (w *Wrapper) RunApp() {
 				},
 				Description: "Service controller",
 			},
+			w.configCommand(),
+			w.doctorCommand(),
 			{
 				Name:  "run",
 				Usage: "run app",
+				Flags: []cli.Flag{
+					setFlag(),
+				},
 				Action: func(context *cli.Context) error {
-					w.Service.Program()
-					return nil
+					err := w.applyOverrides(context)
+					if err != nil {
+						return err
+					}
+					return w.RunService()
 				},
 			},
 		},
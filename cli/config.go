@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/config"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+func setFlag() cli.Flag {
+	return &cli.StringSliceFlag{
+		Name:  "set",
+		Usage: "override config value, e.g. --set datasource.main.host=127.0.0.1, takes precedence over env and config file",
+	}
+}
+
+// applyOverrides 应用 --set 传入的配置
+func (w *Wrapper) applyOverrides(context *cli.Context) error {
+	overrides, err := config.ParseOverrides(context.StringSlice("set"))
+	if err != nil {
+		return err
+	}
+	return w.Config.SetOverrides(overrides)
+}
+
+// ValidateConfig 校验配置声明与插件依赖，不启动任何服务，未声明的配置项也视为错误
+func (w *Wrapper) ValidateConfig() error {
+	if err := w.Engine.ValidateConfigStrict(); err != nil {
+		return err
+	}
+	if _, err := harukap.SortPlugins(w.Engine.Plugins); err != nil {
+		return err
+	}
+	fmt.Println("config is valid")
+	return nil
+}
+
+// DumpConfig 输出生效的配置，敏感值已打码
+func (w *Wrapper) DumpConfig() error {
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(w.Engine.DumpConfig())
+}
+
+// InitConfig 写入示例配置，output 为 - 时输出到标准输出
+func (w *Wrapper) InitConfig(output string, force bool) error {
+	if output == "-" {
+		return w.Engine.WriteConfigExample(os.Stdout)
+	}
+	if !force {
+		if _, err := os.Stat(output); err == nil {
+			return fmt.Errorf("%s already exists, use --force to overwrite", output)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = w.Engine.WriteConfigExample(file); err != nil {
+		return err
+	}
+	fmt.Printf("example config written to %s\n", output)
+	return nil
+}
+
+func (w *Wrapper) configCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "config tools",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "validate",
+				Usage: "load config and run plugin schema checks",
+				Flags: []cli.Flag{
+					setFlag(),
+				},
+				Action: func(context *cli.Context) error {
+					if err := w.applyOverrides(context); err != nil {
+						return err
+					}
+					return w.ValidateConfig()
+				},
+			},
+			{
+				Name:  "dump",
+				Usage: "print effective config with secrets masked",
+				Flags: []cli.Flag{
+					setFlag(),
+				},
+				Action: func(context *cli.Context) error {
+					if err := w.applyOverrides(context); err != nil {
+						return err
+					}
+					return w.DumpConfig()
+				},
+			},
+			{
+				Name:  "init",
+				Usage: "write a commented example config for registered plugins",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "output",
+						Usage: "output file, - for stdout",
+						Value: "config.yml",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "overwrite existing file",
+					},
+				},
+				Action: func(context *cli.Context) error {
+					return w.InitConfig(context.String("output"), context.Bool("force"))
+				},
+			},
+			{
+				Name:  "reference",
+				Usage: "print all known config keys",
+				Action: func(context *cli.Context) error {
+					return w.Engine.WriteConfigReference(os.Stdout)
+				},
+			},
+		},
+	}
+}
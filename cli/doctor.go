@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/allentom/harukap"
+	"github.com/urfave/cli/v2"
+)
+
+// Doctor 试运行初始化插件并输出所有外部依赖的探测结果，有失败项时返回错误
+func (w *Wrapper) Doctor(ctx context.Context) error {
+	results, err := w.Engine.Doctor(ctx)
+	if err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "PLUGIN\tNAME\tTARGET\tSTATUS\tLATENCY\tVERSION\tERROR")
+	failed := 0
+	for _, result := range results {
+		if result.Status == harukap.ProbeStatusFail {
+			failed++
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%dms\t%s\t%s\n",
+			result.Plugin, result.Name, result.Target, result.Status, result.Latency, result.Version, result.Err)
+	}
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+func (w *Wrapper) doctorCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "doctor",
+		Usage: "probe every configured dependency without starting the app",
+		Flags: []cli.Flag{
+			setFlag(),
+		},
+		Action: func(context *cli.Context) error {
+			if err := w.applyOverrides(context); err != nil {
+				return err
+			}
+			return w.Doctor(context.Context)
+		},
+	}
+}
@@ -3,6 +3,7 @@ package commons
 const (
 	AuthTypeWebOauth = "weboauth"
 	AuthTypeBase     = "base"
+	AuthTypeAPIKey   = "apikey"
 )
 const (
 	AuthProviderYouAuth = "youauth"
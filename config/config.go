@@ -1,16 +1,74 @@
 package config
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
+// Provider 配置提供者，配置优先级从高到低为：
+// 命令行 --set > 环境变量 > 配置文件 > 默认值（SetDefault）。
+// 任一来源中以 file:// 开头的值都会被替换为对应文件的内容
 type Provider struct {
+	// Manager OnInit 加载的配置实例，之后的重新加载与默认值不会更新它。
+	//
+	// Deprecated: 只是初始化时的快照，读取当前配置请使用 Config
 	Manager    *viper.Viper
 	OnLoaded   func(provider *Provider)
 	ConfigPath string
+	// EnvPrefix 环境变量前缀，为空时使用 DefaultEnvPrefix
+	EnvPrefix string
+	// EnvKeyMapping 环境变量名到配置键的显式映射，用于不带前缀或无法按规则映射的变量
+	EnvKeyMapping map[string]string
+	// Overrides 命令行传入的配置，重新初始化时会再次应用
+	Overrides map[string]string
+
+	configFile  string
+	raw         []byte
+	reloadLock  sync.Mutex
+	subscribers []*configSubscriber
+	watcher     *fsnotify.Watcher
+	// current 当前生效的配置实例，重新加载时整体替换，已发布的实例不再修改
+	current atomic.Pointer[viper.Viper]
+	// defaultSchemas ApplyDefaults 传入的声明，重新加载时再次应用默认值
+	defaultSchemas []*Schema
+}
+
+// Config 返回当前生效的配置。重新加载时会创建新的实例后整体替换，
+// 正在读取的实例不会被修改，可以在请求处理中并发读取
+func (p *Provider) Config() *viper.Viper {
+	if current := p.current.Load(); current != nil {
+		return current
+	}
+	return p.Manager
+}
+
+// swap 发布新的配置实例，调用方需持有 reloadLock。Manager 只在 OnInit 中设置，这里不修改以免与读取方竞争
+func (p *Provider) swap(manager *viper.Viper) {
+	p.current.Store(manager)
+}
+
+// build 以配置文件内容创建新的配置实例，并叠加 file:// 值、环境变量、命令行配置与声明的默认值
+func (p *Provider) build(raw []byte) (*viper.Viper, error) {
+	manager := viper.New()
+	manager.SetConfigType("yaml")
+	if raw != nil {
+		err := manager.ReadConfig(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := p.applyOverlays(manager, raw)
+	if err != nil {
+		return nil, err
+	}
+	applyDefaults(manager, p.defaultSchemas)
+	return manager, nil
 }
 
 func NewProvider(OnLoaded func(provider *Provider), ConfigPath string) (*Provider, error) {
@@ -25,23 +83,55 @@ func NewProvider(OnLoaded func(provider *Provider), ConfigPath string) (*Provide
 	return provider, nil
 }
 
+// ProviderOptions 创建 Provider 的可选参数
+type ProviderOptions struct {
+	ConfigPath    string
+	EnvPrefix     string
+	EnvKeyMapping map[string]string
+	Overrides     map[string]string
+}
+
+func NewProviderWithOptions(OnLoaded func(provider *Provider), options ProviderOptions) (*Provider, error) {
+	provider := &Provider{
+		OnLoaded:      OnLoaded,
+		ConfigPath:    options.ConfigPath,
+		EnvPrefix:     options.EnvPrefix,
+		EnvKeyMapping: options.EnvKeyMapping,
+		Overrides:     options.Overrides,
+	}
+	err := provider.OnInit()
+	if err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
 func (p *Provider) OnInit() error {
-	p.Manager = viper.New()
-	p.Manager.SetConfigType("yaml")
 	configPath := "./"
 	configName := "config"
 	if p.ConfigPath != "" {
 		configPath = filepath.Dir(p.ConfigPath)
 		configName = filepath.Base(p.ConfigPath)
 	}
-	p.Manager.AddConfigPath(configPath)
-	p.Manager.SetConfigName(configName)
+	p.reloadLock.Lock()
+	p.configFile = ""
+	p.raw = nil
 	if _, err := os.Stat(filepath.Join(configPath, configName)); err == nil {
-		err := p.Manager.ReadInConfig()
+		p.configFile = filepath.Join(configPath, configName)
+		p.raw, err = os.ReadFile(p.configFile)
 		if err != nil {
+			p.reloadLock.Unlock()
 			return err
 		}
 	}
+	manager, err := p.build(p.raw)
+	if err != nil {
+		p.reloadLock.Unlock()
+		return err
+	}
+	p.swap(manager)
+	p.Manager = manager
+	p.reloadLock.Unlock()
 	if p.OnLoaded != nil {
 		p.OnLoaded(p)
 	}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultEnvPrefix 默认的环境变量前缀，HARUKAP_DATASOURCE_MAIN_PASSWORD 对应 datasource.main.password
+const DefaultEnvPrefix = "HARUKAP"
+
+// SecretFileScheme 以该前缀开头的配置值会被替换为对应文件的内容，如 file:///run/secrets/db_password
+const SecretFileScheme = "file://"
+
+// ResolveSecret 解析 file:// 形式的配置值，读取文件内容并去掉结尾换行；其他值原样返回
+func ResolveSecret(value string) (string, error) {
+	if !strings.HasPrefix(value, SecretFileScheme) {
+		return value, nil
+	}
+	path := strings.TrimPrefix(value, SecretFileScheme)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read secret file %s failed: %w", path, err)
+	}
+	return strings.TrimRight(string(raw), "\r\n"), nil
+}
+
+func setNestedValue(target map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	current := target
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := current[part].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			current[part] = child
+		}
+		current = child
+	}
+	current[parts[len(parts)-1]] = value
+}
+
+// applyOverlays 按优先级依次将配置文件中的 file:// 值、环境变量、命令行配置合并到配置文件层。
+// 使用 MergeConfigMap 而不是 Set，避免 Set 覆盖整个父级导致 GetStringMap 只能看到被覆盖的键
+func (p *Provider) applyOverlays(manager *viper.Viper, raw []byte) error {
+	if raw != nil {
+		secrets, err := configSecrets(raw)
+		if err != nil {
+			return err
+		}
+		if err = mergeValues(manager, secrets); err != nil {
+			return err
+		}
+	}
+	env, err := p.envValues(manager)
+	if err != nil {
+		return err
+	}
+	if err = mergeValues(manager, env); err != nil {
+		return err
+	}
+	overrides := map[string]string{}
+	for key, value := range p.Overrides {
+		resolved, err := ResolveSecret(value)
+		if err != nil {
+			return fmt.Errorf("set %s: %w", key, err)
+		}
+		overrides[key] = resolved
+	}
+	return mergeValues(manager, overrides)
+}
+
+func mergeValues(manager *viper.Viper, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	nested := map[string]interface{}{}
+	for key, value := range values {
+		setNestedValue(nested, strings.ToLower(key), value)
+	}
+	return manager.MergeConfigMap(nested)
+}
+
+// configSecrets 返回配置文件中 file:// 值对应的文件内容
+func configSecrets(raw []byte) (map[string]string, error) {
+	fileConfig := viper.New()
+	fileConfig.SetConfigType("yaml")
+	err := fileConfig.ReadConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]interface{}{}
+	flattenSettings("", fileConfig.AllSettings(), settings)
+	secrets := map[string]string{}
+	for key, value := range settings {
+		stringValue, ok := value.(string)
+		if !ok || !strings.HasPrefix(stringValue, SecretFileScheme) {
+			continue
+		}
+		secret, err := ResolveSecret(stringValue)
+		if err != nil {
+			return nil, fmt.Errorf("resolve %s: %w", key, err)
+		}
+		secrets[key] = secret
+	}
+	return secrets, nil
+}
+
+// envKey 将环境变量名映射为配置键。优先匹配已有的键，以支持 api_key 这类含下划线的键，
+// 否则将下划线视为层级分隔符
+func envKey(name string, knownKeys []string) string {
+	normalized := strings.ToLower(name)
+	for _, key := range knownKeys {
+		if strings.ReplaceAll(key, ".", "_") == normalized {
+			return key
+		}
+	}
+	return strings.ReplaceAll(normalized, "_", ".")
+}
+
+// envValues 读取带 EnvPrefix 前缀的环境变量，以及 EnvKeyMapping 中显式映射的环境变量
+func (p *Provider) envValues(manager *viper.Viper) (map[string]string, error) {
+	values := map[string]string{}
+	prefix := p.EnvPrefix
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	prefix = strings.ToUpper(prefix) + "_"
+	knownKeys := manager.AllKeys()
+	for _, env := range os.Environ() {
+		name, value, found := strings.Cut(env, "=")
+		if !found || !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+		resolved, err := ResolveSecret(value)
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", name, err)
+		}
+		values[envKey(strings.TrimPrefix(name, prefix), knownKeys)] = resolved
+	}
+	for name, key := range p.EnvKeyMapping {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		resolved, err := ResolveSecret(value)
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", name, err)
+		}
+		values[key] = resolved
+	}
+	return values, nil
+}
+
+// SetOverrides 设置命令行 --set 传入的配置，优先级最高，重新加载配置文件后依然生效
+func (p *Provider) SetOverrides(overrides map[string]string) error {
+	for key, value := range overrides {
+		if _, err := ResolveSecret(value); err != nil {
+			return fmt.Errorf("set %s: %w", key, err)
+		}
+	}
+	p.reloadLock.Lock()
+	defer p.reloadLock.Unlock()
+	if p.Overrides == nil {
+		p.Overrides = map[string]string{}
+	}
+	for key, value := range overrides {
+		p.Overrides[key] = value
+	}
+	manager, err := p.build(p.raw)
+	if err != nil {
+		return err
+	}
+	p.swap(manager)
+	return nil
+}
+
+// ParseOverrides 解析 key=value 形式的参数
+func ParseOverrides(args []string) (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, arg := range args {
+		key, value, found := strings.Cut(arg, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid override %q, expect key=value", arg)
+		}
+		overrides[key] = value
+	}
+	return overrides, nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	util "github.com/allentom/harukap/utils"
+)
+
+// sensitiveNames 未声明 Secret 时，键名包含这些词的值同样视为敏感配置
+var sensitiveNames = []string{"password", "secret", "token", "apikey", "api_key", "credential"}
+
+func isSensitiveName(key string) bool {
+	name := strings.ToLower(key[strings.LastIndex(key, ".")+1:])
+	for _, sensitive := range sensitiveNames {
+		if strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskValue 对敏感值打码，过短的值完全隐藏
+func MaskValue(value interface{}) interface{} {
+	stringValue, ok := value.(string)
+	if !ok {
+		return "***"
+	}
+	masked := util.MaskKeepHeadTail(stringValue, 1, 2)
+	if masked == stringValue && stringValue != "" {
+		return "***"
+	}
+	return masked
+}
+
+// MaskSecrets 返回打码后的副本，schemas 中声明为 Secret 的键与键名疑似敏感的值会被打码
+func MaskSecrets(settings map[string]interface{}, schemas ...*Schema) map[string]interface{} {
+	return maskSecrets("", settings, schemas)
+}
+
+func maskSecrets(prefix string, settings map[string]interface{}, schemas []*Schema) map[string]interface{} {
+	result := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		fullKey := joinKey(prefix, key)
+		if child, ok := value.(map[string]interface{}); ok {
+			result[key] = maskSecrets(fullKey, child, schemas)
+			continue
+		}
+		if value != nil && (IsSecretKey(schemas, fullKey) || isSensitiveName(fullKey)) {
+			result[key] = MaskValue(value)
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// MaskedSettings 返回当前生效的全部配置，敏感值已打码
+func (p *Provider) MaskedSettings(schemas ...*Schema) map[string]interface{} {
+	return MaskSecrets(p.Config().AllSettings(), schemas...)
+}
+
+type exampleNode struct {
+	name     string
+	comment  string
+	field    *Field
+	children []*exampleNode
+}
+
+func (n *exampleNode) child(name string) *exampleNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &exampleNode{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+func exampleValue(field *Field) string {
+	if field.Default != nil {
+		if s, ok := field.Default.(string); ok {
+			return strconv.Quote(s)
+		}
+		return fmt.Sprintf("%v", field.Default)
+	}
+	if len(field.Enum) > 0 {
+		return strconv.Quote(field.Enum[0])
+	}
+	switch field.Type {
+	case FieldTypeInt, FieldTypeFloat:
+		return "0"
+	case FieldTypeBool:
+		return "false"
+	case FieldTypeDuration:
+		return strconv.Quote("0s")
+	case FieldTypeList:
+		return "[]"
+	case FieldTypeMap:
+		return "{}"
+	}
+	return strconv.Quote("")
+}
+
+func fieldComment(field *Field) string {
+	notes := make([]string, 0)
+	if field.Required {
+		notes = append(notes, "required")
+	}
+	if len(field.Enum) > 0 {
+		notes = append(notes, "one of: "+strings.Join(field.Enum, ", "))
+	}
+	comment := field.Description
+	if len(notes) > 0 {
+		comment = strings.TrimSpace(fmt.Sprintf("%s (%s)", comment, strings.Join(notes, "; ")))
+	}
+	return comment
+}
+
+func writeExampleNode(w io.Writer, node *exampleNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if node.comment != "" {
+		fmt.Fprintf(w, "%s# %s\n", indent, node.comment)
+	}
+	if node.field != nil {
+		if comment := fieldComment(node.field); comment != "" {
+			fmt.Fprintf(w, "%s# %s\n", indent, comment)
+		}
+	}
+	if len(node.children) == 0 {
+		value := strconv.Quote("")
+		if node.field != nil {
+			value = exampleValue(node.field)
+		}
+		fmt.Fprintf(w, "%s%s: %s\n", indent, node.name, value)
+		return
+	}
+	fmt.Fprintf(w, "%s%s:\n", indent, node.name)
+	for _, child := range node.children {
+		writeExampleNode(w, child, depth+1)
+	}
+}
+
+// WriteExample 按声明生成带注释的示例配置，* 以 example 代替
+func WriteExample(w io.Writer, schemas ...*Schema) error {
+	root := &exampleNode{}
+	for _, schema := range schemas {
+		for i := range schema.Fields {
+			field := &schema.Fields[i]
+			parts := strings.Split(schema.FullKey(field.Key), ".")
+			node := root
+			for depth, part := range parts {
+				if part == "*" {
+					part = "example"
+				}
+				next := node.child(part)
+				if depth == 0 && next.comment == "" && next.field == nil && len(next.children) == 0 {
+					next.comment = schema.Description
+				}
+				node = next
+			}
+			node.field = field
+		}
+	}
+	for i, node := range root.children {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		writeExampleNode(w, node, 0)
+	}
+	return nil
+}
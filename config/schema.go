@@ -0,0 +1,389 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// FieldType 配置项的值类型
+type FieldType string
+
+const (
+	FieldTypeString   FieldType = "string"
+	FieldTypeInt      FieldType = "int"
+	FieldTypeFloat    FieldType = "float"
+	FieldTypeBool     FieldType = "bool"
+	FieldTypeDuration FieldType = "duration"
+	FieldTypeList     FieldType = "list"
+	// FieldTypeMap 键值对，其下的任意子键都视为已声明
+	FieldTypeMap FieldType = "map"
+	// FieldTypeAny 不做类型检查
+	FieldTypeAny FieldType = "any"
+)
+
+// Field 配置项声明，Key 相对于 Schema.Prefix，可使用 * 匹配一层任意名称，如 *.type
+type Field struct {
+	Key         string
+	Type        FieldType
+	Required    bool
+	Enum        []string
+	Default     interface{}
+	Description string
+	// Secret 为 true 时输出配置时会打码
+	Secret bool
+}
+
+// Schema 一组配置项的声明。Prefix 不为空时，该前缀下未声明的键会由 UnknownKeys 报告，用于发现拼写错误
+type Schema struct {
+	Prefix      string
+	Description string
+	// EnableKey 开关配置项（相对 Prefix），值不为 true 时跳过必填检查与 Validate
+	EnableKey string
+	Fields    []Field
+	// Validate 额外的校验，如依赖其他配置项取值的必填项
+	Validate func(provider *Provider) ValidationErrors
+	// When 限定第一个 * 匹配的子项，子项下这些键的取值都相同时声明才对其生效（默认值与类型检查），
+	// 例如 {"type": "local"} 只作用于 type 为 local 的 auth.<name>
+	When map[string]string
+}
+
+// FullKey 返回配置项的完整键名
+func (s *Schema) FullKey(key string) string {
+	if s.Prefix == "" {
+		return key
+	}
+	return s.Prefix + "." + key
+}
+
+// ValidationError 单个配置项的校验错误
+type ValidationError struct {
+	Key     string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Message)
+}
+
+// ValidationErrors 一次校验中发现的所有错误
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return fmt.Sprintf("invalid config:\n  %s", strings.Join(lines, "\n  "))
+}
+
+// expandKey 将带 * 的键展开为当前配置中存在的具体键，* 只匹配值为 map 的子项，
+// 第一个 * 匹配的子项还需满足 when
+func (p *Provider) expandKey(pattern string, when map[string]string) []string {
+	return expandKey(p.Config(), pattern, when)
+}
+
+func expandKey(manager *viper.Viper, pattern string, when map[string]string) []string {
+	parts := strings.Split(strings.ToLower(pattern), ".")
+	keys := []string{""}
+	filtered := false
+	for _, part := range parts {
+		next := make([]string, 0)
+		for _, key := range keys {
+			if part != "*" {
+				next = append(next, joinKey(key, part))
+				continue
+			}
+			children := make([]string, 0)
+			for name, value := range manager.GetStringMap(key) {
+				if _, ok := value.(map[string]interface{}); !ok {
+					continue
+				}
+				if !filtered && !matchWhen(manager, joinKey(key, name), when) {
+					continue
+				}
+				children = append(children, name)
+			}
+			sort.Strings(children)
+			for _, name := range children {
+				next = append(next, joinKey(key, name))
+			}
+		}
+		if part == "*" {
+			filtered = true
+		}
+		keys = next
+	}
+	return keys
+}
+
+func matchWhen(manager *viper.Viper, entry string, when map[string]string) bool {
+	for key, value := range when {
+		if manager.GetString(joinKey(entry, key)) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func joinKey(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// matchPattern 判断具体键是否匹配带 * 的声明，map 类型的声明同时匹配其所有子键
+func matchPattern(pattern string, fieldType FieldType, key string) bool {
+	patternParts := strings.Split(strings.ToLower(pattern), ".")
+	keyParts := strings.Split(key, ".")
+	if len(keyParts) < len(patternParts) {
+		return false
+	}
+	if len(keyParts) > len(patternParts) && fieldType != FieldTypeMap && fieldType != FieldTypeAny {
+		return false
+	}
+	for i, part := range patternParts {
+		if part != "*" && part != keyParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func checkType(fieldType FieldType, value interface{}) error {
+	var err error
+	switch fieldType {
+	case FieldTypeString:
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			err = fmt.Errorf("expect string")
+		}
+	case FieldTypeInt:
+		_, err = cast.ToIntE(value)
+	case FieldTypeFloat:
+		_, err = cast.ToFloat64E(value)
+	case FieldTypeBool:
+		_, err = cast.ToBoolE(value)
+	case FieldTypeDuration:
+		_, err = cast.ToDurationE(value)
+	case FieldTypeList:
+		_, err = cast.ToStringSliceE(value)
+	case FieldTypeMap:
+		_, err = cast.ToStringMapE(value)
+	}
+	if err != nil {
+		return fmt.Errorf("expect %s, got %v", fieldType, value)
+	}
+	return nil
+}
+
+// RequireKeys 检查配置项均已设置且不为空，供 Schema.Validate 中的条件必填使用
+func (p *Provider) RequireKeys(keys ...string) ValidationErrors {
+	errs := make(ValidationErrors, 0)
+	for _, key := range keys {
+		if !p.Config().IsSet(key) || cast.ToString(p.Config().Get(key)) == "" {
+			errs = append(errs, &ValidationError{Key: key, Message: "required"})
+		}
+	}
+	return errs
+}
+
+// ApplyDefaults 将声明中的默认值设置为配置默认值，带 * 的键对每个已存在的子项生效，
+// 重新加载配置后会再次应用。已应用过的声明不会重复添加
+func (p *Provider) ApplyDefaults(schemas ...*Schema) {
+	p.reloadLock.Lock()
+	defer p.reloadLock.Unlock()
+	for _, schema := range schemas {
+		if !containsSchema(p.defaultSchemas, schema) {
+			p.defaultSchemas = append(p.defaultSchemas, schema)
+		}
+	}
+	p.rebuildDefaults()
+}
+
+// SetDefaultSchemas 与 ApplyDefaults 相同，但替换之前应用的全部声明，
+// 供每次都会重新收集全部声明的调用方使用
+func (p *Provider) SetDefaultSchemas(schemas ...*Schema) {
+	p.reloadLock.Lock()
+	defer p.reloadLock.Unlock()
+	p.defaultSchemas = append([]*Schema{}, schemas...)
+	p.rebuildDefaults()
+}
+
+func containsSchema(schemas []*Schema, schema *Schema) bool {
+	for _, item := range schemas {
+		if item == schema {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuildDefaults 以当前声明重新构建配置实例，调用方需持有 reloadLock
+func (p *Provider) rebuildDefaults() {
+	manager, err := p.build(p.raw)
+	if err != nil {
+		// 配置内容已在加载时解析过，这里只在环境变量等外部来源变化后才会失败，保留当前配置
+		ConfigLogger.Error(fmt.Sprintf("apply config defaults failed: %s", err.Error()))
+		return
+	}
+	p.swap(manager)
+}
+
+func applyDefaults(manager *viper.Viper, schemas []*Schema) {
+	for _, schema := range schemas {
+		for _, field := range schema.Fields {
+			if field.Default == nil {
+				continue
+			}
+			for _, key := range expandKey(manager, schema.FullKey(field.Key), schema.When) {
+				manager.SetDefault(key, field.Default)
+			}
+		}
+	}
+}
+
+// ValidateSchemas 按声明校验当前配置，返回发现的所有错误，没有错误时返回 nil
+func (p *Provider) ValidateSchemas(schemas ...*Schema) error {
+	errs := make(ValidationErrors, 0)
+	for _, schema := range schemas {
+		enabled := true
+		if schema.EnableKey != "" {
+			enableKey := schema.FullKey(schema.EnableKey)
+			enabled = p.Config().GetBool(enableKey)
+		}
+		for _, field := range schema.Fields {
+			for _, key := range p.expandKey(schema.FullKey(field.Key), schema.When) {
+				if !p.Config().IsSet(key) {
+					if field.Required && enabled {
+						errs = append(errs, &ValidationError{Key: key, Message: "required"})
+					}
+					continue
+				}
+				value := p.Config().Get(key)
+				if err := checkType(field.Type, value); err != nil {
+					errs = append(errs, &ValidationError{Key: key, Message: err.Error()})
+					continue
+				}
+				if len(field.Enum) > 0 {
+					stringValue := cast.ToString(value)
+					found := false
+					for _, option := range field.Enum {
+						if option == stringValue {
+							found = true
+							break
+						}
+					}
+					if !found {
+						errs = append(errs, &ValidationError{
+							Key:     key,
+							Message: fmt.Sprintf("must be one of [%s], got %q", strings.Join(field.Enum, ", "), stringValue),
+						})
+					}
+				}
+			}
+		}
+		if schema.Validate != nil && enabled {
+			errs = append(errs, schema.Validate(p)...)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// userKeys 返回配置文件与命令行中出现的键，不包括代码中设置的默认值
+func (p *Provider) userKeys() []string {
+	settings := map[string]interface{}{}
+	if p.raw != nil {
+		fileConfig := viper.New()
+		fileConfig.SetConfigType("yaml")
+		if err := fileConfig.ReadConfig(bytes.NewReader(p.raw)); err == nil {
+			flattenSettings("", fileConfig.AllSettings(), settings)
+		}
+	}
+	for key := range p.Overrides {
+		settings[strings.ToLower(key)] = nil
+	}
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// UnknownKeys 找出声明了 Prefix 的配置中未声明的键，不包含在 ValidateSchemas 的结果中，
+// 由调用方决定作为警告还是错误
+func (p *Provider) UnknownKeys(schemas ...*Schema) ValidationErrors {
+	errs := make(ValidationErrors, 0)
+	keys := p.userKeys()
+	for _, key := range keys {
+		owned := false
+		declared := false
+		for _, schema := range schemas {
+			if schema.Prefix == "" || !matchPrefix(strings.ToLower(schema.Prefix), key) {
+				continue
+			}
+			owned = true
+			for _, field := range schema.Fields {
+				if matchPattern(schema.FullKey(field.Key), field.Type, key) {
+					declared = true
+					break
+				}
+			}
+			if declared {
+				break
+			}
+		}
+		if owned && !declared {
+			errs = append(errs, &ValidationError{Key: key, Message: "unknown config key"})
+		}
+	}
+	return errs
+}
+
+// IsSecretKey 判断键是否被声明为敏感配置
+func IsSecretKey(schemas []*Schema, key string) bool {
+	key = strings.ToLower(key)
+	for _, schema := range schemas {
+		for _, field := range schema.Fields {
+			if field.Secret && matchPattern(schema.FullKey(field.Key), field.Type, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteReference 输出所有已声明配置项的参考表
+func WriteReference(w io.Writer, schemas ...*Schema) error {
+	writer := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "KEY\tTYPE\tREQUIRED\tDEFAULT\tDESCRIPTION")
+	for _, schema := range schemas {
+		for _, field := range schema.Fields {
+			description := field.Description
+			if len(field.Enum) > 0 {
+				description = strings.TrimSpace(fmt.Sprintf("%s (one of: %s)", description, strings.Join(field.Enum, ", ")))
+			}
+			defaultValue := ""
+			if field.Default != nil {
+				defaultValue = fmt.Sprintf("%v", field.Default)
+			}
+			fieldType := field.Type
+			if fieldType == "" {
+				fieldType = FieldTypeAny
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%v\t%s\t%s\n", schema.FullKey(field.Key), fieldType, field.Required, defaultValue, description)
+		}
+	}
+	return writer.Flush()
+}
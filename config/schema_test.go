@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestProvider(t *testing.T, content string) *Provider {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewProvider(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestApplyDefaultsWhen(t *testing.T) {
+	provider := newTestProvider(t, `
+auth:
+  local:
+    type: local
+  remote:
+    type: youauth
+    accessTokenTtl: soon
+`)
+	schema := &Schema{
+		When: map[string]string{"type": "local"},
+		Fields: []Field{
+			{Key: "auth.*.accessTokenTtl", Type: FieldTypeInt, Default: 3600},
+			{Key: "auth.*.refreshTokenTtl", Type: FieldTypeInt, Default: 7200},
+		},
+	}
+	provider.ApplyDefaults(schema)
+	if provider.Config().GetInt("auth.local.accesstokenttl") != 3600 {
+		t.Fatal("default should apply to matching entry")
+	}
+	if provider.Config().IsSet("auth.remote.refreshtokenttl") {
+		t.Fatal("default should not apply to other entries")
+	}
+	if err := provider.ValidateSchemas(schema); err != nil {
+		t.Fatalf("type check should skip other entries, got %v", err)
+	}
+
+	provider.ApplyDefaults(schema)
+	provider.ApplyDefaults(schema)
+	if len(provider.defaultSchemas) != 1 {
+		t.Fatalf("expected schema applied once, got %d", len(provider.defaultSchemas))
+	}
+	provider.SetDefaultSchemas(&Schema{}, &Schema{})
+	if len(provider.defaultSchemas) != 2 {
+		t.Fatalf("expected schemas replaced, got %d", len(provider.defaultSchemas))
+	}
+}
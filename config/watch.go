@@ -0,0 +1,253 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var ConfigLogger = logrus.New().WithField("scope", "config")
+
+// reloadDebounce 编辑器保存时往往触发多次写事件，合并后再重新加载
+const reloadDebounce = 200 * time.Millisecond
+
+// ConfigChange 描述某个前缀下发生变化的配置项
+type ConfigChange struct {
+	Prefix string
+	// Keys 为发生变化的完整键名（小写），包括新增与删除的键
+	Keys []string
+	Old  map[string]interface{}
+	New  map[string]interface{}
+	// Rollback 为 true 表示其他订阅者拒绝了本次变更，配置已恢复为 New 中的值
+	Rollback bool
+}
+
+// ConfigChangeHandler 返回错误即拒绝本次变更，Provider 会回滚配置并通知已接受的订阅者
+type ConfigChangeHandler func(change *ConfigChange) error
+
+type configSubscriber struct {
+	prefix  string
+	handler ConfigChangeHandler
+}
+
+// Subscribe 订阅 prefix 下的配置变化，prefix 为空时订阅所有变化
+func (p *Provider) Subscribe(prefix string, handler ConfigChangeHandler) {
+	p.reloadLock.Lock()
+	defer p.reloadLock.Unlock()
+	p.subscribers = append(p.subscribers, &configSubscriber{
+		prefix:  strings.ToLower(prefix),
+		handler: handler,
+	})
+}
+
+// ConfigFile 返回当前使用的配置文件路径
+func (p *Provider) ConfigFile() string {
+	return p.configFile
+}
+
+func flattenSettings(prefix string, settings map[string]interface{}, result map[string]interface{}) {
+	for key, value := range settings {
+		fullKey := key
+		if prefix != "" {
+			fullKey = prefix + "." + key
+		}
+		if child, ok := value.(map[string]interface{}); ok && len(child) > 0 {
+			flattenSettings(fullKey, child, result)
+			continue
+		}
+		result[fullKey] = value
+	}
+}
+
+// diffSettings 返回值不同的键，结果已排序
+func diffSettings(old map[string]interface{}, new map[string]interface{}) []string {
+	keys := make([]string, 0)
+	for key, oldValue := range old {
+		newValue, ok := new[key]
+		if !ok || !reflect.DeepEqual(oldValue, newValue) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range new {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func matchPrefix(prefix string, key string) bool {
+	return prefix == "" || key == prefix || strings.HasPrefix(key, prefix+".")
+}
+
+func settings(manager *viper.Viper) map[string]interface{} {
+	result := map[string]interface{}{}
+	flattenSettings("", manager.AllSettings(), result)
+	return result
+}
+
+// Reload 重新读取配置文件，并将变化通知给订阅了对应前缀的订阅者。
+// 任一订阅者拒绝时配置会回滚到重新加载前的内容。订阅者在锁外调用，可以在回调中读取或重新加载配置
+func (p *Provider) Reload() error {
+	p.reloadLock.Lock()
+	if p.configFile == "" {
+		p.reloadLock.Unlock()
+		return errors.New("no config file loaded")
+	}
+	raw, err := os.ReadFile(p.configFile)
+	if err != nil {
+		p.reloadLock.Unlock()
+		return err
+	}
+	// 在新的实例上加载，解析失败时当前配置不受影响
+	oldManager := p.Config()
+	oldRaw := p.raw
+	manager, err := p.build(raw)
+	if err != nil {
+		p.reloadLock.Unlock()
+		return fmt.Errorf("parse config file failed: %w", err)
+	}
+	oldSettings := settings(oldManager)
+	newSettings := settings(manager)
+	changedKeys := diffSettings(oldSettings, newSettings)
+	p.raw = raw
+	p.swap(manager)
+	subscribers := append([]*configSubscriber{}, p.subscribers...)
+	p.reloadLock.Unlock()
+	if len(changedKeys) == 0 {
+		return nil
+	}
+
+	accepted := make([]*ConfigChange, 0)
+	acceptedSubscribers := make([]*configSubscriber, 0)
+	for _, subscriber := range subscribers {
+		change := &ConfigChange{
+			Prefix: subscriber.prefix,
+			Keys:   []string{},
+			Old:    map[string]interface{}{},
+			New:    map[string]interface{}{},
+		}
+		for _, key := range changedKeys {
+			if !matchPrefix(subscriber.prefix, key) {
+				continue
+			}
+			change.Keys = append(change.Keys, key)
+			change.Old[key] = oldSettings[key]
+			change.New[key] = newSettings[key]
+		}
+		if len(change.Keys) == 0 {
+			continue
+		}
+		if err = subscriber.handler(change); err != nil {
+			rejectErr := fmt.Errorf("config change of %s rejected: %w", subscriber.prefix, err)
+			p.reloadLock.Lock()
+			// 回调期间配置已被再次加载时保留较新的配置
+			if p.current.Load() == manager {
+				p.raw = oldRaw
+				p.swap(oldManager)
+			}
+			p.reloadLock.Unlock()
+			for i := len(accepted) - 1; i >= 0; i-- {
+				acceptedChange := accepted[i]
+				rollback := &ConfigChange{
+					Prefix:   acceptedChange.Prefix,
+					Keys:     acceptedChange.Keys,
+					Old:      acceptedChange.New,
+					New:      acceptedChange.Old,
+					Rollback: true,
+				}
+				if rollbackErr := acceptedSubscribers[i].handler(rollback); rollbackErr != nil {
+					ConfigLogger.Error(fmt.Sprintf("rollback config change of %s failed: %s", acceptedChange.Prefix, rollbackErr.Error()))
+				}
+			}
+			return rejectErr
+		}
+		accepted = append(accepted, change)
+		acceptedSubscribers = append(acceptedSubscribers, subscriber)
+	}
+	ConfigLogger.WithField("keys", changedKeys).Info("config reloaded")
+	return nil
+}
+
+// Watch 监听配置文件变化并自动 Reload。监听的是所在目录，以兼容编辑器先写临时文件再重命名的保存方式
+func (p *Provider) Watch() error {
+	if p.configFile == "" {
+		return errors.New("no config file loaded")
+	}
+	p.reloadLock.Lock()
+	if p.watcher != nil {
+		p.reloadLock.Unlock()
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		p.reloadLock.Unlock()
+		return err
+	}
+	if err = watcher.Add(filepath.Dir(p.configFile)); err != nil {
+		watcher.Close()
+		p.reloadLock.Unlock()
+		return err
+	}
+	p.watcher = watcher
+	p.reloadLock.Unlock()
+
+	configFile := filepath.Clean(p.configFile)
+	go func() {
+		var timer *time.Timer
+		var timerLock sync.Mutex
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != configFile {
+					continue
+				}
+				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+				timerLock.Lock()
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDebounce, func() {
+					if err := p.Reload(); err != nil {
+						ConfigLogger.Error(err.Error())
+					}
+				})
+				timerLock.Unlock()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				ConfigLogger.Error(err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+// StopWatch 停止监听配置文件
+func (p *Provider) StopWatch() error {
+	p.reloadLock.Lock()
+	defer p.reloadLock.Unlock()
+	if p.watcher == nil {
+		return nil
+	}
+	err := p.watcher.Close()
+	p.watcher = nil
+	return err
+}
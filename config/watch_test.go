@@ -0,0 +1,46 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadSubscriberCanUseProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("app:\n  name: a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewProvider(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial := provider.Manager
+	provider.Subscribe("app", func(change *ConfigChange) error {
+		// 在回调中再次使用 Provider 不会死锁
+		provider.ApplyDefaults(&Schema{Prefix: "app", Fields: []Field{{Key: "port", Default: 80}}})
+		return provider.Reload()
+	})
+	if err = os.WriteFile(path, []byte("app:\n  name: b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- provider.Reload()
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload deadlocked")
+	}
+	if provider.Config().GetString("app.name") != "b" || provider.Config().GetInt("app.port") != 80 {
+		t.Fatalf("unexpected config %v", provider.Config().AllSettings())
+	}
+	if provider.Manager != initial {
+		t.Fatal("Manager should stay the initial snapshot")
+	}
+}
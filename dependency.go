@@ -0,0 +1,109 @@
+package harukap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GetPluginName 返回插件名称，未实现 PluginWithName 时使用类型名
+func GetPluginName(plugin HarukaPlugin) string {
+	if np, ok := plugin.(PluginWithName); ok {
+		return np.PluginName()
+	}
+	return fmt.Sprintf("%T", plugin)
+}
+
+// GetPluginByName 按名称查找已注册的插件，不存在时返回 nil
+func (e *HarukaAppEngine) GetPluginByName(name string) HarukaPlugin {
+	for _, plugin := range e.Plugins {
+		if GetPluginName(plugin) == name {
+			return plugin
+		}
+	}
+	return nil
+}
+
+// GetPlugin 按类型查找第一个已注册的插件
+//
+//	nacosPlugin, ok := harukap.GetPlugin[*nacos.NacosPlugin](e)
+func GetPlugin[T HarukaPlugin](e *HarukaAppEngine) (T, bool) {
+	for _, plugin := range e.Plugins {
+		if target, ok := plugin.(T); ok {
+			return target, true
+		}
+	}
+	var empty T
+	return empty, false
+}
+
+// SortPlugins 按依赖关系对插件进行拓扑排序，无依赖关系的插件保持注册顺序。
+// 缺失必需依赖或存在循环依赖时返回错误
+func SortPlugins(plugins []HarukaPlugin) ([]HarukaPlugin, error) {
+	nameIndex := map[string][]int{}
+	for idx, plugin := range plugins {
+		name := GetPluginName(plugin)
+		nameIndex[name] = append(nameIndex[name], idx)
+	}
+	// edges[i] 为依赖 plugins[i] 的插件
+	edges := make([][]int, len(plugins))
+	inDegree := make([]int, len(plugins))
+	addEdges := func(idx int, dependencies []string, required bool) error {
+		for _, dependency := range dependencies {
+			dependencyIndex, ok := nameIndex[dependency]
+			if !ok {
+				if required {
+					return fmt.Errorf("plugin %s depends on missing plugin %s", GetPluginName(plugins[idx]), dependency)
+				}
+				continue
+			}
+			for _, from := range dependencyIndex {
+				if from == idx {
+					return fmt.Errorf("plugin %s depends on itself", GetPluginName(plugins[idx]))
+				}
+				edges[from] = append(edges[from], idx)
+				inDegree[idx]++
+			}
+		}
+		return nil
+	}
+	for idx, plugin := range plugins {
+		if dp, ok := plugin.(PluginWithDependencies); ok {
+			if err := addEdges(idx, dp.PluginDependencies(), true); err != nil {
+				return nil, err
+			}
+		}
+		if op, ok := plugin.(PluginWithOptionalDependencies); ok {
+			if err := addEdges(idx, op.PluginOptionalDependencies(), false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := make([]HarukaPlugin, 0, len(plugins))
+	visited := make([]bool, len(plugins))
+	for len(result) < len(plugins) {
+		// 每轮选取注册顺序最靠前且依赖已满足的插件，保证结果稳定
+		next := -1
+		for idx := range plugins {
+			if !visited[idx] && inDegree[idx] == 0 {
+				next = idx
+				break
+			}
+		}
+		if next == -1 {
+			cycle := make([]string, 0)
+			for idx, plugin := range plugins {
+				if !visited[idx] {
+					cycle = append(cycle, GetPluginName(plugin))
+				}
+			}
+			return nil, fmt.Errorf("plugin dependency cycle detected: %s", strings.Join(cycle, ", "))
+		}
+		visited[next] = true
+		result = append(result, plugins[next])
+		for _, to := range edges[next] {
+			inDegree[to]--
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,88 @@
+package harukap
+
+import (
+	"strings"
+	"testing"
+)
+
+type testPlugin struct {
+	name     string
+	requires []string
+	optional []string
+}
+
+func (p *testPlugin) OnInit(e *HarukaAppEngine) error {
+	return nil
+}
+
+func (p *testPlugin) PluginName() string {
+	return p.name
+}
+
+func (p *testPlugin) PluginDependencies() []string {
+	return p.requires
+}
+
+func (p *testPlugin) PluginOptionalDependencies() []string {
+	return p.optional
+}
+
+func pluginNames(plugins []HarukaPlugin) string {
+	names := make([]string, 0, len(plugins))
+	for _, plugin := range plugins {
+		names = append(names, GetPluginName(plugin))
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSortPlugins(t *testing.T) {
+	plugins := []HarukaPlugin{
+		&testPlugin{name: "youauth", optional: []string{"nacos", "consul"}},
+		&testPlugin{name: "storage"},
+		&testPlugin{name: "thumbnail", requires: []string{"storage"}},
+		&testPlugin{name: "nacos"},
+	}
+	sorted, err := SortPlugins(plugins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pluginNames(sorted); got != "storage,thumbnail,nacos,youauth" {
+		t.Fatalf("unexpected order %s", got)
+	}
+}
+
+func TestSortPlugins_MissingDependency(t *testing.T) {
+	_, err := SortPlugins([]HarukaPlugin{
+		&testPlugin{name: "thumbnail", requires: []string{"storage"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "missing plugin storage") {
+		t.Fatalf("expected missing dependency error, got %v", err)
+	}
+}
+
+func TestSortPlugins_Cycle(t *testing.T) {
+	_, err := SortPlugins([]HarukaPlugin{
+		&testPlugin{name: "a", requires: []string{"b"}},
+		&testPlugin{name: "b", requires: []string{"a"}},
+		&testPlugin{name: "c"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "cycle detected: a, b") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
+
+func TestGetPlugin(t *testing.T) {
+	e := NewHarukaAppEngine()
+	target := &testPlugin{name: "nacos"}
+	e.UsePlugin(target)
+	found, ok := GetPlugin[*testPlugin](e)
+	if !ok || found != target {
+		t.Fatal("plugin not found by type")
+	}
+	if e.GetPluginByName("nacos") != target {
+		t.Fatal("plugin not found by name")
+	}
+	if e.GetPluginByName("storage") != nil {
+		t.Fatal("unexpected plugin found")
+	}
+}
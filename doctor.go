@@ -0,0 +1,165 @@
+package harukap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/allentom/harukap/plugins/youlog"
+)
+
+const (
+	ProbeStatusPass = "pass"
+	ProbeStatusFail = "fail"
+	ProbeStatusSkip = "skip"
+)
+
+// ProbeResult 单个外部依赖的探测结果
+type ProbeResult struct {
+	Plugin string `json:"plugin"`
+	// Name 依赖名称，如 datasource.main
+	Name    string `json:"name"`
+	Target  string `json:"target"`
+	Version string `json:"version"`
+	Status  string `json:"status"`
+	// Latency 探测耗时，单位毫秒
+	Latency int64  `json:"latency"`
+	Err     string `json:"err,omitempty"`
+}
+
+// PluginWithProbe 可选接口：逐个探测插件使用的外部依赖，供 doctor 命令使用。
+// 未实现时 doctor 会退回到 PluginWithHealthCheck
+type PluginWithProbe interface {
+	Probe(ctx context.Context) []*ProbeResult
+}
+
+// Probe 执行一次探测并记录耗时，fn 返回依赖的版本号，未知时返回空字符串
+func Probe(ctx context.Context, name string, target string, fn func(ctx context.Context) (string, error)) *ProbeResult {
+	result := &ProbeResult{
+		Name:   name,
+		Target: target,
+	}
+	start := time.Now()
+	var version string
+	err := runWithTimeout(ctx, probeTimeout(ctx), func(ctx context.Context) error {
+		var err error
+		version, err = fn(ctx)
+		return err
+	})
+	result.Latency = time.Since(start).Milliseconds()
+	switch {
+	case err == nil:
+		result.Status = ProbeStatusPass
+		result.Version = version
+	case errors.Is(err, ErrHealthCheckDisabled):
+		result.Status = ProbeStatusSkip
+	default:
+		result.Status = ProbeStatusFail
+		result.Err = err.Error()
+	}
+	return result
+}
+
+type probeTimeoutKey struct{}
+
+func probeTimeout(ctx context.Context) time.Duration {
+	if timeout, ok := ctx.Value(probeTimeoutKey{}).(time.Duration); ok {
+		return timeout
+	}
+	return DefaultHealthCheckTimeout
+}
+
+// IsDryRun 是否处于 doctor 的试运行模式，插件应跳过服务注册、数据迁移等有副作用的操作
+func (e *HarukaAppEngine) IsDryRun() bool {
+	return e.dryRun
+}
+
+// Doctor 以试运行模式初始化插件，探测所有已配置的外部依赖后停止插件，不会启动 HTTP 与 RPC 服务
+func (e *HarukaAppEngine) Doctor(ctx context.Context) ([]*ProbeResult, error) {
+	e.dryRun = true
+	if e.LoggerPlugin == nil {
+		e.LoggerPlugin = &youlog.Plugin{}
+		err := e.LoggerPlugin.OnInit(e.ConfigProvider)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := e.ValidateConfig(); err != nil {
+		return nil, err
+	}
+	plugins, err := SortPlugins(e.Plugins)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, probeTimeoutKey{}, e.getHealthCheckTimeout())
+	results := make([]*ProbeResult, 0)
+	failed := map[string]bool{}
+	defer e.stopPlugins()
+	for _, plugin := range plugins {
+		name := GetPluginName(plugin)
+		if dependency := failedDependency(plugin, failed); dependency != "" {
+			failed[name] = true
+			results = append(results, &ProbeResult{
+				Plugin: name,
+				Name:   name,
+				Status: ProbeStatusSkip,
+				Err:    fmt.Sprintf("dependency %s failed", dependency),
+			})
+			continue
+		}
+		start := time.Now()
+		err := plugin.OnInit(e)
+		if err != nil {
+			failed[name] = true
+			results = append(results, &ProbeResult{
+				Plugin:  name,
+				Name:    name,
+				Status:  ProbeStatusFail,
+				Latency: time.Since(start).Milliseconds(),
+				Err:     fmt.Sprintf("init failed: %v", err),
+			})
+			continue
+		}
+		e.initializedPlugins = append(e.initializedPlugins, plugin)
+		results = append(results, e.probePlugin(ctx, plugin)...)
+	}
+	return results, nil
+}
+
+func failedDependency(plugin HarukaPlugin, failed map[string]bool) string {
+	dp, ok := plugin.(PluginWithDependencies)
+	if !ok {
+		return ""
+	}
+	for _, dependency := range dp.PluginDependencies() {
+		if failed[dependency] {
+			return dependency
+		}
+	}
+	return ""
+}
+
+func (e *HarukaAppEngine) probePlugin(ctx context.Context, plugin HarukaPlugin) []*ProbeResult {
+	name := GetPluginName(plugin)
+	var results []*ProbeResult
+	switch p := plugin.(type) {
+	case PluginWithProbe:
+		results = p.Probe(ctx)
+	case PluginWithHealthCheck:
+		results = []*ProbeResult{
+			Probe(ctx, name, "", func(ctx context.Context) (string, error) {
+				return "", p.HealthCheck(ctx)
+			}),
+		}
+	default:
+		return []*ProbeResult{{Plugin: name, Name: name, Status: ProbeStatusPass}}
+	}
+	if len(results) == 0 {
+		return []*ProbeResult{{Plugin: name, Name: name, Status: ProbeStatusSkip}}
+	}
+	for _, result := range results {
+		result.Plugin = name
+	}
+	return results
+}
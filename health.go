@@ -0,0 +1,273 @@
+package harukap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	HealthStatusUp       = "up"
+	HealthStatusDown     = "down"
+	HealthStatusDisabled = "disabled"
+)
+
+// DefaultHealthCheckTimeout 单个插件健康检查的默认超时
+const DefaultHealthCheckTimeout = 3 * time.Second
+
+// DefaultHealthCheckInterval 后台健康监控的默认间隔
+const DefaultHealthCheckInterval = 30 * time.Second
+
+var (
+	// ErrHealthCheckDisabled 插件未启用时由 HealthCheck 返回，不计入失败
+	ErrHealthCheckDisabled = errors.New("plugin disabled")
+)
+
+type HealthCheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Latency int64  `json:"latency"`
+	Err     string `json:"err,omitempty"`
+}
+
+type HealthReport struct {
+	Status string               `json:"status"`
+	Time   string               `json:"time"`
+	Checks []*HealthCheckResult `json:"checks"`
+}
+
+// Healthy 所有插件检查均通过或未启用时返回 true
+func (r *HealthReport) Healthy() bool {
+	return r.Status == HealthStatusUp
+}
+
+func (e *HarukaAppEngine) getHealthCheckTimeout() time.Duration {
+	if e.ConfigProvider != nil && e.ConfigProvider.Config() != nil {
+		timeout := e.ConfigProvider.Config().GetInt("health.timeout")
+		if timeout > 0 {
+			return time.Duration(timeout) * time.Millisecond
+		}
+	}
+	return DefaultHealthCheckTimeout
+}
+
+func (e *HarukaAppEngine) getHealthCheckInterval() time.Duration {
+	if e.ConfigProvider != nil && e.ConfigProvider.Config() != nil {
+		interval := e.ConfigProvider.Config().GetInt("health.interval")
+		if interval > 0 {
+			return time.Duration(interval) * time.Millisecond
+		}
+	}
+	return DefaultHealthCheckInterval
+}
+
+// healthCall 正在执行的插件健康检查
+type healthCall struct {
+	done chan struct{}
+	err  error
+}
+
+// runHealthCheck 在超时内执行插件的健康检查，超时后不再等待检查返回。
+// 同一插件同时只有一次检查在执行，上一次检查未返回时等待它的结果，
+// 避免忽略 ctx 的检查在后端卡住时每次探测都遗留一个 goroutine 与连接
+func (e *HarukaAppEngine) runHealthCheck(ctx context.Context, plugin PluginWithHealthCheck, timeout time.Duration) error {
+	if !reflect.TypeOf(plugin).Comparable() {
+		return runWithTimeout(ctx, timeout, plugin.HealthCheck)
+	}
+	call := e.startHealthCheck(ctx, plugin, timeout)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-call.done:
+		return call.err
+	case <-timer.C:
+		return fmt.Errorf("timeout after %s", timeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startHealthCheck 返回插件正在执行的检查，没有时发起新的检查。
+// 检查不随调用方的 ctx 取消，以便之后的调用等待同一次结果
+func (e *HarukaAppEngine) startHealthCheck(ctx context.Context, plugin PluginWithHealthCheck, timeout time.Duration) *healthCall {
+	e.healthLock.Lock()
+	defer e.healthLock.Unlock()
+	if call, ok := e.healthCalls[plugin]; ok {
+		return call
+	}
+	if e.healthCalls == nil {
+		e.healthCalls = map[PluginWithHealthCheck]*healthCall{}
+	}
+	call := &healthCall{done: make(chan struct{})}
+	e.healthCalls[plugin] = call
+	go func() {
+		checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		defer func() {
+			if r := recover(); r != nil {
+				call.err = fmt.Errorf("panic: %v", r)
+			}
+			e.healthLock.Lock()
+			delete(e.healthCalls, plugin)
+			e.healthLock.Unlock()
+			close(call.done)
+		}()
+		call.err = plugin.HealthCheck(checkCtx)
+	}()
+	return call
+}
+
+// runWithTimeout 在超时内执行 fn 并捕获 panic，超时后不再等待 fn 返回
+func runWithTimeout(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		result <- fn(checkCtx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-checkCtx.Done():
+		return fmt.Errorf("timeout after %s", timeout)
+	}
+}
+
+// CheckHealth 并发执行所有实现了 PluginWithHealthCheck 的插件检查并汇总结果
+func (e *HarukaAppEngine) CheckHealth(ctx context.Context) *HealthReport {
+	timeout := e.getHealthCheckTimeout()
+	report := &HealthReport{
+		Status: HealthStatusUp,
+		Time:   time.Now().Format("2006-01-02 15:04:05"),
+		Checks: []*HealthCheckResult{},
+	}
+	var wg sync.WaitGroup
+	for _, plugin := range e.Plugins {
+		checker, ok := plugin.(PluginWithHealthCheck)
+		if !ok {
+			continue
+		}
+		result := &HealthCheckResult{
+			Name: GetPluginName(plugin),
+		}
+		report.Checks = append(report.Checks, result)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := e.runHealthCheck(ctx, checker, timeout)
+			result.Latency = time.Since(start).Milliseconds()
+			switch {
+			case err == nil:
+				result.Status = HealthStatusUp
+			case errors.Is(err, ErrHealthCheckDisabled):
+				result.Status = HealthStatusDisabled
+			default:
+				result.Status = HealthStatusDown
+				result.Err = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	for _, result := range report.Checks {
+		if result.Status == HealthStatusDown {
+			report.Status = HealthStatusDown
+			break
+		}
+	}
+	return report
+}
+
+// OnHealthChange 注册健康状态变化的回调，注册后引擎会按 health.interval 在后台定期检查
+func (e *HarukaAppEngine) OnHealthChange(listener func(report *HealthReport)) {
+	e.lifecycleLock.Lock()
+	defer e.lifecycleLock.Unlock()
+	e.healthListeners = append(e.healthListeners, listener)
+}
+
+// runHealthMonitor 定期检查插件健康状态，在状态变化时通知监听者
+func (e *HarukaAppEngine) runHealthMonitor() {
+	e.lifecycleLock.Lock()
+	listeners := e.healthListeners
+	e.lifecycleLock.Unlock()
+	if len(listeners) == 0 {
+		return
+	}
+	ticker := time.NewTicker(e.getHealthCheckInterval())
+	defer ticker.Stop()
+	lastStatus := HealthStatusUp
+	for {
+		select {
+		case <-ticker.C:
+			report := e.CheckHealth(context.Background())
+			if report.Status == lastStatus {
+				continue
+			}
+			lastStatus = report.Status
+			for _, listener := range listeners {
+				listener(report)
+			}
+		case <-e.getStopSignal():
+			return
+		}
+	}
+}
+
+func writeHealthJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// mountHealthHandlers 注册 /livez /healthz /readyz，直接挂载在路由上以绕过业务中间件（如鉴权）。
+// 设置 health.enable 为 false 可关闭
+func (e *HarukaAppEngine) mountHealthHandlers() {
+	configer := e.ConfigProvider.Config()
+	if configer.IsSet("health.enable") && !configer.GetBool("health.enable") {
+		return
+	}
+	router := e.HttpService.Router.HandlerRouter
+	// 存活检查：进程可以处理请求即可，不检查外部依赖，避免依赖抖动导致重启
+	router.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthJSON(w, http.StatusOK, HealthReport{
+			Status: HealthStatusUp,
+			Time:   time.Now().Format("2006-01-02 15:04:05"),
+			Checks: []*HealthCheckResult{},
+		})
+	}).Methods("GET")
+	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		report := e.CheckHealth(r.Context())
+		status := http.StatusOK
+		if !report.Healthy() {
+			status = http.StatusServiceUnavailable
+		}
+		writeHealthJSON(w, status, report)
+	}).Methods("GET")
+	// 就绪检查：在依赖检查之外，关闭过程中也返回 503 以便尽快摘除流量
+	router.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !e.ready.Load() {
+			writeHealthJSON(w, http.StatusServiceUnavailable, HealthReport{
+				Status: HealthStatusDown,
+				Time:   time.Now().Format("2006-01-02 15:04:05"),
+				Checks: []*HealthCheckResult{},
+			})
+			return
+		}
+		report := e.CheckHealth(r.Context())
+		status := http.StatusOK
+		if !report.Healthy() {
+			status = http.StatusServiceUnavailable
+		}
+		writeHealthJSON(w, status, report)
+	}).Methods("GET")
+}
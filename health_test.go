@@ -0,0 +1,43 @@
+package harukap
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// hangingPlugin 健康检查忽略 ctx，直到 release 关闭才返回
+type hangingPlugin struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (p *hangingPlugin) HealthCheck(ctx context.Context) error {
+	p.calls.Add(1)
+	<-p.release
+	return nil
+}
+
+func TestRunHealthCheckSingleFlight(t *testing.T) {
+	engine := NewHarukaAppEngine()
+	plugin := &hangingPlugin{release: make(chan struct{})}
+	for i := 0; i < 3; i++ {
+		if err := engine.runHealthCheck(context.Background(), plugin, 10*time.Millisecond); err == nil {
+			t.Fatal("expected timeout")
+		}
+	}
+	if calls := plugin.calls.Load(); calls != 1 {
+		t.Fatalf("expected a single check in flight, got %d", calls)
+	}
+	close(plugin.release)
+	if err := engine.runHealthCheck(context.Background(), plugin, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.runHealthCheck(context.Background(), plugin, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if calls := plugin.calls.Load(); calls < 2 {
+		t.Fatalf("expected new checks after the hung one returned, got %d", calls)
+	}
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/allentom/haruka"
+	"github.com/allentom/harukap/commons"
+)
+
+const (
+	// APIKeyHeader 携带 API key 的请求头
+	APIKeyHeader = "X-API-Key"
+	// APIKeyScheme Authorization 头中 API key 的前缀
+	APIKeyScheme = "ApiKey "
+)
+
+var ErrAPIKeyPluginNotFound = NewCredentialError("api key auth plugin not found")
+
+// APIKeyAuthenticator 可选接口：AuthPlugin 实现后可以认证请求头中的 API key
+type APIKeyAuthenticator interface {
+	ParseAPIKey(key string) (commons.AuthUser, error)
+}
+
+// ParseAPIKeyHeader 读取 X-API-Key 请求头或 Authorization: ApiKey <key>
+func (m *AuthModule) ParseAPIKeyHeader(c *haruka.Context) string {
+	key := c.Request.Header.Get(APIKeyHeader)
+	if len(key) == 0 {
+		authorization := c.Request.Header.Get("Authorization")
+		if strings.HasPrefix(authorization, APIKeyScheme) {
+			key = strings.TrimPrefix(authorization, APIKeyScheme)
+		}
+	}
+	return strings.TrimSpace(key)
+}
+
+// ParseAPIKey 交给实现了 APIKeyAuthenticator 的 AuthPlugin 认证 API key
+func (m *AuthModule) ParseAPIKey(key string) (commons.AuthUser, error) {
+	for _, plugin := range m.Plugins {
+		if authenticator, ok := plugin.(APIKeyAuthenticator); ok {
+			return authenticator.ParseAPIKey(key)
+		}
+	}
+	return nil, ErrAPIKeyPluginNotFound
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// DefaultJWKSCacheTTL JWKS 默认缓存时间
+	DefaultJWKSCacheTTL = time.Hour
+	// jwksMinRefreshInterval 两次获取 JWKS 的最小间隔：遇到未知 kid 或上一次获取失败时，
+	// 在此间隔内不再访问 JWKS 服务，避免伪造 kid 或服务故障时每个请求都发起获取
+	jwksMinRefreshInterval = time.Minute
+)
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// JWKSKeyProvider 从 JWKS 地址获取公钥，按 CacheTTL 缓存，遇到未知 kid 时刷新以支持密钥轮换。
+// 同一时间只有一个调用访问 JWKS 服务，获取期间不持有锁
+type JWKSKeyProvider struct {
+	URL      string
+	CacheTTL time.Duration
+	Client   *http.Client
+	lock     sync.Mutex
+	keys     map[string]interface{}
+	// fetched 上一次成功获取的时间，attempted 上一次发起获取的时间
+	fetched   time.Time
+	attempted time.Time
+	// lastErr 上一次获取失败的错误，没有可用密钥时返回
+	lastErr error
+	// refreshing 获取期间不为空，获取完成时关闭
+	refreshing chan struct{}
+	now        func() time.Time
+}
+
+func NewJWKSKeyProvider(url string) *JWKSKeyProvider {
+	return &JWKSKeyProvider{
+		URL:      url,
+		CacheTTL: DefaultJWKSCacheTTL,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		now:      time.Now,
+	}
+}
+
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(value)
+}
+
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URL(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URL(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// fetch 获取并解析 JWKS，不访问缓存
+func (p *JWKSKeyProvider) fetch() (map[string]interface{}, error) {
+	response, err := p.Client.Get(p.URL)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks failed: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks failed: status %d", response.StatusCode)
+	}
+	var body struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&body)
+	if err != nil {
+		return nil, fmt.Errorf("decode jwks failed: %v", err)
+	}
+	keys := map[string]interface{}{}
+	for _, webKey := range body.Keys {
+		if webKey.Use != "" && webKey.Use != "sig" {
+			continue
+		}
+		key, err := webKey.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[webKey.Kid] = key
+	}
+	return keys, nil
+}
+
+// refresh 缓存过期或 force 为 true（未知 kid）时获取 JWKS，距离上一次获取不足 jwksMinRefreshInterval 时跳过。
+// 其他调用正在获取时等待其完成
+func (p *JWKSKeyProvider) refresh(force bool) {
+	p.lock.Lock()
+	if p.refreshing != nil {
+		refreshing := p.refreshing
+		p.lock.Unlock()
+		<-refreshing
+		return
+	}
+	now := p.now()
+	stale := p.keys == nil || now.Sub(p.fetched) > p.CacheTTL
+	if (!stale && !force) || (!p.attempted.IsZero() && now.Sub(p.attempted) < jwksMinRefreshInterval) {
+		p.lock.Unlock()
+		return
+	}
+	p.attempted = now
+	refreshing := make(chan struct{})
+	p.refreshing = refreshing
+	p.lock.Unlock()
+
+	keys, err := p.fetch()
+
+	p.lock.Lock()
+	// 获取失败时继续使用旧密钥
+	if err != nil {
+		p.lastErr = err
+	} else {
+		p.keys = keys
+		p.fetched = p.now()
+		p.lastErr = nil
+	}
+	p.refreshing = nil
+	p.lock.Unlock()
+	close(refreshing)
+}
+
+func (p *JWKSKeyProvider) lookup(kid string) (interface{}, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.keys == nil {
+		if p.lastErr != nil {
+			return nil, p.lastErr
+		}
+		return nil, errors.New("jwks not loaded")
+	}
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, nil
+		}
+	}
+	return p.keys[kid], nil
+}
+
+func (p *JWKSKeyProvider) Key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	p.refresh(false)
+	key, err := p.lookup(kid)
+	if err == nil && key == nil {
+		p.refresh(true)
+		key, err = p.lookup(kid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.New("no matching key in jwks")
+	}
+	return key, nil
+}
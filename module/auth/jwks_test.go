@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// jwksServer 提供可替换的 JWKS，fail 为 true 时返回 500
+type jwksServer struct {
+	*httptest.Server
+	mutex   sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fail    bool
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	server := &jwksServer{keys: map[string]*rsa.PrivateKey{}}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.fetches.Add(1)
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		if server.fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		keys := make([]*jsonWebKey, 0)
+		for kid, key := range server.keys {
+			keys = append(keys, &jsonWebKey{
+				Kid: kid,
+				Kty: "RSA",
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// rotate 替换为只包含 kid 的新密钥
+func (s *jwksServer) rotate(t *testing.T, kid string) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys = map[string]*rsa.PrivateKey{kid: key}
+	return key
+}
+
+func (s *jwksServer) setFail(fail bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fail = fail
+}
+
+func signKidToken(t *testing.T, key *rsa.PrivateKey, kid string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"iss": "jwks"})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func newJWKSVerifier(url string) (*TokenVerifier, *JWKSKeyProvider, *time.Time) {
+	now := time.Now()
+	provider := NewJWKSKeyProvider(url)
+	provider.now = func() time.Time {
+		return now
+	}
+	verifier := NewTokenVerifier()
+	verifier.SetIssuerKey("jwks", provider)
+	return verifier, provider, &now
+}
+
+func TestJWKSKeyRotation(t *testing.T) {
+	server := newJWKSServer(t)
+	first := server.rotate(t, "k1")
+	verifier, _, now := newJWKSVerifier(server.URL)
+	if _, err := verifier.Verify(signKidToken(t, first, "k1")); err != nil {
+		t.Fatal(err)
+	}
+	second := server.rotate(t, "k2")
+	// 刚获取过，未知 kid 不会立即刷新
+	if _, err := verifier.Verify(signKidToken(t, second, "k2")); err == nil {
+		t.Fatal("unknown kid should be rejected within min refresh interval")
+	}
+	if got := server.fetches.Load(); got != 1 {
+		t.Fatalf("unknown kid should not refetch within min refresh interval, got %d fetches", got)
+	}
+	*now = now.Add(2 * jwksMinRefreshInterval)
+	if _, err := verifier.Verify(signKidToken(t, second, "k2")); err != nil {
+		t.Fatalf("rotated key should be fetched, got %v", err)
+	}
+	if _, err := verifier.Verify(signKidToken(t, first, "k1")); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("removed key should be rejected, got %v", err)
+	}
+	if got := server.fetches.Load(); got != 2 {
+		t.Fatalf("expected 2 fetches, got %d", got)
+	}
+}
+
+func TestJWKSFailureBackoff(t *testing.T) {
+	server := newJWKSServer(t)
+	key := server.rotate(t, "k1")
+	server.setFail(true)
+	verifier, provider, now := newJWKSVerifier(server.URL)
+	token := signKidToken(t, key, "k1")
+	for i := 0; i < 5; i++ {
+		if _, err := verifier.Verify(token); err == nil {
+			t.Fatal("verify should fail when jwks is unavailable")
+		}
+	}
+	if got := server.fetches.Load(); got != 1 {
+		t.Fatalf("failed fetch should back off, got %d fetches", got)
+	}
+	server.setFail(false)
+	*now = now.Add(2 * jwksMinRefreshInterval)
+	if _, err := verifier.Verify(token); err != nil {
+		t.Fatalf("fetch should be retried after backoff, got %v", err)
+	}
+
+	// 缓存过期后获取失败，继续使用旧密钥
+	server.setFail(true)
+	*now = now.Add(provider.CacheTTL + time.Minute)
+	if _, err := verifier.Verify(token); err != nil {
+		t.Fatalf("cached keys should be used when refresh fails, got %v", err)
+	}
+	if _, err := verifier.Verify(token); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.fetches.Load(); got != 3 {
+		t.Fatalf("expected 3 fetches, got %d", got)
+	}
+}
+
+func TestJWKSConcurrentFetch(t *testing.T) {
+	server := newJWKSServer(t)
+	key := server.rotate(t, "k1")
+	verifier, _, _ := newJWKSVerifier(server.URL)
+	token := signKidToken(t, key, "k1")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := verifier.Verify(token); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := server.fetches.Load(); got != 1 {
+		t.Fatalf("concurrent requests should share one fetch, got %d", got)
+	}
+}
+
+func TestReloadVerifierKeepsJWKSCache(t *testing.T) {
+	server := newJWKSServer(t)
+	key := server.rotate(t, "k1")
+	module := newTestAuthModule(t, fmt.Sprintf(`
+auth:
+  jwks:
+    type: jwks
+    jwt:
+      jwks: %s
+`, server.URL))
+	token := signKidToken(t, key, "k1")
+	if _, err := module.Verifier().Verify(token); err != nil {
+		t.Fatal(err)
+	}
+	if err := module.reloadVerifier(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := module.Verifier().Verify(token); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.fetches.Load(); got != 1 {
+		t.Fatalf("jwks cache should survive verifier rebuild, got %d fetches", got)
+	}
+}
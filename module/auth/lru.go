@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/allentom/harukap/commons"
+)
+
+type lruEntry struct {
+	key       string
+	user      commons.AuthUser
+	expiresAt time.Time
+}
+
+// userLRU TokenStoreManager 的内存缓存，避免每次请求都反序列化
+type userLRU struct {
+	size    int
+	items   map[string]*list.Element
+	entries *list.List
+	// revoked 已吊销的键及吊销到期时间（零值表示永久），防止吊销前读出的用户在吊销后写回缓存
+	revoked map[string]time.Time
+	lock    sync.Mutex
+}
+
+func newUserLRU(size int) *userLRU {
+	return &userLRU{
+		size:    size,
+		items:   map[string]*list.Element{},
+		entries: list.New(),
+		revoked: map[string]time.Time{},
+	}
+}
+
+func (c *userLRU) isRevoked(key string, now time.Time) bool {
+	until, ok := c.revoked[key]
+	return ok && !isExpired(until, now)
+}
+
+// get 过期的条目会被移除
+func (c *userLRU) get(key string, now time.Time) (commons.AuthUser, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !now.Before(entry.expiresAt) || c.isRevoked(key, now) {
+		c.entries.Remove(element)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.entries.MoveToFront(element)
+	return entry.user, true
+}
+
+// put 已吊销的键不会写入
+func (c *userLRU) put(key string, user commons.AuthUser, expiresAt time.Time, now time.Time) {
+	if c.size <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.isRevoked(key, now) {
+		return
+	}
+	if element, ok := c.items[key]; ok {
+		element.Value = &lruEntry{key: key, user: user, expiresAt: expiresAt}
+		c.entries.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.entries.PushFront(&lruEntry{key: key, user: user, expiresAt: expiresAt})
+	for c.entries.Len() > c.size {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *userLRU) remove(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.items[key]; ok {
+		c.entries.Remove(element)
+		delete(c.items, key)
+	}
+}
+
+// revoke 移除条目，并在 until 之前拒绝写入该键，until 为零值时永久拒绝
+func (c *userLRU) revoke(key string, until time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.items[key]; ok {
+		c.entries.Remove(element)
+		delete(c.items, key)
+	}
+	c.revoked[key] = until
+}
+
+// compact 删除已到期的吊销标记
+func (c *userLRU) compact(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key, until := range c.revoked {
+		if isExpired(until, now) {
+			delete(c.revoked, key)
+		}
+	}
+}
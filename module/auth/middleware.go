@@ -16,8 +16,19 @@ func (m AuthMiddleware) OnRequest(c *haruka.Context) {
 	if m.RequestFilter != nil && !m.RequestFilter(c) {
 		return
 	}
+	// API key 可以随时吊销，不经过 token 缓存
+	apiKey := m.Module.ParseAPIKeyHeader(c)
+	if len(apiKey) > 0 {
+		user, err := m.Module.ParseAPIKey(apiKey)
+		if err != nil {
+			m.OnError(c, err)
+			return
+		}
+		c.Param["claim"] = user
+		return
+	}
 	jwtToken := m.Module.ParseAuthHeader(c)
-	if m.Module.Config.EnableAnonymous && len(jwtToken) == 0 {
+	if m.Module.AnonymousEnabled() && len(jwtToken) == 0 {
 		return
 	}
 	var claims commons.AuthUser
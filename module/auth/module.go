@@ -2,6 +2,10 @@ package auth
 
 import (
 	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
 	"github.com/allentom/haruka"
 	"github.com/allentom/harukap"
 	"github.com/allentom/harukap/config"
@@ -14,8 +18,58 @@ type AuthModule struct {
 	Plugins        []harukap.AuthPlugin
 	AuthMiddleware AuthMiddleware
 	ConfigProvider *config.Provider
-	Config         AuthModuleConfig
-	CacheStore     *TokenStoreManager
+	// Deprecated: 只在 InitModule 时设置，配置重新加载后不再更新，使用 AnonymousEnabled 读取当前值
+	Config     AuthModuleConfig
+	CacheStore *TokenStoreManager
+	// mutex 保护代码中设置的签发者与 Verifier 的重建
+	mutex    sync.Mutex
+	config   atomic.Pointer[AuthModuleConfig]
+	verifier atomic.Pointer[TokenVerifier]
+	// issuerKeys unverifiedIssuers 代码中设置的签发者，重建 Verifier 时保留
+	issuerKeys        map[string]KeyProvider
+	unverifiedIssuers map[string]bool
+	// jwksProviders 按地址复用 JWKS，重建 Verifier 时保留已缓存的密钥
+	jwksProviders map[string]*JWKSKeyProvider
+}
+
+// AuthPluginWithIssuerKey 可选接口：自行签发 token 的 AuthPlugin 提供校验密钥，
+// InitModule 时按 TokenTypeName 通过 SetIssuerKey 注册
+type AuthPluginWithIssuerKey interface {
+	IssuerKey() KeyProvider
+}
+
+// AuthPluginWithUnverifiedIssuer 可选接口：token 由外部服务签发、在 GetAuthUserByToken 中远程校验的 AuthPlugin，
+// 返回 true 时 InitModule 按 TokenTypeName 通过 SetIssuerUnverified 注册，框架只校验时间声明。
+// auth.<name>.jwt 中为该签发者配置了密钥时仍以密钥校验签名
+type AuthPluginWithUnverifiedIssuer interface {
+	IssuerUnverified() bool
+}
+
+// NewAuthModule 使用引擎的配置创建 AuthModule，设置为引擎的 AuthModule 用于 gRPC 认证，并在引擎关闭时调用 Close
+func NewAuthModule(e *harukap.HarukaAppEngine, plugins ...harukap.AuthPlugin) *AuthModule {
+	module := &AuthModule{
+		Plugins:        plugins,
+		ConfigProvider: e.ConfigProvider,
+	}
+	e.AuthModule = module
+	e.AddShutdownHook(module.Close)
+	return module
+}
+
+// FindConfigPrefix 按名称顺序查找第一个 type 为 authType 的 auth.<name> 节点，返回 auth.<name>
+func FindConfigPrefix(provider *config.Provider, authType string) (string, error) {
+	configer := provider.Config()
+	keys := make([]string, 0)
+	for key := range configer.GetStringMap("auth") {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if configer.GetString(fmt.Sprintf("auth.%s.type", key)) == authType {
+			return fmt.Sprintf("auth.%s", key), nil
+		}
+	}
+	return "", fmt.Errorf("no auth config with type %s found", authType)
 }
 
 func (m *AuthModule) AddCacheStore(convert Serializer) {
@@ -24,9 +78,9 @@ func (m *AuthModule) AddCacheStore(convert Serializer) {
 		module:     m,
 	}
 }
-func (m *AuthModule) InitModule() error {
+func (m *AuthModule) loadConfig() AuthModuleConfig {
 	authConfig := AuthModuleConfig{}
-	configer := m.ConfigProvider.Manager
+	configer := m.ConfigProvider.Config()
 	for key := range configer.GetStringMap("auth") {
 		configType := configer.GetString(fmt.Sprintf("auth.%s.type", key))
 		enable := configer.GetBool(fmt.Sprintf("auth.%s.enable", key))
@@ -34,12 +88,42 @@ func (m *AuthModule) InitModule() error {
 			authConfig.EnableAnonymous = true
 		}
 	}
+	return authConfig
+}
+
+func (m *AuthModule) InitModule() error {
+	authConfig := m.loadConfig()
 	m.Config = authConfig
+	m.config.Store(&authConfig)
+	for _, plugin := range m.Plugins {
+		if keyPlugin, ok := plugin.(AuthPluginWithIssuerKey); ok {
+			m.SetIssuerKey(plugin.TokenTypeName(), keyPlugin.IssuerKey())
+		}
+		if remotePlugin, ok := plugin.(AuthPluginWithUnverifiedIssuer); ok && remotePlugin.IssuerUnverified() {
+			m.SetIssuerUnverified(plugin.TokenTypeName())
+		}
+	}
+	err := m.reloadVerifier()
+	if err != nil {
+		return err
+	}
 	m.AuthMiddleware = AuthMiddleware{
 		Module: m,
 	}
+	// auth 配置变化时重新计算是否允许匿名访问并重建 token 校验
+	m.ConfigProvider.Subscribe("auth", func(change *config.ConfigChange) error {
+		if err := m.reloadVerifier(); err != nil {
+			return err
+		}
+		authConfig := m.loadConfig()
+		m.config.Store(&authConfig)
+		return nil
+	})
+	m.ConfigProvider.Subscribe("jwt", func(change *config.ConfigChange) error {
+		return m.reloadVerifier()
+	})
 	if m.CacheStore != nil {
-		err := m.CacheStore.Init()
+		err = m.CacheStore.Init()
 		if err != nil {
 			return err
 		}
@@ -47,6 +131,47 @@ func (m *AuthModule) InitModule() error {
 	return nil
 }
 
+// reloadVerifier 按配置重建 Verifier 并整体替换，保留代码中设置的签发者
+func (m *AuthModule) reloadVerifier() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	verifier, jwksProviders, err := m.loadVerifier()
+	if err != nil {
+		return err
+	}
+	for issuer, provider := range m.issuerKeys {
+		verifier.SetIssuerKey(issuer, provider)
+	}
+	for issuer := range m.unverifiedIssuers {
+		verifier.SetIssuerUnverified(issuer, true)
+	}
+	m.jwksProviders = jwksProviders
+	m.verifier.Store(verifier)
+	return nil
+}
+
+// Verifier 当前的 token 校验器，配置变化时整体替换，InitModule 之前为空
+func (m *AuthModule) Verifier() *TokenVerifier {
+	return m.verifier.Load()
+}
+
+// AnonymousEnabled 是否允许匿名访问，供 rpc 认证拦截器使用
+func (m *AuthModule) AnonymousEnabled() bool {
+	if authConfig := m.config.Load(); authConfig != nil {
+		return authConfig.EnableAnonymous
+	}
+	return m.Config.EnableAnonymous
+}
+
+// Close 停止 token 缓存的清理并关闭缓存文件，NewAuthModule 创建的模块会在引擎关闭时自动调用，
+// 直接构造 AuthModule 时需要通过 HarukaAppEngine.AddShutdownHook 注册
+func (m *AuthModule) Close() error {
+	if m.CacheStore != nil {
+		return m.CacheStore.Close()
+	}
+	return nil
+}
+
 func (m *AuthModule) GetAuthPluginByName(name string) harukap.AuthPlugin {
 	for _, plugin := range m.Plugins {
 		if plugin.TokenTypeName() == name {
@@ -58,7 +183,7 @@ func (m *AuthModule) GetAuthPluginByName(name string) harukap.AuthPlugin {
 
 func (m *AuthModule) GetAuthConfig() ([]interface{}, error) {
 	authMaps := make([]interface{}, 0)
-	configManager := m.ConfigProvider.Manager
+	configManager := m.ConfigProvider.Config()
 	for key := range configManager.GetStringMap("auth") {
 		authType := configManager.GetString(fmt.Sprintf("auth.%s.type", key))
 		enable := configManager.GetBool(fmt.Sprintf("auth.%s.enable", key))
@@ -0,0 +1,349 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/allentom/haruka"
+	"github.com/allentom/harukap/commons"
+	"github.com/allentom/harukap/config"
+	"github.com/allentom/harukap/module/errorhandler"
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// PermissionAll 拥有所有权限，也可以用 task:* 表示 task: 开头的所有权限
+	PermissionAll = "*"
+	// ErrorCodeUnauthorized 未认证时 errorhandler 返回的 code
+	ErrorCodeUnauthorized = "401"
+	// ErrorCodeForbidden 没有权限时 errorhandler 返回的 code
+	ErrorCodeForbidden = "403"
+)
+
+// UserWithRoles 可选接口：AuthUser 提供所属角色
+type UserWithRoles interface {
+	GetRoles() []string
+}
+
+// UserWithPermissions 可选接口：AuthUser 直接拥有的权限，与角色的权限合并
+type UserWithPermissions interface {
+	GetPermissions() []string
+}
+
+// UserWithId 可选接口：AuthUser 提供用户标识，用于所有者检查
+type UserWithId interface {
+	GetUserId() string
+}
+
+// UnauthorizedError 未携带有效凭证，返回 401
+type UnauthorizedError struct {
+	Err error
+}
+
+func (e *UnauthorizedError) Error() string {
+	if e.Err == nil {
+		return "unauthorized"
+	}
+	return fmt.Sprintf("unauthorized: %v", e.Err)
+}
+
+func (e *UnauthorizedError) Unwrap() error {
+	return e.Err
+}
+
+// CredentialError 凭证无效，例如过期、吊销或签名错误。OnAuthError 将其作为 401 输出，
+// 其他错误（例如数据库故障）按原样交给 errorhandler
+type CredentialError struct {
+	Message string
+}
+
+func (e *CredentialError) Error() string {
+	return e.Message
+}
+
+// NewCredentialError AuthPlugin 用它声明自己的凭证错误，返回值可以直接作为哨兵错误比较
+func NewCredentialError(message string) error {
+	return &CredentialError{Message: message}
+}
+
+// IsCredentialError 错误链中是否包含 CredentialError 或 jwt 的解析错误
+func IsCredentialError(err error) bool {
+	var credentialErr *CredentialError
+	var validationErr *jwt.ValidationError
+	return errors.As(err, &credentialErr) || errors.As(err, &validationErr)
+}
+
+// ForbiddenError 已认证但没有权限，返回 403
+type ForbiddenError struct {
+	Reason string
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("forbidden: %s", e.Reason)
+}
+
+// PolicyStore 提供角色拥有的权限
+type PolicyStore interface {
+	RolePermissions(role string) ([]string, error)
+}
+
+// MemoryPolicyStore 在代码中配置角色权限
+type MemoryPolicyStore struct {
+	roles map[string][]string
+	lock  sync.RWMutex
+}
+
+func NewMemoryPolicyStore() *MemoryPolicyStore {
+	return &MemoryPolicyStore{
+		roles: map[string][]string{},
+	}
+}
+
+// Grant 为角色添加权限
+func (s *MemoryPolicyStore) Grant(role string, permissions ...string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.roles[role] = append(s.roles[role], permissions...)
+}
+
+func (s *MemoryPolicyStore) RolePermissions(role string) ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return append([]string{}, s.roles[role]...), nil
+}
+
+// ConfigPolicyStore 从 rbac.roles.<role> 读取角色权限，配置热更新后立即生效
+type ConfigPolicyStore struct {
+	Provider *config.Provider
+}
+
+// ConfigPolicyStoreSchema ConfigPolicyStore 读取的配置，可通过 HarukaAppEngine.RegisterConfigSchema 注册
+var ConfigPolicyStoreSchema = &config.Schema{
+	Prefix:      "rbac",
+	Description: "role permissions",
+	Fields: []config.Field{
+		{Key: "roles", Type: config.FieldTypeMap, Description: "permissions of each role, e.g. rbac.roles.admin: [\"*\"]"},
+	},
+}
+
+func NewConfigPolicyStore(provider *config.Provider) *ConfigPolicyStore {
+	return &ConfigPolicyStore{Provider: provider}
+}
+
+func (s *ConfigPolicyStore) RolePermissions(role string) ([]string, error) {
+	return s.Provider.Config().GetStringSlice(fmt.Sprintf("rbac.roles.%s", role)), nil
+}
+
+// Requirement 路由的访问要求，不满足时返回 ForbiddenError
+type Requirement func(c *haruka.Context, user *Principal) error
+
+// Principal 当前请求的用户及其展开后的角色与权限
+type Principal struct {
+	User        commons.AuthUser
+	Roles       []string
+	Permissions []string
+}
+
+// HasRole 是否拥有指定角色
+func (p *Principal) HasRole(role string) bool {
+	for _, item := range p.Roles {
+		if item == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission 是否拥有指定权限，支持 * 与 prefix:* 通配
+func (p *Principal) HasPermission(permission string) bool {
+	for _, item := range p.Permissions {
+		if matchPermission(item, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// UserId 用户标识，用户未实现 UserWithId 时为空
+func (p *Principal) UserId() string {
+	if user, ok := p.User.(UserWithId); ok {
+		return user.GetUserId()
+	}
+	return ""
+}
+
+func matchPermission(granted string, permission string) bool {
+	if granted == PermissionAll || granted == permission {
+		return true
+	}
+	if strings.HasSuffix(granted, ":*") {
+		return strings.HasPrefix(permission, strings.TrimSuffix(granted, "*"))
+	}
+	return false
+}
+
+// RequireRole 要求拥有任一角色
+func RequireRole(roles ...string) Requirement {
+	return func(c *haruka.Context, user *Principal) error {
+		for _, role := range roles {
+			if user.HasRole(role) {
+				return nil
+			}
+		}
+		return &ForbiddenError{Reason: fmt.Sprintf("require role %s", strings.Join(roles, " or "))}
+	}
+}
+
+// RequirePermission 要求拥有全部权限
+func RequirePermission(permissions ...string) Requirement {
+	return func(c *haruka.Context, user *Principal) error {
+		for _, permission := range permissions {
+			if !user.HasPermission(permission) {
+				return &ForbiddenError{Reason: fmt.Sprintf("require permission %s", permission)}
+			}
+		}
+		return nil
+	}
+}
+
+// RequireOwner 要求当前用户是资源的所有者，ownerOf 从请求中找出资源所有者
+func RequireOwner(ownerOf func(c *haruka.Context) (string, error)) Requirement {
+	return func(c *haruka.Context, user *Principal) error {
+		owner, err := ownerOf(c)
+		if err != nil {
+			return err
+		}
+		userId := user.UserId()
+		if userId == "" || userId != owner {
+			return &ForbiddenError{Reason: "not the owner"}
+		}
+		return nil
+	}
+}
+
+// AnyOf 满足任一要求即可，例如所有者或管理员
+func AnyOf(requirements ...Requirement) Requirement {
+	return func(c *haruka.Context, user *Principal) error {
+		var lastErr error = &ForbiddenError{Reason: "no requirement matched"}
+		for _, requirement := range requirements {
+			err := requirement(c, user)
+			if err == nil {
+				return nil
+			}
+			lastErr = err
+		}
+		return lastErr
+	}
+}
+
+// Authorizer 在 AuthMiddleware 写入 claim 之后检查路由的访问要求，
+// 未认证返回 401，没有权限返回 403，错误通过 errorhandler 模块输出
+type Authorizer struct {
+	Store  PolicyStore
+	Errors *errorhandler.ErrorModule
+}
+
+// NewAuthorizer store 为空时只使用用户自身的权限
+func NewAuthorizer(store PolicyStore) *Authorizer {
+	authorizer := &Authorizer{Store: store}
+	authorizer.UseErrorModule(errorhandler.NewErrorModule())
+	return authorizer
+}
+
+// UseErrorModule 使用应用的 errorhandler 模块，并注册 401 与 403 的处理
+func (a *Authorizer) UseErrorModule(module *errorhandler.ErrorModule) {
+	RegisterErrorHandlers(module)
+	a.Errors = module
+}
+
+// RegisterErrorHandlers 注册 UnauthorizedError 与 ForbiddenError 的响应，已注册的类型不会重复注册
+func RegisterErrorHandlers(module *errorhandler.ErrorModule) {
+	handlers := []errorhandler.ErrorHandler{
+		{Match: &UnauthorizedError{}, Code: ErrorCodeUnauthorized, Status: http.StatusUnauthorized},
+		{Match: &ForbiddenError{}, Code: ErrorCodeForbidden, Status: http.StatusForbidden},
+	}
+	for _, handler := range handlers {
+		if module.GetHandlerByType(handler.Match.(error)) != nil {
+			continue
+		}
+		module.RegisterHandler(handler)
+	}
+}
+
+// Principal 展开用户的角色与权限，未认证时返回 UnauthorizedError
+func (a *Authorizer) Principal(c *haruka.Context) (*Principal, error) {
+	user := c.Param["claim"]
+	if user == nil {
+		return nil, &UnauthorizedError{}
+	}
+	principal := &Principal{User: user}
+	if withRoles, ok := user.(UserWithRoles); ok {
+		principal.Roles = withRoles.GetRoles()
+	}
+	if withPermissions, ok := user.(UserWithPermissions); ok {
+		principal.Permissions = append(principal.Permissions, withPermissions.GetPermissions()...)
+	}
+	if a.Store != nil {
+		for _, role := range principal.Roles {
+			permissions, err := a.Store.RolePermissions(role)
+			if err != nil {
+				return nil, err
+			}
+			principal.Permissions = append(principal.Permissions, permissions...)
+		}
+	}
+	return principal, nil
+}
+
+// RaiseError 输出认证或授权错误。errorhandler 按错误的具体类型匹配，
+// 被包装的 UnauthorizedError 与 ForbiddenError 会先取出再输出
+func (a *Authorizer) RaiseError(c *haruka.Context, err error) {
+	var unauthorized *UnauthorizedError
+	var forbidden *ForbiddenError
+	if errors.As(err, &unauthorized) {
+		err = unauthorized
+	} else if errors.As(err, &forbidden) {
+		err = forbidden
+	}
+	c.Abort()
+	a.Errors.RaiseHttpError(c, err)
+}
+
+// OnAuthError 可作为 AuthMiddleware.OnError，凭证无效时返回 401，其他错误按原样输出
+func (a *Authorizer) OnAuthError(c *haruka.Context, err error) {
+	var unauthorized *UnauthorizedError
+	if !errors.As(err, &unauthorized) && IsCredentialError(err) {
+		err = &UnauthorizedError{Err: err}
+	}
+	a.RaiseError(c, err)
+}
+
+// Check 检查当前请求是否满足全部要求
+func (a *Authorizer) Check(c *haruka.Context, requirements ...Requirement) error {
+	principal, err := a.Principal(c)
+	if err != nil {
+		return err
+	}
+	for _, requirement := range requirements {
+		err = requirement(c, principal)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Protect 包装路由处理函数，满足全部要求后才会执行
+func (a *Authorizer) Protect(handler haruka.RequestHandler, requirements ...Requirement) haruka.RequestHandler {
+	return func(c *haruka.Context) {
+		err := a.Check(c, requirements...)
+		if err != nil {
+			a.RaiseError(c, err)
+			return
+		}
+		handler(c)
+	}
+}
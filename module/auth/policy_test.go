@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/allentom/haruka"
+	"github.com/allentom/harukap/module/errorhandler"
+)
+
+type policyUser struct {
+	id          string
+	roles       []string
+	permissions []string
+}
+
+func (u *policyUser) GetUserId() string {
+	return u.id
+}
+
+func (u *policyUser) GetRoles() []string {
+	return u.roles
+}
+
+func (u *policyUser) GetPermissions() []string {
+	return u.permissions
+}
+
+func newPolicyContext(claim interface{}) (*haruka.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	context := &haruka.Context{
+		Request: httptest.NewRequest("GET", "/", nil),
+		Writer:  recorder,
+		Param:   map[string]interface{}{},
+	}
+	if claim != nil {
+		context.Param["claim"] = claim
+	}
+	return context, recorder
+}
+
+func TestMatchPermission(t *testing.T) {
+	cases := []struct {
+		granted    string
+		permission string
+		match      bool
+	}{
+		{"*", "task:read", true},
+		{"task:read", "task:read", true},
+		{"task:read", "task:write", false},
+		{"task:*", "task:read", true},
+		{"task:*", "task:sub:read", true},
+		{"task:*", "tasks:read", false},
+		{"task:*", "task", false},
+		{"task", "task:read", false},
+	}
+	for _, c := range cases {
+		if got := matchPermission(c.granted, c.permission); got != c.match {
+			t.Errorf("matchPermission(%q, %q) = %v, expected %v", c.granted, c.permission, got, c.match)
+		}
+	}
+}
+
+func TestPrincipalExpandsRoles(t *testing.T) {
+	store := NewMemoryPolicyStore()
+	store.Grant("editor", "post:*")
+	store.Grant("viewer", "comment:read")
+	authorizer := NewAuthorizer(store)
+	context, _ := newPolicyContext(&policyUser{id: "bob", roles: []string{"editor", "viewer"}, permissions: []string{"profile:write"}})
+
+	principal, err := authorizer.Principal(context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, permission := range []string{"post:delete", "comment:read", "profile:write"} {
+		if !principal.HasPermission(permission) {
+			t.Errorf("expected permission %s", permission)
+		}
+	}
+	if principal.HasPermission("comment:write") || !principal.HasRole("viewer") || principal.UserId() != "bob" {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+
+	context, _ = newPolicyContext(nil)
+	if _, err = authorizer.Principal(context); !errors.As(err, new(*UnauthorizedError)) {
+		t.Fatalf("expected unauthorized, got %v", err)
+	}
+}
+
+type failingPolicyStore struct{}
+
+func (s failingPolicyStore) RolePermissions(role string) ([]string, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func TestAuthorizerStatus(t *testing.T) {
+	authorizer := NewAuthorizer(nil)
+	viewer := &policyUser{id: "bob", permissions: []string{"task:read"}}
+	wrapForbidden := func(c *haruka.Context, user *Principal) error {
+		return fmt.Errorf("check owner: %w", &ForbiddenError{Reason: "not the owner"})
+	}
+	cases := []struct {
+		name         string
+		authorizer   *Authorizer
+		claim        interface{}
+		requirements []Requirement
+		status       int
+	}{
+		{"allowed", authorizer, viewer, []Requirement{RequirePermission("task:read")}, http.StatusOK},
+		{"anonymous", authorizer, nil, nil, http.StatusUnauthorized},
+		{"missing permission", authorizer, viewer, []Requirement{RequirePermission("task:write")}, http.StatusForbidden},
+		{"wrapped forbidden", authorizer, viewer, []Requirement{wrapForbidden}, http.StatusForbidden},
+		{"store failure", NewAuthorizer(failingPolicyStore{}), &policyUser{roles: []string{"admin"}}, nil, http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		context, recorder := newPolicyContext(c.claim)
+		c.authorizer.Protect(func(c *haruka.Context) {
+			c.Writer.WriteHeader(http.StatusOK)
+		}, c.requirements...)(context)
+		if recorder.Code != c.status {
+			t.Errorf("%s: expected %d, got %d %s", c.name, c.status, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestOnAuthError(t *testing.T) {
+	authorizer := NewAuthorizer(nil)
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{ErrTokenExpired, http.StatusUnauthorized},
+		{fmt.Errorf("%w: issuer", ErrUnknownIssuer), http.StatusUnauthorized},
+		{NewCredentialError("plugin credential"), http.StatusUnauthorized},
+		{&UnauthorizedError{}, http.StatusUnauthorized},
+		{errors.New("database is locked"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		context, recorder := newPolicyContext(nil)
+		authorizer.OnAuthError(context, c.err)
+		if recorder.Code != c.status {
+			t.Errorf("%v: expected %d, got %d", c.err, c.status, recorder.Code)
+		}
+	}
+}
+
+func TestRegisterErrorHandlersOnce(t *testing.T) {
+	module := errorhandler.NewErrorModule()
+	authorizer := NewAuthorizer(nil)
+	authorizer.UseErrorModule(module)
+	RegisterErrorHandlers(module)
+	if len(module.Handlers) != 2 {
+		t.Fatalf("expected 2 handlers, got %d", len(module.Handlers))
+	}
+}
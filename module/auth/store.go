@@ -1,64 +1,400 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/allentom/haruka"
 	"github.com/allentom/harukap/commons"
-	"github.com/boltdb/bolt"
+	"github.com/allentom/harukap/config"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/project-xpolaris/youplustoolkit/youlink"
+	bolt "go.etcd.io/bbolt"
 )
 
 var (
-	storeBucket = "tokens"
+	// legacyStoreBucket 旧版本没有过期时间的缓存，初始化时删除
+	legacyStoreBucket = "tokens"
+	storeBucket       = "token_entries"
+	revokedBucket     = "revoked_tokens"
+)
+
+const (
+	DefaultTokenStorePath       = "token.db"
+	DefaultTokenStoreTTL        = time.Hour
+	DefaultTokenCompactInterval = 10 * time.Minute
+	DefaultTokenMemorySize      = 1024
 )
 
+var ErrTokenRevoked = NewCredentialError("token revoked")
+
+// TokenStoreSchema TokenStoreManager 读取的配置，可通过 HarukaAppEngine.RegisterConfigSchema 注册
+var TokenStoreSchema = &config.Schema{
+	Prefix:      "tokenStore",
+	Description: "auth token cache",
+	Fields: []config.Field{
+		{Key: "path", Type: config.FieldTypeString, Default: DefaultTokenStorePath, Description: "bolt file of token cache"},
+		{Key: "ttl", Type: config.FieldTypeInt, Default: int(DefaultTokenStoreTTL.Seconds()), Description: "max seconds a user is cached, tokens expiring earlier are evicted at exp"},
+		{Key: "compactInterval", Type: config.FieldTypeInt, Default: int(DefaultTokenCompactInterval.Seconds()), Description: "seconds between removing expired entries, 0 to disable"},
+		{Key: "memorySize", Type: config.FieldTypeInt, Default: DefaultTokenMemorySize, Description: "entries kept in memory lru, 0 to disable"},
+	},
+}
+
+// TokenStoreManager 缓存 token 解析出的用户，条目在 token 的 exp 或 TTL 到期后失效。
+// bolt 中以 token 的 sha256 为键，前面有一层内存 LRU
 type TokenStoreManager struct {
 	DB         *bolt.DB
 	Serializer Serializer
-	module     *AuthModule
+	// Path bolt 文件路径，为空时读取 tokenStore.path
+	Path string
+	// TTL 缓存的最长时间，token 没有 exp 时也使用该时间
+	TTL time.Duration
+	// CompactInterval 清理过期条目的间隔，小于 0 时不清理
+	CompactInterval time.Duration
+	// MemorySize 内存 LRU 的条目数，小于 0 时不使用
+	MemorySize    int
+	RevokeHandler haruka.RequestHandler
+	module        *AuthModule
+	memory        *userLRU
+	now           func() time.Time
+	stop          chan struct{}
+	wg            sync.WaitGroup
 }
 type Serializer interface {
 	Serialize(data interface{}) ([]byte, error)
 	Deserialize(raw []byte) (commons.AuthUser, error)
 }
 
+func (m *TokenStoreManager) loadConfig() {
+	if m.module == nil || m.module.ConfigProvider == nil {
+		return
+	}
+	configer := m.module.ConfigProvider.Config()
+	if m.Path == "" {
+		m.Path = configer.GetString("tokenStore.path")
+	}
+	if m.TTL == 0 && configer.IsSet("tokenStore.ttl") {
+		m.TTL = time.Duration(configer.GetInt("tokenStore.ttl")) * time.Second
+	}
+	if m.CompactInterval == 0 && configer.IsSet("tokenStore.compactInterval") {
+		m.CompactInterval = time.Duration(configer.GetInt("tokenStore.compactInterval")) * time.Second
+		if m.CompactInterval == 0 {
+			m.CompactInterval = -1
+		}
+	}
+	if m.MemorySize == 0 && configer.IsSet("tokenStore.memorySize") {
+		m.MemorySize = configer.GetInt("tokenStore.memorySize")
+		if m.MemorySize == 0 {
+			m.MemorySize = -1
+		}
+	}
+}
+
 func (m *TokenStoreManager) Init() error {
-	db, err := bolt.Open("token.db", 0600, nil)
+	m.loadConfig()
+	if m.Path == "" {
+		m.Path = DefaultTokenStorePath
+	}
+	if m.TTL == 0 {
+		m.TTL = DefaultTokenStoreTTL
+	}
+	if m.CompactInterval == 0 {
+		m.CompactInterval = DefaultTokenCompactInterval
+	}
+	if m.MemorySize == 0 {
+		m.MemorySize = DefaultTokenMemorySize
+	}
+	if m.now == nil {
+		m.now = time.Now
+	}
+	m.memory = newUserLRU(m.MemorySize)
+	db, err := bolt.Open(m.Path, 0600, &bolt.Options{Timeout: 5 * time.Second})
 	if err != nil {
 		return err
 	}
 	m.DB = db
 	err = m.DB.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(legacyStoreBucket)) != nil {
+			err := tx.DeleteBucket([]byte(legacyStoreBucket))
+			if err != nil {
+				return err
+			}
+		}
 		_, err := tx.CreateBucketIfNotExists([]byte(storeBucket))
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(revokedBucket))
 		return err
 	})
 	if err != nil {
 		return err
 	}
+	m.RevokeHandler = m.revokeHandler
+	if m.CompactInterval > 0 {
+		m.stop = make(chan struct{})
+		m.wg.Add(1)
+		go m.runCompaction()
+	}
 	return nil
 }
 
+func tokenKey(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return []byte(hex.EncodeToString(sum[:]))
+}
+
+// tokenExpiry 读取 token 的 exp，不是 jwt 或没有 exp 时返回零值
+func tokenExpiry(token string) time.Time {
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return time.Time{}
+	}
+	switch exp := parsed.Claims.(jwt.MapClaims)["exp"].(type) {
+	case float64:
+		return time.Unix(int64(exp), 0)
+	case int64:
+		return time.Unix(exp, 0)
+	}
+	return time.Time{}
+}
+
+// entryExpiry 取 token exp 与 TTL 中较早的时间
+func (m *TokenStoreManager) entryExpiry(token string, now time.Time) time.Time {
+	expiresAt := now.Add(m.TTL)
+	if exp := tokenExpiry(token); !exp.IsZero() && exp.Before(expiresAt) {
+		return exp
+	}
+	return expiresAt
+}
+
+// encodeEntry 前 8 字节为过期时间的 unix 秒，为 0 时不过期
+func encodeEntry(expiresAt time.Time, data []byte) []byte {
+	raw := make([]byte, 8+len(data))
+	if !expiresAt.IsZero() {
+		binary.BigEndian.PutUint64(raw, uint64(expiresAt.Unix()))
+	}
+	copy(raw[8:], data)
+	return raw
+}
+
+func decodeEntry(raw []byte) (time.Time, []byte, bool) {
+	if len(raw) < 8 {
+		return time.Time{}, nil, false
+	}
+	expiresAt := time.Time{}
+	if seconds := binary.BigEndian.Uint64(raw[:8]); seconds > 0 {
+		expiresAt = time.Unix(int64(seconds), 0)
+	}
+	return expiresAt, raw[8:], true
+}
+
+func isExpired(expiresAt time.Time, now time.Time) bool {
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
+
 func (m *TokenStoreManager) GetUserByToken(token string) (commons.AuthUser, error) {
+	key := tokenKey(token)
+	now := m.now()
+	if user, ok := m.memory.get(string(key), now); ok {
+		return user, nil
+	}
 	var auth commons.AuthUser
-	var err error
+	var expiresAt time.Time
+	err := m.DB.View(func(tx *bolt.Tx) error {
+		if isRevokedIn(tx, key, now) {
+			return ErrTokenRevoked
+		}
+		raw := tx.Bucket([]byte(storeBucket)).Get(key)
+		entryExpiresAt, data, ok := decodeEntry(raw)
+		if !ok || isExpired(entryExpiresAt, now) {
+			return nil
+		}
+		user, err := m.Serializer.Deserialize(data)
+		if err != nil {
+			return err
+		}
+		auth = user
+		expiresAt = entryExpiresAt
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		m.memory.put(string(key), auth, expiresAt, now)
+		return auth, nil
+	}
+	authUser, err := m.module.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt = m.entryExpiry(token, now)
 	err = m.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(storeBucket))
-		v := b.Get([]byte(token))
-		auth, err = m.Serializer.Deserialize(v)
-		return err
+		data, err := m.Serializer.Serialize(authUser)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(storeBucket)).Put(key, encodeEntry(expiresAt, data))
 	})
-	if auth == nil {
-		authUser, err := m.module.ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	m.memory.put(string(key), authUser, expiresAt, now)
+	return authUser, nil
+}
+
+// Revoke 移除缓存并拒绝该 token 直到其 exp，没有 exp 的 token 永久拒绝，吊销记录不会被清理。
+// 先写入吊销记录再移除内存缓存，并发的请求不会把吊销前读出的用户写回缓存
+func (m *TokenStoreManager) Revoke(token string) error {
+	key := tokenKey(token)
+	revokedUntil := tokenExpiry(token)
+	err := m.DB.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket([]byte(storeBucket)).Delete(key)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		err = m.DB.Update(func(tx *bolt.Tx) error {
-			b := tx.Bucket([]byte(storeBucket))
-			data, err := m.Serializer.Serialize(authUser)
+		return tx.Bucket([]byte(revokedBucket)).Put(key, encodeEntry(revokedUntil, nil))
+	})
+	if err != nil {
+		return err
+	}
+	m.memory.revoke(string(key), revokedUntil)
+	return nil
+}
+
+// isRevokedIn 检查 bolt 中的吊销记录
+func isRevokedIn(tx *bolt.Tx, key []byte, now time.Time) bool {
+	raw := tx.Bucket([]byte(revokedBucket)).Get(key)
+	if raw == nil {
+		return false
+	}
+	revokedUntil, _, _ := decodeEntry(raw)
+	return !isExpired(revokedUntil, now)
+}
+
+// IsRevoked token 是否已被吊销，缓存未初始化时返回 false
+func (m *TokenStoreManager) IsRevoked(token string) (bool, error) {
+	if m.DB == nil {
+		return false, nil
+	}
+	key := tokenKey(token)
+	now := m.now()
+	revoked := false
+	err := m.DB.View(func(tx *bolt.Tx) error {
+		revoked = isRevokedIn(tx, key, now)
+		return nil
+	})
+	return revoked, err
+}
+
+// Evict 只移除缓存，下次请求重新解析 token
+func (m *TokenStoreManager) Evict(token string) error {
+	key := tokenKey(token)
+	m.memory.remove(string(key))
+	return m.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(storeBucket)).Delete(key)
+	})
+}
+
+// Compact 删除已过期的缓存与吊销记录，返回 bolt 中删除的数量，永久吊销的记录保留
+func (m *TokenStoreManager) Compact() (int, error) {
+	now := m.now()
+	m.memory.compact(now)
+	removed := 0
+	err := m.DB.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{storeBucket, revokedBucket} {
+			bucket := tx.Bucket([]byte(name))
+			expiredKeys := make([][]byte, 0)
+			err := bucket.ForEach(func(k, v []byte) error {
+				expiresAt, _, ok := decodeEntry(v)
+				if !ok || isExpired(expiresAt, now) {
+					expiredKeys = append(expiredKeys, append([]byte{}, k...))
+				}
+				return nil
+			})
 			if err != nil {
 				return err
 			}
-			err = b.Put([]byte(token), data)
-			return err
-		})
-		return authUser, err
+			for _, k := range expiredKeys {
+				err = bucket.Delete(k)
+				if err != nil {
+					return err
+				}
+			}
+			removed += len(expiredKeys)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func (m *TokenStoreManager) runCompaction() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.CompactInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.Compact()
+		}
+	}
+}
+
+type revokeRequestBody struct {
+	Token string `json:"token"`
+}
+
+// revokeHandler 吊销请求体中的 token，未提供时吊销当前请求携带的 token
+func (m *TokenStoreManager) revokeHandler(context *haruka.Context) {
+	var body revokeRequestBody
+	if context.Request.ContentLength != 0 {
+		err := context.ParseJson(&body)
+		if err != nil {
+			youlink.AbortErrorWithStatus(err, context, http.StatusBadRequest)
+			return
+		}
+	}
+	token := body.Token
+	if token == "" {
+		token = m.module.ParseAuthHeader(context)
+	}
+	if token == "" {
+		youlink.AbortErrorWithStatus(errors.New("token is required"), context, http.StatusBadRequest)
+		return
+	}
+	err := m.Revoke(token)
+	if err != nil {
+		youlink.AbortErrorWithStatus(err, context, http.StatusInternalServerError)
+		return
+	}
+	context.JSON(haruka.JSON{
+		"success": true,
+	})
+}
+
+// Close 停止清理并将缓存落盘，关闭 bolt 文件
+func (m *TokenStoreManager) Close() error {
+	if m.DB == nil {
+		return nil
+	}
+	if m.stop != nil {
+		close(m.stop)
+		m.wg.Wait()
+		m.stop = nil
+	}
+	err := m.DB.Sync()
+	if err != nil {
+		return err
 	}
-	return auth, nil
+	err = m.DB.Close()
+	m.DB = nil
+	return err
 }
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allentom/harukap/commons"
+	"github.com/dgrijalva/jwt-go"
+)
+
+type mapSerializer struct{}
+
+func (mapSerializer) Serialize(data interface{}) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+func (mapSerializer) Deserialize(raw []byte) (commons.AuthUser, error) {
+	var user map[string]interface{}
+	err := json.Unmarshal(raw, &user)
+	return user, err
+}
+
+// countingPlugin 记录 token 被解析的次数
+type countingPlugin struct {
+	issuerPlugin
+	lock  sync.Mutex
+	calls int
+}
+
+func (p *countingPlugin) GetAuthUserByToken(token string) (commons.AuthUser, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.calls++
+	return map[string]interface{}{"name": "bob"}, nil
+}
+
+func (p *countingPlugin) parsed() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.calls
+}
+
+type testClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestTokenStore(t *testing.T) (*TokenStoreManager, *countingPlugin, *testClock) {
+	plugin := &countingPlugin{issuerPlugin: issuerPlugin{secret: []byte("secret")}}
+	module := newTestAuthModule(t, "", plugin)
+	clock := &testClock{now: time.Now()}
+	store := &TokenStoreManager{
+		Serializer:      mapSerializer{},
+		Path:            filepath.Join(t.TempDir(), "token.db"),
+		TTL:             time.Hour,
+		CompactInterval: -1,
+		module:          module,
+		now:             clock.Now,
+	}
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		store.Close()
+	})
+	return store, plugin, clock
+}
+
+func signStoreToken(t *testing.T, claims jwt.MapClaims) string {
+	claims["iss"] = "self"
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestTokenStoreCacheUntilExp(t *testing.T) {
+	store, plugin, clock := newTestTokenStore(t)
+	token := signStoreToken(t, jwt.MapClaims{"exp": clock.Now().Add(2 * time.Minute).Unix()})
+	for i := 0; i < 3; i++ {
+		if _, err := store.GetUserByToken(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if plugin.parsed() != 1 {
+		t.Fatalf("expected token parsed once, got %d", plugin.parsed())
+	}
+
+	// 内存缓存丢失后从 bolt 读取
+	store.memory = newUserLRU(DefaultTokenMemorySize)
+	if _, err := store.GetUserByToken(token); err != nil || plugin.parsed() != 1 {
+		t.Fatalf("expected user from bolt, parsed %d, err %v", plugin.parsed(), err)
+	}
+
+	clock.Add(3 * time.Minute)
+	if removed, _ := store.Compact(); removed != 1 {
+		t.Fatalf("expected expired entry compacted, got %d", removed)
+	}
+	if _, err := store.GetUserByToken(token); err != nil || plugin.parsed() != 2 {
+		t.Fatalf("expected token parsed again after expiry, parsed %d, err %v", plugin.parsed(), err)
+	}
+}
+
+func TestTokenStoreRevoke(t *testing.T) {
+	store, plugin, clock := newTestTokenStore(t)
+	token := signStoreToken(t, jwt.MapClaims{"exp": clock.Now().Add(2 * time.Minute).Unix()})
+	if _, err := store.GetUserByToken(token); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Revoke(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetUserByToken(token); err != ErrTokenRevoked {
+		t.Fatalf("expected revoked, got %v", err)
+	}
+
+	// 吊销前读出的用户不会写回内存缓存
+	key := string(tokenKey(token))
+	store.memory.put(key, map[string]interface{}{"name": "bob"}, clock.Now().Add(time.Minute), clock.Now())
+	if _, ok := store.memory.get(key, clock.Now()); ok {
+		t.Fatal("revoked token should not be cached")
+	}
+	if plugin.parsed() != 1 {
+		t.Fatalf("revoked token should not be parsed, got %d", plugin.parsed())
+	}
+
+	clock.Add(3 * time.Minute)
+	if removed, _ := store.Compact(); removed != 1 {
+		t.Fatalf("expected revoked record compacted, got %d", removed)
+	}
+	if len(store.memory.revoked) != 0 {
+		t.Fatal("expected memory revoke mark compacted")
+	}
+}
+
+func TestTokenStoreRevokeWithoutExp(t *testing.T) {
+	store, _, clock := newTestTokenStore(t)
+	token := signStoreToken(t, jwt.MapClaims{})
+	if err := store.Revoke(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetUserByToken(token); err != ErrTokenRevoked {
+		t.Fatalf("expected revoked, got %v", err)
+	}
+	// 没有 exp 的 token 永久吊销，超过 TTL 后清理也不会移除
+	clock.Add(2 * store.TTL)
+	if removed, _ := store.Compact(); removed != 0 {
+		t.Fatalf("permanent revocation should not be compacted, removed %d", removed)
+	}
+	if _, err := store.GetUserByToken(token); err != ErrTokenRevoked {
+		t.Fatalf("expected still revoked after ttl, got %v", err)
+	}
+}
+
+func TestAuthModuleParseTokenRevoked(t *testing.T) {
+	store, _, _ := newTestTokenStore(t)
+	store.module.CacheStore = store
+	token := signStoreToken(t, jwt.MapClaims{})
+	if _, err := store.module.ParseToken(token); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Revoke(token); err != nil {
+		t.Fatal(err)
+	}
+	// gRPC 认证直接调用 ParseToken，同样需要拒绝已吊销的 token
+	if _, err := store.module.ParseToken(token); err != ErrTokenRevoked {
+		t.Fatalf("expected revoked, got %v", err)
+	}
+}
+
+func TestUserLRU(t *testing.T) {
+	now := time.Now()
+	cache := newUserLRU(2)
+	cache.put("a", "a", now.Add(time.Hour), now)
+	cache.put("b", "b", now.Add(time.Hour), now)
+	cache.get("a", now)
+	cache.put("c", "c", now.Add(time.Hour), now)
+	if _, ok := cache.get("b", now); ok {
+		t.Fatal("least recently used entry should be evicted")
+	}
+	if _, ok := cache.get("a", now); !ok {
+		t.Fatal("recently used entry should be kept")
+	}
+	if _, ok := cache.get("c", now.Add(time.Hour)); ok {
+		t.Fatal("expired entry should be removed")
+	}
+}
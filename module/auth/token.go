@@ -1,13 +1,15 @@
 package auth
 
 import (
-	"errors"
+	"fmt"
 	"github.com/allentom/haruka"
 	"github.com/allentom/harukap/commons"
-	"github.com/dgrijalva/jwt-go"
 	"strings"
 )
 
+// ErrAuthPluginNotFound token 的签发者没有对应的 AuthPlugin
+var ErrAuthPluginNotFound = NewCredentialError("auth plugin not found")
+
 func (m *AuthModule) ParseAuthHeader(c *haruka.Context) string {
 	jwtToken := ""
 	jwtToken = c.Request.Header.Get("Authorization")
@@ -21,16 +23,35 @@ func (m *AuthModule) ParseAuthHeader(c *haruka.Context) string {
 	return jwtToken
 }
 
+// ParseToken 校验 token 签名与 exp nbf 后，交给签发者（iss）对应的 AuthPlugin 解析用户，
+// 设置了 CacheStore 时拒绝已吊销的 token
 func (m *AuthModule) ParseToken(jwtToken string) (commons.AuthUser, error) {
-	token, _, err := new(jwt.Parser).ParseUnverified(jwtToken, jwt.MapClaims{})
+	if m.CacheStore != nil {
+		revoked, err := m.CacheStore.IsRevoked(jwtToken)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return m.parseToken(jwtToken)
+}
+
+// parseToken 与 ParseToken 相同但不检查吊销记录，供已检查过的 TokenStoreManager 使用
+func (m *AuthModule) parseToken(jwtToken string) (commons.AuthUser, error) {
+	verifier := m.Verifier()
+	if verifier == nil {
+		verifier = NewTokenVerifier()
+	}
+	mapClaims, err := verifier.Verify(jwtToken)
 	if err != nil {
 		return nil, err
 	}
-	mapClaims := token.Claims.(jwt.MapClaims)
-	isu := mapClaims["iss"].(string)
+	isu, _ := mapClaims["iss"].(string)
 	authPlugin := m.GetAuthPluginByName(isu)
 	if authPlugin == nil {
-		return nil, errors.New("auth plugin not found")
+		return nil, fmt.Errorf("%w: %s", ErrAuthPluginNotFound, isu)
 	}
 	authUser, err := authPlugin.GetAuthUserByToken(jwtToken)
 	if err != nil {
@@ -0,0 +1,315 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// DefaultClockSkew 校验 exp nbf iat 时允许的时钟偏差
+const DefaultClockSkew = 60 * time.Second
+
+var (
+	ErrTokenExpired     = NewCredentialError("token expired")
+	ErrTokenNotValidYet = NewCredentialError("token not valid yet")
+	ErrTokenIssuer      = NewCredentialError("token issuer missing")
+	// ErrUnknownIssuer 签发者没有配置校验密钥，也没有声明跳过校验
+	ErrUnknownIssuer = NewCredentialError("no verification key for token issuer")
+	// ErrInvalidSignature 签名错误或签名算法与密钥类型不匹配
+	ErrInvalidSignature = NewCredentialError("invalid token signature")
+)
+
+// KeyProvider 按 token 头部（alg、kid）返回校验签名的密钥
+type KeyProvider interface {
+	Key(token *jwt.Token) (interface{}, error)
+}
+
+// staticKey HMAC 密钥或 PEM 公钥
+type staticKey struct {
+	key interface{}
+}
+
+func (k *staticKey) Key(token *jwt.Token) (interface{}, error) {
+	return k.key, nil
+}
+
+// KeyFunc 将函数作为 KeyProvider，适用于初始化后才能确定密钥的插件
+type KeyFunc func(token *jwt.Token) (interface{}, error)
+
+func (f KeyFunc) Key(token *jwt.Token) (interface{}, error) {
+	return f(token)
+}
+
+// NewHMACKey HS256/HS384/HS512 共享密钥
+func NewHMACKey(secret []byte) KeyProvider {
+	return &staticKey{key: secret}
+}
+
+// ParsePublicKeyPEM 解析 RSA 或 ECDSA 公钥，也接受证书
+func ParsePublicKeyPEM(raw []byte) (interface{}, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("invalid pem data")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "CERTIFICATE":
+		var certificate *x509.Certificate
+		certificate, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			key = certificate.PublicKey
+		}
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", key)
+}
+
+// NewPEMKey RSA 或 ECDSA 公钥，raw 为 PEM 内容
+func NewPEMKey(raw []byte) (KeyProvider, error) {
+	key, err := ParsePublicKeyPEM(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &staticKey{key: key}, nil
+}
+
+// checkAlgorithm 密钥类型必须与签名算法一致，防止用公钥作为 HMAC 密钥伪造签名
+func checkAlgorithm(token *jwt.Token, key interface{}) error {
+	switch key.(type) {
+	case []byte:
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			return nil
+		}
+	case *rsa.PublicKey:
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodECDSA); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: algorithm %s does not match key", ErrInvalidSignature, token.Method.Alg())
+}
+
+// TokenVerifier 按签发者（iss）校验 token 签名与时间声明。
+// 没有配置密钥的签发者会被拒绝，除非通过 SetIssuerUnverified 声明由插件自行校验
+type TokenVerifier struct {
+	sync.RWMutex
+	issuers map[string]KeyProvider
+	// unverified 跳过签名校验、只校验时间声明的签发者
+	unverified map[string]bool
+	// Skew 校验 exp nbf iat 时允许的时钟偏差
+	Skew time.Duration
+	now  func() time.Time
+}
+
+func NewTokenVerifier() *TokenVerifier {
+	return &TokenVerifier{
+		issuers:    map[string]KeyProvider{},
+		unverified: map[string]bool{},
+		Skew:       DefaultClockSkew,
+		now:        time.Now,
+	}
+}
+
+// SetIssuerKey 设置签发者的校验密钥，provider 为空时删除
+func (v *TokenVerifier) SetIssuerKey(issuer string, provider KeyProvider) {
+	v.Lock()
+	defer v.Unlock()
+	if provider == nil {
+		delete(v.issuers, issuer)
+		return
+	}
+	v.issuers[issuer] = provider
+}
+
+// SetIssuerUnverified 声明签发者的 token 由对应的 AuthPlugin 自行校验签名，框架只校验时间声明
+func (v *TokenVerifier) SetIssuerUnverified(issuer string, unverified bool) {
+	v.Lock()
+	defer v.Unlock()
+	if !unverified {
+		delete(v.unverified, issuer)
+		return
+	}
+	v.unverified[issuer] = true
+}
+
+func (v *TokenVerifier) getIssuerKey(issuer string) (KeyProvider, bool) {
+	v.RLock()
+	defer v.RUnlock()
+	return v.issuers[issuer], v.unverified[issuer]
+}
+
+// checkTimeClaims 校验 exp nbf iat，允许 Skew 的偏差
+func (v *TokenVerifier) checkTimeClaims(claims jwt.MapClaims) error {
+	now := v.now().Unix()
+	skew := int64(v.Skew.Seconds())
+	if !claims.VerifyExpiresAt(now-skew, false) {
+		return ErrTokenExpired
+	}
+	if !claims.VerifyNotBefore(now+skew, false) || !claims.VerifyIssuedAt(now+skew, false) {
+		return ErrTokenNotValidYet
+	}
+	return nil
+}
+
+// Verify 校验 token 并返回声明，签发者配置了密钥时校验签名，没有配置密钥时只接受声明跳过校验的签发者
+func (v *TokenVerifier) Verify(tokenString string) (jwt.MapClaims, error) {
+	unverified, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+	issuer, _ := unverified.Claims.(jwt.MapClaims)["iss"].(string)
+	if issuer == "" {
+		return nil, ErrTokenIssuer
+	}
+	provider, skip := v.getIssuerKey(issuer)
+	if provider == nil {
+		if !skip {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownIssuer, issuer)
+		}
+		claims := unverified.Claims.(jwt.MapClaims)
+		return claims, v.checkTimeClaims(claims)
+	}
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(tokenString, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
+		key, err := provider.Key(token)
+		if err != nil {
+			return nil, err
+		}
+		if err = checkAlgorithm(token, key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	})
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Inner != nil {
+			err = validationErr.Inner
+		}
+		if errors.Is(err, ErrInvalidSignature) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	return claims, v.checkTimeClaims(claims)
+}
+
+// readKeyValue 值为 PEM 内容或文件路径
+func readKeyValue(value string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return os.ReadFile(value)
+}
+
+// loadVerifier 读取 jwt.skew 与各 auth.<name>.jwt 配置：
+// issuer（默认为 name）以及 secret、publicKey（PEM 内容或路径）、jwks（地址）之一，
+// jwksCacheTtl 为 JWKS 缓存秒数；verify 为 false 时该签发者的 token 由插件自行校验签名。
+// 地址与缓存时间未变的 JWKS 沿用之前的实例，调用方需持有 mutex
+func (m *AuthModule) loadVerifier() (*TokenVerifier, map[string]*JWKSKeyProvider, error) {
+	configer := m.ConfigProvider.Config()
+	verifier := NewTokenVerifier()
+	if configer.IsSet("jwt.skew") {
+		verifier.Skew = time.Duration(configer.GetInt("jwt.skew")) * time.Second
+	}
+	jwksProviders := map[string]*JWKSKeyProvider{}
+	for key := range configer.GetStringMap("auth") {
+		prefix := fmt.Sprintf("auth.%s.jwt", key)
+		if !configer.IsSet(prefix) {
+			continue
+		}
+		issuer := configer.GetString(prefix + ".issuer")
+		if issuer == "" {
+			issuer = key
+		}
+		if configer.IsSet(prefix+".verify") && !configer.GetBool(prefix+".verify") {
+			verifier.SetIssuerUnverified(issuer, true)
+			continue
+		}
+		var provider KeyProvider
+		switch {
+		case configer.GetString(prefix+".secret") != "":
+			provider = NewHMACKey([]byte(configer.GetString(prefix + ".secret")))
+		case configer.GetString(prefix+".publicKey") != "":
+			raw, err := readKeyValue(configer.GetString(prefix + ".publicKey"))
+			if err != nil {
+				return nil, nil, fmt.Errorf("read %s.publicKey failed: %v", prefix, err)
+			}
+			provider, err = NewPEMKey(raw)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parse %s.publicKey failed: %v", prefix, err)
+			}
+		case configer.GetString(prefix+".jwks") != "":
+			url := configer.GetString(prefix + ".jwks")
+			cacheTTL := DefaultJWKSCacheTTL
+			if configer.IsSet(prefix + ".jwksCacheTtl") {
+				cacheTTL = time.Duration(configer.GetInt(prefix+".jwksCacheTtl")) * time.Second
+			}
+			jwks, ok := jwksProviders[url]
+			if !ok {
+				jwks = m.jwksProviders[url]
+			}
+			if jwks == nil || jwks.CacheTTL != cacheTTL {
+				jwks = NewJWKSKeyProvider(url)
+				jwks.CacheTTL = cacheTTL
+			}
+			jwksProviders[url] = jwks
+			provider = jwks
+		default:
+			return nil, nil, fmt.Errorf("%s requires one of secret, publicKey or jwks, or verify: false", prefix)
+		}
+		verifier.SetIssuerKey(issuer, provider)
+	}
+	return verifier, jwksProviders, nil
+}
+
+// SetIssuerKey 在代码中设置签发者的校验密钥，会在配置重新加载时保留
+func (m *AuthModule) SetIssuerKey(issuer string, provider KeyProvider) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.issuerKeys == nil {
+		m.issuerKeys = map[string]KeyProvider{}
+	}
+	m.issuerKeys[issuer] = provider
+	if verifier := m.verifier.Load(); verifier != nil {
+		verifier.SetIssuerKey(issuer, provider)
+	}
+}
+
+// SetIssuerUnverified 在代码中声明签发者的 token 由插件自行校验签名，与 auth.<name>.jwt.verify: false 相同，
+// 会在配置重新加载时保留
+func (m *AuthModule) SetIssuerUnverified(issuer string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.unverifiedIssuers == nil {
+		m.unverifiedIssuers = map[string]bool{}
+	}
+	m.unverifiedIssuers[issuer] = true
+	if verifier := m.verifier.Load(); verifier != nil {
+		verifier.SetIssuerUnverified(issuer, true)
+	}
+}
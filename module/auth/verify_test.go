@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/commons"
+	"github.com/allentom/harukap/config"
+	"github.com/dgrijalva/jwt-go"
+)
+
+var verifyNow = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func newTestRSAKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: raw})
+}
+
+func TestTokenVerifierVerify(t *testing.T) {
+	privateKey, publicPEM := newTestRSAKey(t)
+	rsaKey, err := NewPEMKey(publicPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("secret")
+	verifier := NewTokenVerifier()
+	verifier.now = func() time.Time {
+		return verifyNow
+	}
+	verifier.SetIssuerKey("hmac", NewHMACKey(secret))
+	verifier.SetIssuerKey("rsa", rsaKey)
+	verifier.SetIssuerUnverified("external", true)
+
+	at := func(offset time.Duration) int64 {
+		return verifyNow.Add(offset).Unix()
+	}
+	cases := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"hmac", signTestToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "hmac", "exp": at(time.Minute)}), nil},
+		{"rsa", signTestToken(t, jwt.SigningMethodRS256, privateKey, jwt.MapClaims{"iss": "rsa", "exp": at(time.Minute)}), nil},
+		{"wrong key", signTestToken(t, jwt.SigningMethodHS256, []byte("other"), jwt.MapClaims{"iss": "hmac"}), ErrInvalidSignature},
+		// 使用公钥作为 HMAC 密钥伪造签名
+		{"alg confusion", signTestToken(t, jwt.SigningMethodHS256, publicPEM, jwt.MapClaims{"iss": "rsa"}), ErrInvalidSignature},
+		{"alg none", signTestToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, jwt.MapClaims{"iss": "hmac"}), ErrInvalidSignature},
+		{"rsa key for hmac issuer", signTestToken(t, jwt.SigningMethodRS256, privateKey, jwt.MapClaims{"iss": "hmac"}), ErrInvalidSignature},
+		{"unknown issuer", signTestToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "other"}), ErrUnknownIssuer},
+		{"missing issuer", signTestToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{}), ErrTokenIssuer},
+		{"unverified issuer", signTestToken(t, jwt.SigningMethodHS256, []byte("any"), jwt.MapClaims{"iss": "external"}), nil},
+		{"unverified issuer expired", signTestToken(t, jwt.SigningMethodHS256, []byte("any"), jwt.MapClaims{"iss": "external", "exp": at(-time.Hour)}), ErrTokenExpired},
+		{"expired within skew", signTestToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "hmac", "exp": at(-30 * time.Second)}), nil},
+		{"expired beyond skew", signTestToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "hmac", "exp": at(-2 * time.Minute)}), ErrTokenExpired},
+		{"nbf within skew", signTestToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "hmac", "nbf": at(30 * time.Second)}), nil},
+		{"nbf beyond skew", signTestToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "hmac", "nbf": at(2 * time.Minute)}), ErrTokenNotValidYet},
+		{"iat beyond skew", signTestToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "hmac", "iat": at(2 * time.Minute)}), ErrTokenNotValidYet},
+	}
+	for _, c := range cases {
+		claims, err := verifier.Verify(c.token)
+		if c.err == nil {
+			if err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+			if claims["iss"] == nil {
+				t.Fatalf("%s: claims should be returned", c.name)
+			}
+			continue
+		}
+		if !errors.Is(err, c.err) {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+	}
+
+	// 取消跳过校验后拒绝
+	verifier.SetIssuerUnverified("external", false)
+	if _, err = verifier.Verify(cases[8].token); !errors.Is(err, ErrUnknownIssuer) {
+		t.Fatalf("issuer should be rejected after opt-out removed, got %v", err)
+	}
+}
+
+// issuerPlugin 自行签发 token 的 AuthPlugin
+type issuerPlugin struct {
+	secret []byte
+}
+
+func (p *issuerPlugin) GetAuthInfo() (*commons.AuthInfo, error) {
+	return &commons.AuthInfo{}, nil
+}
+
+func (p *issuerPlugin) AuthName() string {
+	return "self"
+}
+
+func (p *issuerPlugin) GetAuthUserByToken(token string) (commons.AuthUser, error) {
+	return nil, nil
+}
+
+func (p *issuerPlugin) TokenTypeName() string {
+	return "self"
+}
+
+func (p *issuerPlugin) IssuerKey() KeyProvider {
+	return NewHMACKey(p.secret)
+}
+
+// remotePlugin token 由外部服务签发并远程校验的 AuthPlugin
+type remotePlugin struct{}
+
+func (p *remotePlugin) GetAuthInfo() (*commons.AuthInfo, error) {
+	return &commons.AuthInfo{}, nil
+}
+
+func (p *remotePlugin) AuthName() string {
+	return "remote"
+}
+
+func (p *remotePlugin) GetAuthUserByToken(token string) (commons.AuthUser, error) {
+	return nil, nil
+}
+
+func (p *remotePlugin) TokenTypeName() string {
+	return "remote"
+}
+
+func (p *remotePlugin) IssuerUnverified() bool {
+	return true
+}
+
+func newTestAuthModule(t *testing.T, content string, plugins ...harukap.AuthPlugin) *AuthModule {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := config.NewProvider(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module := &AuthModule{Plugins: plugins, ConfigProvider: provider}
+	if err = module.InitModule(); err != nil {
+		t.Fatal(err)
+	}
+	return module
+}
+
+func TestAuthModuleIssuerKeys(t *testing.T) {
+	module := newTestAuthModule(t, `
+auth:
+  hs:
+    type: hs
+    jwt:
+      secret: secret
+  external:
+    type: external
+    jwt:
+      verify: false
+`, &issuerPlugin{secret: []byte("self-secret")})
+	cases := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"configured secret", signTestToken(t, jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{"iss": "hs"}), nil},
+		{"plugin issuer key", signTestToken(t, jwt.SigningMethodHS256, []byte("self-secret"), jwt.MapClaims{"iss": "self"}), nil},
+		{"plugin issuer wrong key", signTestToken(t, jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{"iss": "self"}), ErrInvalidSignature},
+		{"verify disabled", signTestToken(t, jwt.SigningMethodHS256, []byte("any"), jwt.MapClaims{"iss": "external"}), nil},
+		{"not configured", signTestToken(t, jwt.SigningMethodHS256, []byte("any"), jwt.MapClaims{"iss": "youauth"}), ErrUnknownIssuer},
+	}
+	for _, c := range cases {
+		_, err := module.Verifier().Verify(c.token)
+		if c.err == nil && err != nil || c.err != nil && !errors.Is(err, c.err) {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+	}
+	module.SetIssuerUnverified("youauth")
+	if _, err := module.Verifier().Verify(cases[4].token); err != nil {
+		t.Fatalf("issuer opted out in code should be accepted, got %v", err)
+	}
+}
+
+func TestAuthModuleUnverifiedIssuerPlugin(t *testing.T) {
+	module := newTestAuthModule(t, `
+auth:
+  remote:
+    type: remote
+`, &remotePlugin{})
+	token := signTestToken(t, jwt.SigningMethodHS256, []byte("any"), jwt.MapClaims{"iss": "remote"})
+	if _, err := module.ParseToken(token); err != nil {
+		t.Fatalf("token of remote verified plugin should be accepted, got %v", err)
+	}
+	expired := signTestToken(t, jwt.SigningMethodHS256, []byte("any"), jwt.MapClaims{"iss": "remote", "exp": time.Now().Add(-time.Hour).Unix()})
+	if _, err := module.ParseToken(expired); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expired token should be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrTaskNotActive 任务已结束，无法停止或暂停
+	ErrTaskNotActive = errors.New("task is not active")
+	// ErrTaskNotPaused 任务未处于暂停状态
+	ErrTaskNotPaused = errors.New("task is not paused")
+)
+
+// TaskWithPause 可选接口：支持暂停与恢复的任务，BaseTask 已实现
+type TaskWithPause interface {
+	Pause() error
+	Resume() error
+}
+
+// IsFinishedStatus 任务是否已处于结束状态
+func IsFinishedStatus(status string) bool {
+	switch status {
+	case StatusNameMapping[StatusDone],
+		StatusNameMapping[StatusError],
+		StatusNameMapping[StatusCancelled],
+		StatusNameMapping[StatusInterrupted],
+		StatusNameMapping[StatusSkipped]:
+		return true
+	}
+	return false
+}
+
+// initContext 调用方需持有 lock，兼容未通过 NewBaseTask 创建的任务
+func (t *BaseTask) initContext() {
+	if t.ctx == nil {
+		t.ctx, t.cancel = context.WithCancel(context.Background())
+	}
+}
+
+// Context 任务的 context，任务停止时取消，Start 中的长时间操作应使用该 context
+func (t *BaseTask) Context() context.Context {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.initContext()
+	return t.ctx
+}
+
+// IsCancelled 任务是否已被停止
+func (t *BaseTask) IsCancelled() bool {
+	return t.Context().Err() != nil
+}
+
+// Checkpoint 供 Start 在处理过程中调用：暂停时阻塞直到恢复，任务已停止时返回 context.Canceled
+func (t *BaseTask) Checkpoint() error {
+	t.lock.Lock()
+	t.initContext()
+	ctx := t.ctx
+	resume := t.resume
+	t.lock.Unlock()
+	if resume != nil {
+		select {
+		case <-resume:
+		case <-ctx.Done():
+		}
+	}
+	return ctx.Err()
+}
+
+// Stop 取消任务的 context 并标记为 Cancelled，同时停止所有子任务。
+// 正在执行的 Start 需要通过 Context 或 Checkpoint 感知取消
+func (t *BaseTask) Stop() error {
+	t.lock.Lock()
+	if IsFinishedStatus(t.Status) {
+		t.lock.Unlock()
+		return ErrTaskNotActive
+	}
+	t.initContext()
+	t.cancel()
+	if t.resume != nil {
+		close(t.resume)
+		t.resume = nil
+	}
+	t.EndTime = time.Now()
+	t.recordStatus(StatusNameMapping[StatusCancelled])
+	t.lock.Unlock()
+	for _, subTask := range t.SubTask() {
+		subTask.Stop()
+	}
+	t.notifyChange()
+	return nil
+}
+
+// Pause 暂停任务及其子任务，任务在下一次 Checkpoint 时阻塞
+func (t *BaseTask) Pause() error {
+	t.lock.Lock()
+	if IsFinishedStatus(t.Status) {
+		t.lock.Unlock()
+		return ErrTaskNotActive
+	}
+	if t.resume == nil {
+		t.resume = make(chan struct{})
+		t.recordStatus(StatusNameMapping[StatusPaused])
+	}
+	t.lock.Unlock()
+	for _, subTask := range t.SubTask() {
+		if pauseTask, ok := subTask.(TaskWithPause); ok {
+			pauseTask.Pause()
+		}
+	}
+	t.notifyChange()
+	return nil
+}
+
+// Resume 恢复暂停的任务及其子任务，已开始执行的任务恢复为 Running，否则恢复为 Pending
+func (t *BaseTask) Resume() error {
+	t.lock.Lock()
+	if t.resume == nil {
+		t.lock.Unlock()
+		return ErrTaskNotPaused
+	}
+	close(t.resume)
+	t.resume = nil
+	if t.StartTime.IsZero() {
+		t.recordStatus(StatusNameMapping[StatusPending])
+	} else {
+		t.recordStatus(StatusNameMapping[StatusRunning])
+	}
+	t.lock.Unlock()
+	for _, subTask := range t.SubTask() {
+		if pauseTask, ok := subTask.(TaskWithPause); ok {
+			pauseTask.Resume()
+		}
+	}
+	t.notifyChange()
+	return nil
+}
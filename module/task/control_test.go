@@ -0,0 +1,103 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newControlTask() (*outputTask, *outputTask) {
+	parent := &outputTask{BaseTask: NewBaseTask("scan", "", StatusNameMapping[StatusRunning])}
+	sub := &outputTask{BaseTask: NewSubTask("scan", "", StatusNameMapping[StatusPending], parent.GetId())}
+	parent.AddSubTask(sub)
+	return parent, sub
+}
+
+func TestStopPropagatesToSubTasks(t *testing.T) {
+	parent, sub := newControlTask()
+	if err := parent.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range []*outputTask{parent, sub} {
+		if task.GetStatus() != StatusNameMapping[StatusCancelled] || !task.IsCancelled() {
+			t.Fatalf("task should be cancelled, got %s", task.GetStatus())
+		}
+		if !errors.Is(task.Checkpoint(), context.Canceled) {
+			t.Fatal("checkpoint should report cancellation")
+		}
+	}
+	// 取消后 Done 与 AbortError 不覆盖状态
+	parent.Done()
+	parent.AbortError(errors.New("late"))
+	if parent.GetStatus() != StatusNameMapping[StatusCancelled] || parent.Error() != nil {
+		t.Fatalf("cancelled status should be kept, got %s", parent.GetStatus())
+	}
+	if parent.Stop() != ErrTaskNotActive {
+		t.Fatal("stopping a finished task should fail")
+	}
+	late := &outputTask{BaseTask: NewSubTask("scan", "", StatusNameMapping[StatusPending], parent.GetId())}
+	parent.AddSubTask(late)
+	if !late.IsCancelled() {
+		t.Fatal("sub task added after stop should be cancelled")
+	}
+}
+
+func TestPauseBlocksCheckpointUntilResume(t *testing.T) {
+	parent, sub := newControlTask()
+	parent.SetStart()
+	if err := parent.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if parent.GetStatus() != StatusNameMapping[StatusPaused] || sub.GetStatus() != StatusNameMapping[StatusPaused] {
+		t.Fatal("pause should propagate to sub tasks")
+	}
+	passed := make(chan error, 1)
+	go func() {
+		passed <- parent.Checkpoint()
+	}()
+	select {
+	case <-passed:
+		t.Fatal("checkpoint should block while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := parent.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-passed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("checkpoint should return after resume")
+	}
+	if parent.GetStatus() != StatusNameMapping[StatusRunning] {
+		t.Fatalf("started task should resume as running, got %s", parent.GetStatus())
+	}
+	if sub.GetStatus() != StatusNameMapping[StatusPending] {
+		t.Fatalf("not started sub task should resume as pending, got %s", sub.GetStatus())
+	}
+	if parent.Resume() != ErrTaskNotPaused {
+		t.Fatal("resuming a running task should fail")
+	}
+}
+
+func TestStopReleasesPausedCheckpoint(t *testing.T) {
+	task := &outputTask{BaseTask: NewBaseTask("scan", "", StatusNameMapping[StatusRunning])}
+	task.Pause()
+	passed := make(chan error, 1)
+	go func() {
+		passed <- task.Checkpoint()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	task.Stop()
+	select {
+	case err := <-passed:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("checkpoint should report cancellation, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stop should release paused checkpoint")
+	}
+}
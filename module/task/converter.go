@@ -0,0 +1,197 @@
+package task
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// 匹配优先级：指定任务类型 > 类型完全一致 > 指针与值互相转换 > 接口实现，同一优先级后注册的优先
+const (
+	matchNone = iota
+	matchInterface
+	matchPointer
+	matchExact
+	// matchTaskType 指定了任务类型的转换器在同一匹配方式上额外加权
+	matchTaskType = 10
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// OutputConverter 将任务的 Output 转换为接口返回的数据
+type OutputConverter interface {
+	// TaskType 只对该类型的任务生效，空字符串表示所有任务
+	TaskType() string
+	// InputType 转换器接受的输出类型
+	InputType() reflect.Type
+	// Convert 转换已经按 InputType 匹配后的输出
+	Convert(data interface{}) (interface{}, error)
+}
+
+// matchOutput 按匹配规则把 data 转换为 target 类型的值，返回匹配方式
+func matchOutput(target reflect.Type, data interface{}) (reflect.Value, int) {
+	if data == nil {
+		return reflect.Value{}, matchNone
+	}
+	value := reflect.ValueOf(data)
+	dataType := value.Type()
+	if dataType == target {
+		return value, matchExact
+	}
+	// 输出为 *T，转换器接受 T
+	if dataType.Kind() == reflect.Ptr && dataType.Elem() == target {
+		if value.IsNil() {
+			return reflect.Value{}, matchNone
+		}
+		return value.Elem(), matchPointer
+	}
+	// 输出为 T，转换器接受 *T
+	if target.Kind() == reflect.Ptr && target.Elem() == dataType {
+		pointer := reflect.New(dataType)
+		pointer.Elem().Set(value)
+		return pointer, matchPointer
+	}
+	if target.Kind() == reflect.Interface && dataType.Implements(target) {
+		return value, matchInterface
+	}
+	return reflect.Value{}, matchNone
+}
+
+type typedConverter[T any] struct {
+	taskType string
+	convert  func(data T) (interface{}, error)
+}
+
+func (c *typedConverter[T]) TaskType() string {
+	return c.taskType
+}
+
+func (c *typedConverter[T]) InputType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (c *typedConverter[T]) Convert(data interface{}) (interface{}, error) {
+	typed, ok := data.(T)
+	if !ok {
+		return nil, fmt.Errorf("converter expects %s, got %T", c.InputType(), data)
+	}
+	return c.convert(typed)
+}
+
+// NewConverter 创建类型安全的转换器，T 可以为具体类型、指针或接口
+func NewConverter[T any](convert func(data T) (interface{}, error)) OutputConverter {
+	return &typedConverter[T]{convert: convert}
+}
+
+// NewTaskTypeConverter 创建只对指定类型任务生效的转换器
+func NewTaskTypeConverter[T any](taskType string, convert func(data T) (interface{}, error)) OutputConverter {
+	return &typedConverter[T]{taskType: taskType, convert: convert}
+}
+
+// funcConverter 兼容旧的 func(T) (R, error) 形式的转换函数
+type funcConverter struct {
+	fn reflect.Value
+}
+
+func newFuncConverter(fn interface{}) (OutputConverter, error) {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func {
+		return nil, fmt.Errorf("converter must be a function, got %T", fn)
+	}
+	fnType := value.Type()
+	if fnType.NumIn() != 1 || fnType.NumOut() != 2 || !fnType.Out(1).Implements(errorType) {
+		return nil, fmt.Errorf("converter must be func(T) (R, error), got %s", fnType)
+	}
+	return &funcConverter{fn: value}, nil
+}
+
+func (c *funcConverter) TaskType() string {
+	return ""
+}
+
+func (c *funcConverter) InputType() reflect.Type {
+	return c.fn.Type().In(0)
+}
+
+func (c *funcConverter) Convert(data interface{}) (interface{}, error) {
+	results := c.fn.Call([]reflect.Value{reflect.ValueOf(data)})
+	if err, _ := results[1].Interface().(error); err != nil {
+		return nil, err
+	}
+	return results[0].Interface(), nil
+}
+
+// ConverterRegistry 任务输出转换器注册表
+type ConverterRegistry struct {
+	sync.RWMutex
+	converters []OutputConverter
+}
+
+func NewConverterRegistry() *ConverterRegistry {
+	return &ConverterRegistry{
+		converters: []OutputConverter{},
+	}
+}
+
+// Register 注册转换器，支持 OutputConverter 与 func(T) (R, error) 形式的函数
+func (r *ConverterRegistry) Register(converter interface{}) error {
+	outputConverter, ok := converter.(OutputConverter)
+	if !ok {
+		var err error
+		outputConverter, err = newFuncConverter(converter)
+		if err != nil {
+			return err
+		}
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.converters = append(r.converters, outputConverter)
+	return nil
+}
+
+// Find 返回匹配 data 优先级最高的转换器与转换后的输入，没有匹配时返回 nil
+func (r *ConverterRegistry) Find(taskType string, data interface{}) (OutputConverter, interface{}) {
+	r.RLock()
+	defer r.RUnlock()
+	var best OutputConverter
+	var bestInput reflect.Value
+	bestRank := matchNone
+	for _, converter := range r.converters {
+		if converter.TaskType() != "" && converter.TaskType() != taskType {
+			continue
+		}
+		input, rank := matchOutput(converter.InputType(), data)
+		if rank == matchNone {
+			continue
+		}
+		if converter.TaskType() != "" {
+			rank += matchTaskType
+		}
+		if rank >= bestRank {
+			best, bestInput, bestRank = converter, input, rank
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	return best, bestInput.Interface()
+}
+
+// Convert 使用匹配的转换器转换 data，没有匹配时原样返回，转换器中的 panic 会作为错误返回
+func (r *ConverterRegistry) Convert(taskType string, data interface{}) (result interface{}, err error) {
+	converter, input := r.Find(taskType, data)
+	if converter == nil {
+		return data, nil
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result = nil
+			err = fmt.Errorf("convert %T failed: panic: %v", data, recovered)
+		}
+	}()
+	result, err = converter.Convert(input)
+	if err != nil {
+		return nil, fmt.Errorf("convert %T failed: %w", data, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,258 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type scanOutput struct {
+	Count int
+}
+
+type namedOutput interface {
+	Name() string
+}
+
+type tagOutput struct {
+	Tag string
+}
+
+func (o *tagOutput) Name() string {
+	return o.Tag
+}
+
+func TestConverterExactType(t *testing.T) {
+	registry := NewConverterRegistry()
+	registry.Register(NewConverter(func(data scanOutput) (interface{}, error) {
+		return fmt.Sprintf("count=%d", data.Count), nil
+	}))
+	result, err := registry.Convert("scan", scanOutput{Count: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "count=3" {
+		t.Fatalf("unexpected result: %v", result)
+	}
+}
+
+func TestConverterPointerAndValue(t *testing.T) {
+	valueRegistry := NewConverterRegistry()
+	valueRegistry.Register(NewConverter(func(data scanOutput) (interface{}, error) {
+		return data.Count, nil
+	}))
+	result, err := valueRegistry.Convert("", &scanOutput{Count: 5})
+	if err != nil || result != 5 {
+		t.Fatalf("value converter should accept pointer output, got %v %v", result, err)
+	}
+	// nil 指针不匹配，原样返回
+	var nilOutput *scanOutput
+	result, err = valueRegistry.Convert("", nilOutput)
+	if err != nil || result != nilOutput {
+		t.Fatalf("nil pointer should not match, got %v %v", result, err)
+	}
+
+	pointerRegistry := NewConverterRegistry()
+	pointerRegistry.Register(NewConverter(func(data *scanOutput) (interface{}, error) {
+		return data.Count, nil
+	}))
+	result, err = pointerRegistry.Convert("", scanOutput{Count: 7})
+	if err != nil || result != 7 {
+		t.Fatalf("pointer converter should accept value output, got %v %v", result, err)
+	}
+}
+
+func TestConverterInterface(t *testing.T) {
+	registry := NewConverterRegistry()
+	registry.Register(NewConverter(func(data namedOutput) (interface{}, error) {
+		return "name:" + data.Name(), nil
+	}))
+	result, err := registry.Convert("", &tagOutput{Tag: "cat"})
+	if err != nil || result != "name:cat" {
+		t.Fatalf("interface converter should match implementation, got %v %v", result, err)
+	}
+	// tagOutput 的值类型没有实现 namedOutput
+	result, err = registry.Convert("", tagOutput{Tag: "cat"})
+	if err != nil || result != (tagOutput{Tag: "cat"}) {
+		t.Fatalf("value without method should not match, got %v %v", result, err)
+	}
+}
+
+func TestConverterPriority(t *testing.T) {
+	registry := NewConverterRegistry()
+	registry.Register(NewTaskTypeConverter("tag", func(data namedOutput) (interface{}, error) {
+		return "task type", nil
+	}))
+	registry.Register(NewConverter(func(data *tagOutput) (interface{}, error) {
+		return "exact", nil
+	}))
+	registry.Register(NewConverter(func(data namedOutput) (interface{}, error) {
+		return "interface", nil
+	}))
+	registry.Register(NewConverter(func(data tagOutput) (interface{}, error) {
+		return "pointer", nil
+	}))
+	cases := []struct {
+		taskType string
+		expect   string
+	}{
+		{taskType: "tag", expect: "task type"},
+		{taskType: "scan", expect: "exact"},
+	}
+	for _, c := range cases {
+		result, err := registry.Convert(c.taskType, &tagOutput{Tag: "cat"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != c.expect {
+			t.Fatalf("task type %s: expect %s, got %v", c.taskType, c.expect, result)
+		}
+	}
+
+	// 同一优先级后注册的优先
+	registry.Register(NewConverter(func(data *tagOutput) (interface{}, error) {
+		return "exact latest", nil
+	}))
+	result, _ := registry.Convert("scan", &tagOutput{Tag: "cat"})
+	if result != "exact latest" {
+		t.Fatalf("latest converter should win, got %v", result)
+	}
+}
+
+func TestConverterNoMatch(t *testing.T) {
+	registry := NewConverterRegistry()
+	registry.Register(NewConverter(func(data scanOutput) (interface{}, error) {
+		return nil, nil
+	}))
+	for _, data := range []interface{}{nil, "raw", 1, map[string]int{"a": 1}} {
+		result, err := registry.Convert("", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(result) != fmt.Sprint(data) {
+			t.Fatalf("unmatched output should be returned as is, got %v", result)
+		}
+	}
+}
+
+func TestConverterErrors(t *testing.T) {
+	convertErr := errors.New("bad output")
+	registry := NewConverterRegistry()
+	registry.Register(NewConverter(func(data scanOutput) (interface{}, error) {
+		return nil, convertErr
+	}))
+	registry.Register(NewConverter(func(data *tagOutput) (interface{}, error) {
+		panic("boom")
+	}))
+	_, err := registry.Convert("", scanOutput{})
+	if !errors.Is(err, convertErr) {
+		t.Fatalf("converter error should be wrapped, got %v", err)
+	}
+	_, err = registry.Convert("", &tagOutput{})
+	if err == nil || !strings.Contains(err.Error(), "panic: boom") {
+		t.Fatalf("converter panic should be returned as error, got %v", err)
+	}
+}
+
+func TestConverterLegacyFunc(t *testing.T) {
+	registry := NewConverterRegistry()
+	err := registry.Register(func(data *scanOutput) (string, error) {
+		return fmt.Sprintf("legacy %d", data.Count), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := registry.Convert("", scanOutput{Count: 2})
+	if err != nil || result != "legacy 2" {
+		t.Fatalf("legacy converter should match, got %v %v", result, err)
+	}
+	registry.Register(func(data scanOutput) (string, error) {
+		return "", errors.New("legacy failed")
+	})
+	_, err = registry.Convert("", scanOutput{})
+	if err == nil || !strings.Contains(err.Error(), "legacy failed") {
+		t.Fatalf("legacy converter error should be returned, got %v", err)
+	}
+
+	for _, invalid := range []interface{}{
+		"not a function",
+		func(data scanOutput) string { return "" },
+		func(a, b scanOutput) (string, error) { return "", nil },
+	} {
+		if registry.Register(invalid) == nil {
+			t.Fatalf("invalid converter %T should be rejected", invalid)
+		}
+	}
+}
+
+func TestTaskModuleConverterByTaskType(t *testing.T) {
+	module := NewTaskModule()
+	module.AddConverter(NewTaskTypeConverter("scan", func(data scanOutput) (interface{}, error) {
+		return data.Count * 10, nil
+	}))
+	task := &outputTask{
+		BaseTask: NewBaseTask("scan", "", StatusNameMapping[StatusDone]),
+		output:   scanOutput{Count: 4},
+	}
+	data, err := module.SerializerTemplate(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.(*Template).Output != 40 {
+		t.Fatalf("task type converter should be used, got %v", data.(*Template).Output)
+	}
+	other := &outputTask{
+		BaseTask: NewBaseTask("tag", "", StatusNameMapping[StatusDone]),
+		output:   scanOutput{Count: 4},
+	}
+	data, err = module.SerializerTemplate(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.(*Template).Output != (scanOutput{Count: 4}) {
+		t.Fatalf("converter of other task type should not be used, got %v", data.(*Template).Output)
+	}
+}
+
+func TestTaskModuleConverterAppended(t *testing.T) {
+	module := NewTaskModule()
+	// 直接追加到已废弃的 Converter 字段仍然生效
+	module.Converter = append(module.Converter, func(data scanOutput) (int, error) {
+		return data.Count * 2, nil
+	})
+	task := &outputTask{
+		BaseTask: NewBaseTask("scan", "", StatusNameMapping[StatusDone]),
+		output:   scanOutput{Count: 4},
+	}
+	data, err := module.SerializerTemplate(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.(*Template).Output != 8 {
+		t.Fatalf("appended converter should be used, got %v", data.(*Template).Output)
+	}
+	module.AddConverter(func(data scanOutput) (int, error) {
+		return data.Count * 3, nil
+	})
+	data, err = module.SerializerTemplate(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.(*Template).Output != 12 || len(module.Converters.converters) != 2 {
+		t.Fatalf("converters should be registered once, got %v with %d converters", data.(*Template).Output, len(module.Converters.converters))
+	}
+}
+
+type outputTask struct {
+	*BaseTask
+	output interface{}
+}
+
+func (o *outputTask) Start() error {
+	return nil
+}
+
+func (o *outputTask) Output() (interface{}, error) {
+	return o.output, nil
+}
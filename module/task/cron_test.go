@@ -0,0 +1,113 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func cronTime(value string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseCronNext(t *testing.T) {
+	cases := []struct {
+		spec string
+		from string
+		next []string
+	}{
+		{"*/15 * * * *", "2026-01-01 10:07", []string{"2026-01-01 10:15", "2026-01-01 10:30", "2026-01-01 10:45", "2026-01-01 11:00"}},
+		{"5/20 9-10 * * *", "2026-01-01 09:30", []string{"2026-01-01 09:45", "2026-01-01 10:05", "2026-01-01 10:25", "2026-01-01 10:45", "2026-01-02 09:05"}},
+		{"0 8,20 * * *", "2026-01-01 08:00", []string{"2026-01-01 20:00", "2026-01-02 08:00"}},
+		{"30 2 * feb-mar mon-fri", "2026-01-20 00:00", []string{"2026-02-02 02:30", "2026-02-03 02:30"}},
+		// 日与周都指定时满足任意一个：1 号或周日
+		{"0 0 1 * 0", "2026-01-01 00:00", []string{"2026-01-04 00:00", "2026-01-11 00:00", "2026-01-18 00:00", "2026-01-25 00:00", "2026-02-01 00:00", "2026-02-08 00:00"}},
+		// 周为 * 时只按日匹配
+		{"0 0 31 * *", "2026-01-31 00:00", []string{"2026-03-31 00:00"}},
+		{"0 0 * * 7", "2026-01-01 00:00", []string{"2026-01-04 00:00"}},
+		{"@daily", "2026-01-01 10:00", []string{"2026-01-02 00:00"}},
+		{"@weekly", "2026-01-01 10:00", []string{"2026-01-04 00:00"}},
+		{"@every 90m", "2026-01-01 10:00", []string{"2026-01-01 11:30", "2026-01-01 13:00"}},
+	}
+	for _, c := range cases {
+		schedule, err := ParseCron(c.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", c.spec, err)
+		}
+		current := cronTime(c.from)
+		for _, expected := range c.next {
+			current = schedule.Next(current)
+			if got := current.Format("2006-01-02 15:04"); got != expected {
+				t.Fatalf("%s: expected %s, got %s", c.spec, expected, got)
+			}
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *", "@every soon"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Fatalf("%s should be rejected", spec)
+		}
+	}
+	schedule, _ := ParseCron("0 0 30 2 *")
+	if !schedule.Next(cronTime("2026-01-01 00:00")).IsZero() {
+		t.Fatal("impossible date should never match")
+	}
+}
+
+func TestCronSchedulerRunDue(t *testing.T) {
+	module := NewTaskModule()
+	// 调度器未启动，提交的任务保持 Pending
+	module.Scheduler = NewScheduler(module.Pool, 1)
+	now := cronTime("2026-01-01 10:00")
+	// 不启动 loop，通过 now 控制时间并手动触发
+	scheduler := &CronScheduler{
+		module:  module,
+		entries: map[string]*scheduleEntry{},
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		now: func() time.Time {
+			return now
+		},
+	}
+	tasks := make([]*flakyTask, 0)
+	scheduler.Add("scan", "*/5 * * * *", func() (QueueTask, error) {
+		task := newFlakyTask(0, nil)
+		tasks = append(tasks, task)
+		return task, nil
+	})
+	if info := scheduler.List()[0]; info.NextRun != "2026-01-01 10:05:00" {
+		t.Fatalf("unexpected next run %s", info.NextRun)
+	}
+	scheduler.runDue()
+	if len(tasks) != 0 {
+		t.Fatal("schedule should not run before next time")
+	}
+	now = now.Add(5 * time.Minute)
+	scheduler.runDue()
+	if len(tasks) != 1 {
+		t.Fatalf("schedule should run once, got %d", len(tasks))
+	}
+	info := scheduler.List()[0]
+	if info.LastRun != "2026-01-01 10:05:00" || info.NextRun != "2026-01-01 10:10:00" || info.LastTaskId != tasks[0].GetId() {
+		t.Fatalf("unexpected schedule info %+v", info)
+	}
+	if module.Pool.GetTaskById(tasks[0].GetId()) == nil {
+		t.Fatal("scheduled task should be added to pool")
+	}
+	now = now.Add(5 * time.Minute)
+	scheduler.runDue()
+	if info = scheduler.List()[0]; len(tasks) != 1 || info.Skipped != 1 {
+		t.Fatalf("unfinished previous run should be skipped, got %d tasks", len(tasks))
+	}
+	tasks[0].Done()
+	now = now.Add(5 * time.Minute)
+	scheduler.runDue()
+	if len(tasks) != 2 {
+		t.Fatalf("schedule should run after previous run finished, got %d", len(tasks))
+	}
+}
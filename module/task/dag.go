@@ -0,0 +1,219 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FailurePolicy 子任务失败时的处理方式
+type FailurePolicy int
+
+const (
+	// FailFast 任意子任务失败后停止正在执行的子任务，未开始的子任务标记为 Skipped
+	FailFast FailurePolicy = iota
+	// ContinueOnError 继续执行与失败任务无关的子任务，只跳过依赖失败任务的子任务
+	ContinueOnError
+)
+
+// TaskGraph 按依赖关系执行父任务的子任务，通常在父任务的 Start 中调用 Run：
+//
+//	graph := task.NewTaskGraph(t.BaseTask, 4, task.FailFast)
+//	graph.Add(download)
+//	graph.Add(tag, download.GetId())
+//	return graph.Run()
+//
+// 依赖必须先于依赖它的子任务加入，因此图中不会出现环
+type TaskGraph struct {
+	Parent      *BaseTask
+	Parallelism int
+	Policy      FailurePolicy
+	// RetryPolicies 不为空时子任务按类型的策略重试
+	RetryPolicies *RetryPolicies
+	nodes         []*graphNode
+	index         map[string]*graphNode
+}
+
+type graphNode struct {
+	task      QueueTask
+	dependsOn []string
+	state     int
+}
+
+const (
+	nodePending = iota
+	nodeRunning
+	nodeDone
+	nodeFailed
+	nodeSkipped
+)
+
+type nodeResult struct {
+	node *graphNode
+	err  error
+}
+
+// NewTaskGraph 创建任务图，parallelism 小于等于 0 时不限制并行数
+func NewTaskGraph(parent *BaseTask, parallelism int, policy FailurePolicy) *TaskGraph {
+	return &TaskGraph{
+		Parent:      parent,
+		Parallelism: parallelism,
+		Policy:      policy,
+		nodes:       []*graphNode{},
+		index:       map[string]*graphNode{},
+	}
+}
+
+// Add 添加子任务及其依赖的子任务 id，子任务同时加入父任务的 SubTaskList
+func (g *TaskGraph) Add(task QueueTask, dependsOn ...string) error {
+	if _, ok := g.index[task.GetId()]; ok {
+		return fmt.Errorf("sub task %s already added", task.GetId())
+	}
+	for _, id := range dependsOn {
+		if _, ok := g.index[id]; !ok {
+			return fmt.Errorf("dependency %s of sub task %s not found", id, task.GetId())
+		}
+	}
+	node := &graphNode{task: task, dependsOn: dependsOn}
+	g.nodes = append(g.nodes, node)
+	g.index[task.GetId()] = node
+	g.Parent.AddSubTask(task)
+	return nil
+}
+
+// ready 依赖全部完成时返回 true，存在失败或跳过的依赖时返回该依赖
+func (g *TaskGraph) ready(node *graphNode) (bool, *graphNode) {
+	for _, id := range node.dependsOn {
+		dependency := g.index[id]
+		switch dependency.state {
+		case nodeFailed, nodeSkipped:
+			return false, dependency
+		case nodeDone:
+		default:
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (g *TaskGraph) skip(node *graphNode, reason error) {
+	node.state = nodeSkipped
+	if skipper, ok := node.task.(interface{ Skip(reason error) }); ok {
+		skipper.Skip(reason)
+	}
+}
+
+func (g *TaskGraph) reportProgress() {
+	finished := 0
+	for _, node := range g.nodes {
+		if node.state != nodePending && node.state != nodeRunning {
+			finished++
+		}
+	}
+	g.Parent.SetProgress(int64(finished), int64(len(g.nodes)), fmt.Sprintf("%d/%d sub tasks finished", finished, len(g.nodes)))
+}
+
+func (g *TaskGraph) runNode(node *graphNode, results chan<- nodeResult) {
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			err = node.task.AbortError(fmt.Errorf("panic: %v", r))
+		}
+		results <- nodeResult{node: node, err: err}
+	}()
+	if setter, ok := node.task.(statusSetter); ok {
+		setter.SetStatus(StatusNameMapping[StatusRunning])
+	}
+	err = RunTaskWithRetry(node.task, g.RetryPolicies.Get(node.task.GetType()))
+}
+
+// Run 按依赖顺序并行执行子任务，并把完成数量汇总为父任务的进度。
+// FailFast 时返回第一个失败的错误，ContinueOnError 时返回所有失败子任务的错误
+func (g *TaskGraph) Run() error {
+	results := make(chan nodeResult, len(g.nodes))
+	running := 0
+	halted := false
+	var failures []error
+	g.reportProgress()
+	for {
+		if g.Parent.IsCancelled() {
+			halted = true
+		}
+		for changed := true; changed && !halted; {
+			changed = false
+			for _, node := range g.nodes {
+				if node.state != nodePending {
+					continue
+				}
+				ok, failedDependency := g.ready(node)
+				if failedDependency != nil {
+					g.skip(node, fmt.Errorf("dependency %s not completed", failedDependency.task.GetId()))
+					changed = true
+					continue
+				}
+				if !ok || (g.Parallelism > 0 && running >= g.Parallelism) {
+					continue
+				}
+				// 暂停时等待恢复后再启动新的子任务
+				if g.Parent.Checkpoint() != nil {
+					halted = true
+					break
+				}
+				node.state = nodeRunning
+				running++
+				go g.runNode(node, results)
+			}
+		}
+		if running == 0 {
+			break
+		}
+		result := <-results
+		running--
+		if result.err == nil && result.node.task.GetStatus() == StatusNameMapping[StatusDone] {
+			result.node.state = nodeDone
+		} else {
+			result.node.state = nodeFailed
+			err := result.err
+			if err == nil {
+				err = fmt.Errorf("sub task %s %s", result.node.task.GetId(), result.node.task.GetStatus())
+			}
+			failures = append(failures, fmt.Errorf("sub task %s failed: %w", result.node.task.GetId(), err))
+			if g.Policy == FailFast && !halted {
+				halted = true
+				for _, node := range g.nodes {
+					if node.state == nodeRunning {
+						node.task.Stop()
+					}
+				}
+			}
+		}
+		g.reportProgress()
+	}
+	for _, node := range g.nodes {
+		if node.state == nodePending {
+			g.skip(node, errors.New("task graph halted"))
+		}
+	}
+	g.reportProgress()
+	if g.Parent.IsCancelled() {
+		return context.Canceled
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	if g.Policy == FailFast {
+		return failures[0]
+	}
+	return fmt.Errorf("%d of %d sub tasks failed: %w", len(failures), len(g.nodes), errors.Join(failures...))
+}
+
+// Skip 因依赖未完成而不执行，标记为 Skipped
+func (t *BaseTask) Skip(reason error) {
+	t.lock.Lock()
+	t.Err = reason
+	t.EndTime = time.Now()
+	t.recordStatus(StatusNameMapping[StatusSkipped])
+	t.lock.Unlock()
+	t.notifyChange()
+}
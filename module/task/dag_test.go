@@ -0,0 +1,125 @@
+package task
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type graphRecorder struct {
+	sync.Mutex
+	order []string
+}
+
+// graphTask 执行时记录名称，fail 为 true 时返回 errFlaky
+type graphTask struct {
+	*BaseTask
+	name     string
+	fail     bool
+	recorder *graphRecorder
+}
+
+func (g *graphTask) Start() error {
+	g.recorder.Lock()
+	g.recorder.order = append(g.recorder.order, g.name)
+	g.recorder.Unlock()
+	if g.fail {
+		return errFlaky
+	}
+	return nil
+}
+
+func (g *graphTask) Output() (interface{}, error) {
+	return nil, nil
+}
+
+// newTestGraph a -> b -> c，d 无依赖
+func newTestGraph(t *testing.T, policy FailurePolicy, failing string) (*TaskGraph, map[string]*graphTask, *graphRecorder) {
+	parent := NewBaseTask("graph", "", StatusNameMapping[StatusRunning])
+	graph := NewTaskGraph(parent, 1, policy)
+	recorder := &graphRecorder{}
+	tasks := map[string]*graphTask{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		tasks[name] = &graphTask{
+			BaseTask: NewSubTask("step", "", StatusNameMapping[StatusPending], parent.GetId()),
+			name:     name,
+			fail:     name == failing,
+			recorder: recorder,
+		}
+	}
+	for _, step := range [][2]string{{"a", ""}, {"b", "a"}, {"c", "b"}, {"d", ""}} {
+		var dependsOn []string
+		if step[1] != "" {
+			dependsOn = append(dependsOn, tasks[step[1]].GetId())
+		}
+		if err := graph.Add(tasks[step[0]], dependsOn...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return graph, tasks, recorder
+}
+
+func TestTaskGraphRunInDependencyOrder(t *testing.T) {
+	graph, tasks, recorder := newTestGraph(t, FailFast, "")
+	if err := graph.Run(); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(recorder.order, "")
+	if len(got) != 4 || strings.Index(got, "a") > strings.Index(got, "b") || strings.Index(got, "b") > strings.Index(got, "c") {
+		t.Fatalf("sub tasks should run after their dependencies, got %s", got)
+	}
+	for name, task := range tasks {
+		if task.GetStatus() != StatusNameMapping[StatusDone] {
+			t.Fatalf("%s should be done, got %s", name, task.GetStatus())
+		}
+	}
+	if progress := graph.Parent.GetProgress(); progress.Current != 4 || progress.Percent != 100 {
+		t.Fatalf("unexpected parent progress %+v", progress)
+	}
+	if err := graph.Add(tasks["a"]); err == nil {
+		t.Fatal("duplicate sub task should be rejected")
+	}
+	other := &graphTask{BaseTask: NewBaseTask("step", "", StatusNameMapping[StatusPending])}
+	if err := graph.Add(other, "missing"); err == nil {
+		t.Fatal("unknown dependency should be rejected")
+	}
+}
+
+func TestTaskGraphFailFast(t *testing.T) {
+	graph, tasks, recorder := newTestGraph(t, FailFast, "a")
+	err := graph.Run()
+	if !errors.Is(err, errFlaky) {
+		t.Fatalf("first failure should be returned, got %v", err)
+	}
+	if got := strings.Join(recorder.order, ","); got != "a" {
+		t.Fatalf("no sub task should start after failure, got %s", got)
+	}
+	for _, name := range []string{"b", "c", "d"} {
+		if tasks[name].GetStatus() != StatusNameMapping[StatusSkipped] || tasks[name].Error() == nil {
+			t.Fatalf("%s should be skipped, got %s", name, tasks[name].GetStatus())
+		}
+	}
+}
+
+func TestTaskGraphContinueOnError(t *testing.T) {
+	graph, tasks, _ := newTestGraph(t, ContinueOnError, "a")
+	err := graph.Run()
+	if !errors.Is(err, errFlaky) || !strings.HasPrefix(err.Error(), "1 of 4 sub tasks failed") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := map[string]string{
+		"a": StatusNameMapping[StatusError],
+		"b": StatusNameMapping[StatusSkipped],
+		"c": StatusNameMapping[StatusSkipped],
+		"d": StatusNameMapping[StatusDone],
+	}
+	for name, status := range expected {
+		if got := tasks[name].GetStatus(); got != status {
+			t.Fatalf("%s should be %s, got %s", name, status, got)
+		}
+	}
+	if !strings.Contains(tasks["c"].Error().Error(), tasks["b"].GetId()) {
+		t.Fatalf("skip reason should name the dependency, got %v", tasks["c"].Error())
+	}
+}
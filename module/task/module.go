@@ -4,31 +4,77 @@ import (
 	"errors"
 	"fmt"
 	"github.com/allentom/haruka"
-	"reflect"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
 )
 
+var TaskLogger = logrus.New().WithField("scope", "task")
+
 type TaskModule struct {
-	Pool               *TaskPool
-	Converter          []interface{}
+	Pool *TaskPool
+	// Converter 通过 AddConverter 注册的转换器，直接追加的转换器会在下一次序列化时注册到 Converters
+	//
+	// Deprecated: 使用 AddConverter 或 Converters.Register
+	Converter []interface{}
+	// Converters 任务输出转换器注册表
+	Converters         *ConverterRegistry
 	ListHandler        haruka.RequestHandler
 	GetTaskByIdHandler haruka.RequestHandler
-	ErrorHandler       func(context *haruka.Context, err error)
+	// StopTaskHandler PauseTaskHandler ResumeTaskHandler 通过 query 参数 id 控制任务
+	StopTaskHandler   haruka.RequestHandler
+	PauseTaskHandler  haruka.RequestHandler
+	ResumeTaskHandler haruka.RequestHandler
+	// RetryTaskHandler 使用相同输入重新执行出错的任务
+	RetryTaskHandler haruka.RequestHandler
+	// ClearFinishedHandler 移除已结束的任务，可通过 query 参数 type owner 限定范围
+	ClearFinishedHandler haruka.RequestHandler
+	// SchedulesHandler 返回定时任务的下一次执行时间与最近一次结果
+	SchedulesHandler haruka.RequestHandler
+	// QueueHandler 返回调度器的队列状态，需要先调用 EnableScheduler
+	QueueHandler haruka.RequestHandler
+	ErrorHandler func(context *haruka.Context, err error)
+	// Store 任务持久化存储，通过 UseStore 设置，为空时任务只保存在内存中
+	Store TaskStore
+	// Scheduler 任务队列，通过 EnableScheduler 启用
+	Scheduler *Scheduler
+	// RetryPolicies 各任务类型的重试策略，调度器与 RetryTaskHandler 共用
+	RetryPolicies *RetryPolicies
+	// Retention 后台清理，通过 EnableRetention 启用
+	Retention *RetentionSweeper
+	// Cron 定时任务，在第一次 AddSchedule 时创建
+	Cron     *CronScheduler
+	cronOnce sync.Once
+	// converterLock syncedConverters 记录 Converter 中已注册到 Converters 的数量
+	converterLock    sync.Mutex
+	syncedConverters int
 }
 
 func NewTaskModule() *TaskModule {
 	module := &TaskModule{
-		Pool:      NewTaskPool(),
-		Converter: []interface{}{},
+		Pool:          NewTaskPool(),
+		Converter:     []interface{}{},
+		Converters:    NewConverterRegistry(),
+		RetryPolicies: NewRetryPolicies(),
 	}
 	module.ListHandler = func(context *haruka.Context) {
-		data, err := module.SerializerTemplateList()
+		query, err := ParseTaskQuery(context)
+		if err != nil {
+			module.ErrorHandler(context, err)
+			return
+		}
+		tasks, count := module.Pool.Query(query)
+		data, err := module.SerializerTemplates(tasks)
 		if err != nil {
 			module.ErrorHandler(context, err)
 			return
 		}
 		context.JSON(haruka.JSON{
-			"success": true,
-			"data":    data,
+			"success":  true,
+			"data":     data,
+			"count":    count,
+			"page":     query.Page,
+			"pageSize": query.PageSize,
 		})
 	}
 	module.GetTaskByIdHandler = func(context *haruka.Context) {
@@ -48,29 +94,196 @@ func NewTaskModule() *TaskModule {
 			"data":    data,
 		})
 	}
+	module.StopTaskHandler = module.controlHandler(func(task Task) error {
+		return task.Stop()
+	})
+	module.PauseTaskHandler = module.controlHandler(func(task Task) error {
+		pauseTask, ok := task.(TaskWithPause)
+		if !ok {
+			return fmt.Errorf("task id = %s does not support pause", task.GetId())
+		}
+		return pauseTask.Pause()
+	})
+	module.ResumeTaskHandler = module.controlHandler(func(task Task) error {
+		pauseTask, ok := task.(TaskWithPause)
+		if !ok {
+			return fmt.Errorf("task id = %s does not support resume", task.GetId())
+		}
+		return pauseTask.Resume()
+	})
+	module.RetryTaskHandler = module.controlHandler(module.RetryTask)
+	module.ClearFinishedHandler = module.clearFinishedHandler
+	module.SchedulesHandler = module.schedulesHandler
+	module.QueueHandler = func(context *haruka.Context) {
+		if module.Scheduler == nil {
+			module.ErrorHandler(context, errors.New("task scheduler not enabled"))
+			return
+		}
+		context.JSON(haruka.JSON{
+			"success": true,
+			"data":    module.Scheduler.Stats(),
+		})
+	}
 	return module
 }
 
+// controlHandler 按 query 参数 id 查找任务并执行 action，返回操作后的任务
+func (t *TaskModule) controlHandler(action func(task Task) error) haruka.RequestHandler {
+	return func(context *haruka.Context) {
+		id := context.GetQueryString("id")
+		task := t.Pool.GetTaskById(id)
+		if task == nil {
+			t.ErrorHandler(context, errors.New(fmt.Sprintf("task id = %s not found", id)))
+			return
+		}
+		err := action(task)
+		if err != nil {
+			t.ErrorHandler(context, err)
+			return
+		}
+		data, err := t.SerializerTemplate(task)
+		if err != nil {
+			t.ErrorHandler(context, err)
+			return
+		}
+		context.JSON(haruka.JSON{
+			"success": true,
+			"data":    data,
+		})
+	}
+}
+
+// SetRetryPolicy 设置任务类型的重试策略
+func (t *TaskModule) SetRetryPolicy(taskType string, policy *RetryPolicy) {
+	t.RetryPolicies.Set(taskType, policy)
+}
+
+// RetryTask 重置出错的任务并重新执行，启用调度器时重新排队，否则在新的 goroutine 中执行
+func (t *TaskModule) RetryTask(task Task) error {
+	queueTask, ok := task.(QueueTask)
+	if !ok {
+		return fmt.Errorf("task id = %s does not support retry", task.GetId())
+	}
+	retryTask, ok := task.(TaskWithRetry)
+	if !ok {
+		return fmt.Errorf("task id = %s does not support retry", task.GetId())
+	}
+	err := retryTask.ResetForRetry()
+	if err != nil {
+		return err
+	}
+	return t.Submit(queueTask)
+}
+
+// EnableScheduler 创建并启动任务队列，提交的任务会加入任务池，typeLimits 为各类型的并发上限
+func (t *TaskModule) EnableScheduler(workers int, typeLimits map[string]int) *Scheduler {
+	scheduler := NewScheduler(t.Pool, workers)
+	scheduler.RetryPolicies = t.RetryPolicies
+	for taskType, limit := range typeLimits {
+		scheduler.SetTypeLimit(taskType, limit)
+	}
+	scheduler.Start()
+	t.Scheduler = scheduler
+	return scheduler
+}
+
 type Template struct {
-	Id           string      `json:"id"`
-	Type         string      `json:"type"`
-	Status       string      `json:"status"`
-	Created      string      `json:"created"`
-	Err          string      `json:"err,omitempty"`
-	Output       interface{} `json:"output,omitempty"`
-	SubTask      []*Template `json:"subTask,omitempty"`
-	StartTime    string      `json:"startTime,omitempty"`
-	EndTime      string      `json:"endTime,omitempty"`
-	Duration     uint        `json:"duration,omitempty"`
-	ParentTaskId string      `json:"parentTaskId,omitempty"`
+	Id           string              `json:"id"`
+	Type         string              `json:"type"`
+	Status       string              `json:"status"`
+	Owner        string              `json:"owner,omitempty"`
+	Created      string              `json:"created"`
+	Err          string              `json:"err,omitempty"`
+	Output       interface{}         `json:"output,omitempty"`
+	SubTask      []*Template         `json:"subTask,omitempty"`
+	StartTime    string              `json:"startTime,omitempty"`
+	EndTime      string              `json:"endTime,omitempty"`
+	Duration     uint                `json:"duration,omitempty"`
+	ParentTaskId string              `json:"parentTaskId,omitempty"`
+	Transitions  []*StatusTransition `json:"transitions,omitempty"`
+	Attempts     []*Attempt          `json:"attempts,omitempty"`
+	Progress     *TaskProgress       `json:"progress,omitempty"`
+}
+
+// UseStore 设置任务存储：加载历史任务到任务池，将上次退出时未结束的任务标记为 Interrupted，
+// 之后任务池中任务的变化都会写入存储
+func (t *TaskModule) UseStore(store TaskStore) error {
+	templates, err := store.List()
+	if err != nil {
+		return fmt.Errorf("load task history failed: %v", err)
+	}
+	now := time.Now()
+	for _, template := range templates {
+		if markInterrupted(template, now) {
+			err = store.Save(template)
+			if err != nil {
+				return fmt.Errorf("save interrupted task %s failed: %v", template.Id, err)
+			}
+		}
+		t.Pool.AddTask(NewStoredTask(template))
+	}
+	t.Store = store
+	// 进度更新频繁，合并后每秒最多写入一次，状态变化立即写入
+	throttle := newChangeThrottle(time.Second, t.saveTask)
+	t.Pool.AddChangeListener(throttle.onChange)
+	t.Pool.AddRemoveListener(throttle.onRemove)
+	t.Pool.AddRemoveListener(func(id string) {
+		err := store.Delete(id)
+		if err != nil {
+			TaskLogger.WithField("id", id).Error(fmt.Sprintf("delete task from store failed: %v", err))
+		}
+	})
+	return nil
+}
+
+// saveTask 将任务快照写入存储，失败只记录日志，不影响任务执行
+func (t *TaskModule) saveTask(task Task) {
+	if _, ok := task.(*StoredTask); ok {
+		return
+	}
+	data, err := t.SerializerTemplate(task)
+	if err != nil {
+		TaskLogger.WithField("id", task.GetId()).Error(fmt.Sprintf("serialize task failed: %v", err))
+		return
+	}
+	err = t.Store.Save(data.(*Template))
+	if err != nil {
+		TaskLogger.WithField("id", task.GetId()).Error(fmt.Sprintf("save task failed: %v", err))
+	}
 }
 
+// AddConverter 注册输出转换器，支持 NewConverter 创建的转换器与 func(T) (R, error) 形式的函数，
+// 不合法的转换器会被忽略并记录日志
 func (t *TaskModule) AddConverter(converters ...interface{}) {
+	t.converterLock.Lock()
 	t.Converter = append(t.Converter, converters...)
+	t.converterLock.Unlock()
+	t.syncConverters()
+}
+
+// syncConverters 把直接追加到 Converter 的转换器注册到 Converters
+func (t *TaskModule) syncConverters() {
+	t.converterLock.Lock()
+	defer t.converterLock.Unlock()
+	if t.syncedConverters > len(t.Converter) {
+		t.syncedConverters = len(t.Converter)
+	}
+	for _, converter := range t.Converter[t.syncedConverters:] {
+		err := t.Converters.Register(converter)
+		if err != nil {
+			TaskLogger.Error(fmt.Sprintf("register converter failed: %v", err))
+		}
+	}
+	t.syncedConverters = len(t.Converter)
 }
 func (t *TaskModule) SerializerTemplateList() (interface{}, error) {
-	list := make([]interface{}, 0)
-	for _, task := range t.Pool.Tasks {
+	return t.SerializerTemplates(t.Pool.Snapshot())
+}
+
+// SerializerTemplates 序列化指定的任务列表
+func (t *TaskModule) SerializerTemplates(tasks []Task) ([]interface{}, error) {
+	list := make([]interface{}, 0, len(tasks))
+	for _, task := range tasks {
 		tp, err := t.SerializerTemplate(task)
 		if err != nil {
 			return nil, err
@@ -84,9 +297,21 @@ func (t *TaskModule) SerializerTemplate(data Task) (interface{}, error) {
 		Id:           data.GetId(),
 		Type:         data.GetType(),
 		Status:       data.GetStatus(),
-		Created:      data.GetCreated().Format("2006-01-02 15:04:05"),
+		Created:      data.GetCreated().Format(templateTimeFormat),
 		ParentTaskId: data.GetParentTaskId(),
 	}
+	if ownerTask, ok := data.(TaskWithOwner); ok {
+		template.Owner = ownerTask.GetOwner()
+	}
+	if transitionTask, ok := data.(TaskWithTransitions); ok {
+		template.Transitions = transitionTask.GetTransitions()
+	}
+	if attemptTask, ok := data.(TaskWithAttempts); ok {
+		template.Attempts = attemptTask.GetAttempts()
+	}
+	if progressTask, ok := data.(TaskWithProgress); ok {
+		template.Progress = progressTask.GetProgress()
+	}
 	if data.Error() != nil {
 		template.Err = data.Error().Error()
 	}
@@ -94,7 +319,8 @@ func (t *TaskModule) SerializerTemplate(data Task) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	template.Output, err = t.SerializerTemplateOutput(output)
+	t.syncConverters()
+	template.Output, err = t.Converters.Convert(data.GetType(), output)
 	if err != nil {
 		return nil, err
 	}
@@ -109,30 +335,17 @@ func (t *TaskModule) SerializerTemplate(data Task) (interface{}, error) {
 		}
 	}
 	if !data.GetStartTime().IsZero() {
-		template.StartTime = data.GetStartTime().Format("2006-01-02 15:04:05")
+		template.StartTime = data.GetStartTime().Format(templateTimeFormat)
 	}
 	if !data.GetEndTime().IsZero() {
-		template.EndTime = data.GetEndTime().Format("2006-01-02 15:04:05")
+		template.EndTime = data.GetEndTime().Format(templateTimeFormat)
 		template.Duration = uint(data.GetEndTime().Sub(data.GetStartTime()).Milliseconds())
 	}
 	return template, nil
 }
+
+// SerializerTemplateOutput 使用不限任务类型的转换器转换输出
 func (t *TaskModule) SerializerTemplateOutput(data interface{}) (interface{}, error) {
-	dataTypeRef := reflect.TypeOf(data)
-	var dataConverter interface{} = nil
-	for _, converter := range t.Converter {
-		converterTypeRef := reflect.TypeOf(converter)
-		if converterTypeRef.In(0) == dataTypeRef {
-			dataConverter = converter
-		}
-	}
-	if dataConverter == nil {
-		return data, nil
-	}
-	dataConverterValueRef := reflect.ValueOf(dataConverter)
-	resultValues := dataConverterValueRef.Call([]reflect.Value{reflect.ValueOf(data)})
-	if resultValues[1].Interface() == nil {
-		return resultValues[0].Interface(), nil
-	}
-	return resultValues[0].Interface(), resultValues[0].Interface().(error)
+	t.syncConverters()
+	return t.Converters.Convert("", data)
 }
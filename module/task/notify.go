@@ -0,0 +1,47 @@
+package task
+
+import (
+	"time"
+)
+
+// TaskChangeEvent 推送给前端的任务变化事件名
+const TaskChangeEvent = "TaskChange"
+
+// DefaultNotifyInterval 同一任务进度推送的最小间隔
+const DefaultNotifyInterval = 500 * time.Millisecond
+
+// Notifier 按用户推送消息，notification.NotificationManager 实现了该接口
+type Notifier interface {
+	SendJSONToUser(data interface{}, username string)
+}
+
+// TaskNotification 推送的任务变化消息
+type TaskNotification struct {
+	Event string    `json:"event"`
+	Task  *Template `json:"task"`
+}
+
+// EnableNotification 将任务池中任务的变化推送给任务所属用户（BaseTask.Owner），没有所属用户的任务不推送。
+// 状态变化立即推送，进度更新按 interval 合并，interval 小于等于 0 时使用 DefaultNotifyInterval
+func (t *TaskModule) EnableNotification(notifier Notifier, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultNotifyInterval
+	}
+	throttle := newChangeThrottle(interval, func(task Task) {
+		ownerTask, ok := task.(TaskWithOwner)
+		if !ok || ownerTask.GetOwner() == "" {
+			return
+		}
+		data, err := t.SerializerTemplate(task)
+		if err != nil {
+			TaskLogger.WithField("id", task.GetId()).Error(err)
+			return
+		}
+		notifier.SendJSONToUser(&TaskNotification{
+			Event: TaskChangeEvent,
+			Task:  data.(*Template),
+		}, ownerTask.GetOwner())
+	})
+	t.Pool.AddChangeListener(throttle.onChange)
+	t.Pool.AddRemoveListener(throttle.onRemove)
+}
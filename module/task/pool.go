@@ -7,7 +7,9 @@ import (
 
 type TaskPool struct {
 	sync.Mutex
-	Tasks []Task
+	Tasks           []Task
+	changeListeners []func(task Task)
+	removeListeners []func(id string)
 }
 
 func NewTaskPool() *TaskPool {
@@ -16,24 +18,57 @@ func NewTaskPool() *TaskPool {
 	}
 }
 
-func (p *TaskPool) RemoveTaskById(id string) {
+// AddChangeListener 注册任务变化回调，任务加入、状态或时间变化时调用，参数为池中的顶层任务
+func (p *TaskPool) AddChangeListener(listener func(task Task)) {
+	p.Lock()
+	defer p.Unlock()
+	p.changeListeners = append(p.changeListeners, listener)
+}
+
+// AddRemoveListener 注册任务移除回调
+func (p *TaskPool) AddRemoveListener(listener func(id string)) {
 	p.Lock()
 	defer p.Unlock()
+	p.removeListeners = append(p.removeListeners, listener)
+}
+
+func (p *TaskPool) emitChange(task Task) {
+	p.Lock()
+	listeners := p.changeListeners
+	p.Unlock()
+	for _, listener := range listeners {
+		listener(task)
+	}
+}
+
+func (p *TaskPool) RemoveTaskById(id string) {
+	p.Lock()
 	var newTask []Task
 	From(p.Tasks).WhereT(func(task Task) bool {
 		return task.GetId() != id
 	}).ToSlice(&newTask)
 	p.Tasks = newTask
+	listeners := p.removeListeners
+	p.Unlock()
+	for _, listener := range listeners {
+		listener(id)
+	}
 }
 
 func (p *TaskPool) AddTask(task Task) {
 	p.Lock()
-	defer p.Unlock()
 	p.Tasks = append(p.Tasks, task)
+	p.Unlock()
+	if notifier, ok := task.(changeNotifier); ok {
+		notifier.bindChangeListener(func() {
+			p.emitChange(task)
+		})
+	}
+	p.emitChange(task)
 }
 
 func (p *TaskPool) GetTaskWithStatus(taskType string, status string) Task {
-	for _, task := range p.Tasks {
+	for _, task := range p.Snapshot() {
 		if task.GetType() == taskType {
 			if task.GetStatus() == status {
 				return task
@@ -44,11 +79,7 @@ func (p *TaskPool) GetTaskWithStatus(taskType string, status string) Task {
 }
 
 func (p *TaskPool) GetTaskById(id string) Task {
-	if len(p.Tasks) == 0 {
-		return nil
-	}
-	queue := make([]Task, 0)
-	queue = append(queue, p.Tasks...)
+	queue := p.Snapshot()
 	for len(queue) > 0 {
 		task := queue[0]
 		queue = queue[1:]
@@ -0,0 +1,64 @@
+package task
+
+import (
+	"math"
+	"time"
+)
+
+// TaskProgress 任务进度
+type TaskProgress struct {
+	Current int64   `json:"current"`
+	Total   int64   `json:"total"`
+	Percent float64 `json:"percent"`
+	Message string  `json:"message,omitempty"`
+	// ETA 预计剩余秒数，无法估算时为 0
+	ETA int64 `json:"eta,omitempty"`
+}
+
+// TaskWithProgress 可选接口：提供任务进度，BaseTask 已实现
+type TaskWithProgress interface {
+	GetProgress() *TaskProgress
+}
+
+// SetProgress 更新进度，total 为 0 表示总量未知；ETA 按开始上报进度以来的平均速度估算
+func (t *BaseTask) SetProgress(current int64, total int64, message string) {
+	t.lock.Lock()
+	now := time.Now()
+	if t.progressStart.IsZero() {
+		t.progressStart = now
+		if !t.StartTime.IsZero() {
+			t.progressStart = t.StartTime
+		}
+	}
+	progress := &TaskProgress{
+		Current: current,
+		Total:   total,
+		Message: message,
+	}
+	if total > 0 {
+		progress.Percent = math.Round(float64(current)/float64(total)*10000) / 100
+		if current > 0 && current < total {
+			// 使用浮点数计算，总量很大时 Duration 相乘会溢出
+			eta := now.Sub(t.progressStart).Seconds() * float64(total-current) / float64(current)
+			if eta >= math.MaxInt64 {
+				progress.ETA = math.MaxInt64
+			} else {
+				progress.ETA = int64(eta)
+			}
+		}
+	}
+	t.Progress = progress
+	t.lock.Unlock()
+	t.notifyChange()
+}
+
+// GetProgress 返回进度副本，未上报进度时返回 nil
+func (t *BaseTask) GetProgress() *TaskProgress {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.Progress == nil {
+		return nil
+	}
+	progress := *t.Progress
+	return &progress
+}
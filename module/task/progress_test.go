@@ -0,0 +1,50 @@
+package task
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSetProgress(t *testing.T) {
+	task := NewBaseTask("scan", "", StatusNameMapping[StatusRunning])
+	if task.GetProgress() != nil {
+		t.Fatal("progress should be empty before reporting")
+	}
+	task.StartTime = time.Now().Add(-10 * time.Second)
+	task.SetProgress(25, 100, "scanning")
+	progress := task.GetProgress()
+	if progress.Percent != 25 || progress.Message != "scanning" {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+	// 10 秒完成 25%，剩余 75% 预计 30 秒
+	if progress.ETA != 30 {
+		t.Fatalf("unexpected eta %d", progress.ETA)
+	}
+	task.SetProgress(1, 3, "")
+	if progress = task.GetProgress(); progress.Percent != 33.33 {
+		t.Fatalf("percent should keep two decimals, got %v", progress.Percent)
+	}
+	task.SetProgress(100, 100, "")
+	if progress = task.GetProgress(); progress.Percent != 100 || progress.ETA != 0 {
+		t.Fatalf("finished progress should have no eta, got %+v", progress)
+	}
+	task.SetProgress(5, 0, "")
+	if progress = task.GetProgress(); progress.Percent != 0 || progress.ETA != 0 {
+		t.Fatalf("unknown total should have no percent, got %+v", progress)
+	}
+}
+
+func TestSetProgressLargeTotal(t *testing.T) {
+	task := NewBaseTask("scan", "", StatusNameMapping[StatusRunning])
+	task.StartTime = time.Now().Add(-10 * time.Second)
+	// 10 秒完成一百万分之一，剩余约 9999990 秒
+	task.SetProgress(1000000, 1000000000000, "")
+	if eta := task.GetProgress().ETA; eta < 9999990 || eta > 10000100 {
+		t.Fatalf("unexpected eta %d", eta)
+	}
+	task.SetProgress(1, math.MaxInt64, "")
+	if eta := task.GetProgress().ETA; eta != math.MaxInt64 {
+		t.Fatalf("eta should be clamped, got %d", eta)
+	}
+}
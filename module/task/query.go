@@ -0,0 +1,238 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/allentom/haruka"
+)
+
+const (
+	// DefaultListPageSize ListHandler 只指定 page 时的分页大小
+	DefaultListPageSize = 50
+	// MaxListPageSize ListHandler 允许的最大分页大小
+	MaxListPageSize = 500
+)
+
+const (
+	OrderByCreated   = "created"
+	OrderByStartTime = "startTime"
+	OrderByEndTime   = "endTime"
+)
+
+// TaskQuery 任务池查询条件，零值字段不参与过滤
+type TaskQuery struct {
+	Types    []string
+	Statuses []string
+	Owner    string
+	// ParentTaskId 不为空时在包括子任务在内的所有任务中查找该任务的子任务
+	ParentTaskId  string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// OrderBy 排序字段，为空时保持任务加入任务池的顺序
+	OrderBy string
+	Desc    bool
+	// Page 从 1 开始，PageSize 小于等于 0 时不分页
+	Page     int
+	PageSize int
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *TaskQuery) match(task Task) bool {
+	if len(q.Types) > 0 && !containsString(q.Types, task.GetType()) {
+		return false
+	}
+	if len(q.Statuses) > 0 && !containsString(q.Statuses, task.GetStatus()) {
+		return false
+	}
+	if q.Owner != "" {
+		ownerTask, ok := task.(TaskWithOwner)
+		if !ok || ownerTask.GetOwner() != q.Owner {
+			return false
+		}
+	}
+	if q.ParentTaskId != "" && task.GetParentTaskId() != q.ParentTaskId {
+		return false
+	}
+	if !q.CreatedAfter.IsZero() && task.GetCreated().Before(q.CreatedAfter) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && task.GetCreated().After(q.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+func (q *TaskQuery) sortKey(task Task) time.Time {
+	switch q.OrderBy {
+	case OrderByStartTime:
+		return task.GetStartTime()
+	case OrderByEndTime:
+		return task.GetEndTime()
+	case OrderByCreated:
+		return task.GetCreated()
+	}
+	return time.Time{}
+}
+
+// Snapshot 返回任务池中顶层任务的副本，遍历时不需要持有锁
+func (p *TaskPool) Snapshot() []Task {
+	p.Lock()
+	defer p.Unlock()
+	return append([]Task{}, p.Tasks...)
+}
+
+// flatten 广度优先展开任务及其子任务
+func flatten(tasks []Task) []Task {
+	result := make([]Task, 0, len(tasks))
+	queue := append([]Task{}, tasks...)
+	for len(queue) > 0 {
+		task := queue[0]
+		queue = queue[1:]
+		result = append(result, task)
+		queue = append(queue, task.SubTask()...)
+	}
+	return result
+}
+
+// Query 按条件查询任务，返回当前页的任务与符合条件的总数
+func (p *TaskPool) Query(query *TaskQuery) ([]Task, int) {
+	tasks := p.Snapshot()
+	if query.ParentTaskId != "" {
+		tasks = flatten(tasks)
+	}
+	type sortItem struct {
+		task Task
+		key  time.Time
+	}
+	items := make([]sortItem, 0)
+	for _, task := range tasks {
+		if query.match(task) {
+			items = append(items, sortItem{task: task, key: query.sortKey(task)})
+		}
+	}
+	if query.OrderBy != "" {
+		sort.SliceStable(items, func(i, j int) bool {
+			if query.Desc {
+				return items[i].key.After(items[j].key)
+			}
+			return items[i].key.Before(items[j].key)
+		})
+	} else if query.Desc {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	result := make([]Task, 0, len(items))
+	for _, item := range items {
+		result = append(result, item.task)
+	}
+	total := len(result)
+	if query.PageSize > 0 {
+		page := query.Page
+		if page < 1 {
+			page = 1
+		}
+		start := (page - 1) * query.PageSize
+		if start >= total {
+			return []Task{}, total
+		}
+		end := start + query.PageSize
+		if end > total {
+			end = total
+		}
+		result = result[start:end]
+	}
+	return result, total
+}
+
+// parseQueryList 支持重复参数与逗号分隔两种写法
+func parseQueryList(context *haruka.Context, key string) []string {
+	values := make([]string, 0)
+	for _, raw := range context.GetQueryStrings(key) {
+		for _, value := range strings.Split(raw, ",") {
+			value = strings.TrimSpace(value)
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// parseQueryTime 支持 Template 的时间格式与 RFC3339
+func parseQueryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.ParseInLocation(templateTimeFormat, value, time.Local)
+	if err == nil {
+		return parsed, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// ParseTaskQuery 解析 ListHandler 的查询参数：
+// type status owner parent createdAfter createdBefore order(created|startTime|endTime) sort(asc|desc) page pageSize。
+// 不带排序与分页参数时与旧版本一致，按加入顺序返回全部任务；指定 order 时默认倒序，
+// 只指定 page 时每页 DefaultListPageSize 条
+func ParseTaskQuery(context *haruka.Context) (*TaskQuery, error) {
+	query := &TaskQuery{
+		Types:        parseQueryList(context, "type"),
+		Statuses:     parseQueryList(context, "status"),
+		Owner:        context.GetQueryString("owner"),
+		ParentTaskId: context.GetQueryString("parent"),
+		OrderBy:      context.GetQueryString("order"),
+		Page:         1,
+	}
+	switch context.GetQueryString("sort") {
+	case "":
+		query.Desc = query.OrderBy != ""
+	case "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return nil, fmt.Errorf("unknown sort: %s", context.GetQueryString("sort"))
+	}
+	switch query.OrderBy {
+	case "", OrderByCreated, OrderByStartTime, OrderByEndTime:
+	default:
+		return nil, fmt.Errorf("unknown order field: %s", query.OrderBy)
+	}
+	var err error
+	query.CreatedAfter, err = parseQueryTime(context.GetQueryString("createdAfter"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid createdAfter: %v", err)
+	}
+	query.CreatedBefore, err = parseQueryTime(context.GetQueryString("createdBefore"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid createdBefore: %v", err)
+	}
+	if context.GetQueryString("page") != "" {
+		query.PageSize = DefaultListPageSize
+		query.Page, err = context.GetQueryInt("page")
+		if err != nil || query.Page < 1 {
+			return nil, fmt.Errorf("invalid page: %s", context.GetQueryString("page"))
+		}
+	}
+	if context.GetQueryString("pageSize") != "" {
+		query.PageSize, err = context.GetQueryInt("pageSize")
+		if err != nil || query.PageSize < 1 {
+			return nil, fmt.Errorf("invalid pageSize: %s", context.GetQueryString("pageSize"))
+		}
+		if query.PageSize > MaxListPageSize {
+			query.PageSize = MaxListPageSize
+		}
+	}
+	return query, nil
+}
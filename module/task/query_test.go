@@ -0,0 +1,81 @@
+package task
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/allentom/haruka"
+)
+
+func newQueryContext(query string) *haruka.Context {
+	return &haruka.Context{
+		Request: httptest.NewRequest("GET", "/tasks?"+query, nil),
+		Param:   map[string]interface{}{},
+	}
+}
+
+func newQueryPool(count int) (*TaskPool, []Task) {
+	pool := NewTaskPool()
+	tasks := make([]Task, 0, count)
+	created := time.Now()
+	for i := 0; i < count; i++ {
+		task := &outputTask{BaseTask: NewBaseTask("scan", "", StatusNameMapping[StatusDone])}
+		// 创建时间与加入顺序相反，用于区分两种排序
+		task.Created = created.Add(-time.Duration(i) * time.Minute)
+		pool.AddTask(task)
+		tasks = append(tasks, task)
+	}
+	return pool, tasks
+}
+
+func TestParseTaskQueryDefaults(t *testing.T) {
+	pool, tasks := newQueryPool(60)
+	query, err := ParseTaskQuery(newQueryContext(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, count := pool.Query(query)
+	if count != 60 || len(result) != 60 {
+		t.Fatalf("default query should return all tasks, got %d of %d", len(result), count)
+	}
+	for i, task := range result {
+		if task != tasks[i] {
+			t.Fatalf("default query should keep insertion order, index %d", i)
+		}
+	}
+}
+
+func TestParseTaskQueryOrderAndPage(t *testing.T) {
+	pool, tasks := newQueryPool(60)
+	query, err := ParseTaskQuery(newQueryContext("order=created&page=2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !query.Desc || query.PageSize != DefaultListPageSize {
+		t.Fatalf("order should default to desc and page to default size, got %+v", query)
+	}
+	result, count := pool.Query(query)
+	if count != 60 || len(result) != 10 {
+		t.Fatalf("expect second page of 10, got %d of %d", len(result), count)
+	}
+	// 创建时间倒序即加入顺序
+	if result[0] != tasks[50] {
+		t.Fatal("second page should start at the 51st newest task")
+	}
+
+	query, err = ParseTaskQuery(newQueryContext("order=created&sort=asc&pageSize=5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, _ = pool.Query(query)
+	if len(result) != 5 || result[0] != tasks[59] {
+		t.Fatal("ascending order should start with the oldest task")
+	}
+
+	for _, invalid := range []string{"order=name", "sort=up", "page=0", "pageSize=x"} {
+		if _, err := ParseTaskQuery(newQueryContext(invalid)); err == nil {
+			t.Fatalf("%s should be rejected", invalid)
+		}
+	}
+}
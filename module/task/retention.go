@@ -0,0 +1,190 @@
+package task
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/allentom/haruka"
+)
+
+// DefaultRetentionInterval 后台清理的默认间隔
+const DefaultRetentionInterval = 10 * time.Minute
+
+// RetentionPolicy 已结束任务的保留策略，只清理任务池中的顶层任务，零值字段表示不限制
+type RetentionPolicy struct {
+	// MaxAge 已结束任务的最长保留时间，从结束时间开始计算
+	MaxAge time.Duration
+	// ErrorMaxAge 出错与中断任务的最长保留时间，为 0 时使用 MaxAge，通常设置得比 MaxAge 长以便排查
+	ErrorMaxAge time.Duration
+	// MaxCountPerType 每种类型最多保留的已完成任务数，出错与中断任务不计入，只受 ErrorMaxAge 限制
+	MaxCountPerType int
+}
+
+func isErrorStatus(status string) bool {
+	return status == StatusNameMapping[StatusError] || status == StatusNameMapping[StatusInterrupted]
+}
+
+func finishedAt(task Task) time.Time {
+	if !task.GetEndTime().IsZero() {
+		return task.GetEndTime()
+	}
+	return task.GetCreated()
+}
+
+// Expired 返回 tasks 中按策略应清理的任务 id
+func (p *RetentionPolicy) Expired(tasks []Task, now time.Time) []string {
+	expired := make([]string, 0)
+	kept := map[string][]Task{}
+	for _, task := range tasks {
+		status := task.GetStatus()
+		if !IsFinishedStatus(status) {
+			continue
+		}
+		maxAge := p.MaxAge
+		if isErrorStatus(status) && p.ErrorMaxAge > 0 {
+			maxAge = p.ErrorMaxAge
+		}
+		if maxAge > 0 && now.Sub(finishedAt(task)) > maxAge {
+			expired = append(expired, task.GetId())
+			continue
+		}
+		if !isErrorStatus(status) {
+			kept[task.GetType()] = append(kept[task.GetType()], task)
+		}
+	}
+	if p.MaxCountPerType > 0 {
+		for _, typeTasks := range kept {
+			if len(typeTasks) <= p.MaxCountPerType {
+				continue
+			}
+			sort.SliceStable(typeTasks, func(i, j int) bool {
+				return finishedAt(typeTasks[i]).After(finishedAt(typeTasks[j]))
+			})
+			for _, task := range typeTasks[p.MaxCountPerType:] {
+				expired = append(expired, task.GetId())
+			}
+		}
+	}
+	return expired
+}
+
+// RemoveTasks 批量移除顶层任务
+func (p *TaskPool) RemoveTasks(ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	removeSet := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		removeSet[id] = true
+	}
+	p.Lock()
+	newTask := make([]Task, 0, len(p.Tasks))
+	for _, task := range p.Tasks {
+		if !removeSet[task.GetId()] {
+			newTask = append(newTask, task)
+		}
+	}
+	p.Tasks = newTask
+	listeners := p.removeListeners
+	p.Unlock()
+	for _, id := range ids {
+		for _, listener := range listeners {
+			listener(id)
+		}
+	}
+}
+
+// RetentionSweeper 按策略定期清理任务池
+type RetentionSweeper struct {
+	Pool     *TaskPool
+	Policy   *RetentionPolicy
+	Interval time.Duration
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// Sweep 立即执行一次清理，返回清理的任务数
+func (s *RetentionSweeper) Sweep() int {
+	expired := s.Policy.Expired(s.Pool.Snapshot(), time.Now())
+	s.Pool.RemoveTasks(expired)
+	if len(expired) > 0 {
+		TaskLogger.WithField("count", len(expired)).Info("retention removed finished tasks")
+	}
+	return len(expired)
+}
+
+// Start 在后台按 Interval 定期清理
+func (s *RetentionSweeper) Start() {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultRetentionInterval
+	}
+	s.stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.Sweep()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台清理
+func (s *RetentionSweeper) Stop() {
+	s.once.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+		}
+	})
+}
+
+// EnableRetention 启用保留策略：立即清理一次，之后每 interval 清理一次
+func (t *TaskModule) EnableRetention(policy *RetentionPolicy, interval time.Duration) *RetentionSweeper {
+	sweeper := &RetentionSweeper{
+		Pool:     t.Pool,
+		Policy:   policy,
+		Interval: interval,
+	}
+	sweeper.Sweep()
+	sweeper.Start()
+	t.Retention = sweeper
+	return sweeper
+}
+
+// ClearFinished 移除所有已结束的顶层任务，taskType 与 owner 不为空时只移除匹配的任务，返回移除的任务数
+func (t *TaskModule) ClearFinished(taskType string, owner string) int {
+	ids := make([]string, 0)
+	for _, task := range t.Pool.Snapshot() {
+		if !IsFinishedStatus(task.GetStatus()) {
+			continue
+		}
+		if taskType != "" && task.GetType() != taskType {
+			continue
+		}
+		if owner != "" {
+			ownerTask, ok := task.(TaskWithOwner)
+			if !ok || ownerTask.GetOwner() != owner {
+				continue
+			}
+		}
+		ids = append(ids, task.GetId())
+	}
+	t.Pool.RemoveTasks(ids)
+	return len(ids)
+}
+
+func (t *TaskModule) clearFinishedHandler(context *haruka.Context) {
+	count := t.ClearFinished(context.GetQueryString("type"), context.GetQueryString("owner"))
+	context.JSON(haruka.JSON{
+		"success": true,
+		"data": haruka.JSON{
+			"count": count,
+		},
+	})
+}
@@ -0,0 +1,95 @@
+package task
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newFinishedTask(id string, taskType string, status string, end time.Time) *outputTask {
+	task := &outputTask{BaseTask: NewBaseTask(taskType, "bob", status)}
+	task.Id = id
+	task.EndTime = end
+	return task
+}
+
+func sortedIds(ids []string) string {
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestRetentionPolicyExpired(t *testing.T) {
+	now := time.Now()
+	tasks := []Task{
+		newFinishedTask("old", "scan", StatusNameMapping[StatusDone], now.Add(-2*time.Hour)),
+		newFinishedTask("recent", "scan", StatusNameMapping[StatusDone], now.Add(-time.Minute)),
+		newFinishedTask("failed", "scan", StatusNameMapping[StatusError], now.Add(-2*time.Hour)),
+		newFinishedTask("failed-old", "scan", StatusNameMapping[StatusInterrupted], now.Add(-48*time.Hour)),
+		newFinishedTask("running", "scan", StatusNameMapping[StatusRunning], now.Add(-48*time.Hour)),
+	}
+	policy := &RetentionPolicy{MaxAge: time.Hour, ErrorMaxAge: 24 * time.Hour}
+	if got := sortedIds(policy.Expired(tasks, now)); got != "failed-old,old" {
+		t.Fatalf("unexpected expired tasks %s", got)
+	}
+	policy = &RetentionPolicy{MaxAge: time.Hour}
+	if got := sortedIds(policy.Expired(tasks, now)); got != "failed,failed-old,old" {
+		t.Fatalf("error tasks should use MaxAge by default, got %s", got)
+	}
+}
+
+func TestRetentionPolicyMaxCountPerType(t *testing.T) {
+	now := time.Now()
+	tasks := []Task{
+		newFinishedTask("scan-1", "scan", StatusNameMapping[StatusDone], now.Add(-3*time.Minute)),
+		newFinishedTask("scan-2", "scan", StatusNameMapping[StatusDone], now.Add(-time.Minute)),
+		newFinishedTask("scan-3", "scan", StatusNameMapping[StatusCancelled], now.Add(-2*time.Minute)),
+		newFinishedTask("scan-error", "scan", StatusNameMapping[StatusError], now.Add(-4*time.Minute)),
+		newFinishedTask("tag-1", "tag", StatusNameMapping[StatusDone], now.Add(-4*time.Minute)),
+	}
+	policy := &RetentionPolicy{MaxCountPerType: 2}
+	if got := sortedIds(policy.Expired(tasks, now)); got != "scan-1" {
+		t.Fatalf("oldest task beyond count should expire, got %s", got)
+	}
+}
+
+func TestRetentionSweep(t *testing.T) {
+	pool := NewTaskPool()
+	now := time.Now()
+	pool.AddTask(newFinishedTask("old", "scan", StatusNameMapping[StatusDone], now.Add(-2*time.Hour)))
+	pool.AddTask(newFinishedTask("recent", "scan", StatusNameMapping[StatusDone], now))
+	removed := make([]string, 0)
+	pool.AddRemoveListener(func(id string) {
+		removed = append(removed, id)
+	})
+	sweeper := &RetentionSweeper{Pool: pool, Policy: &RetentionPolicy{MaxAge: time.Hour}}
+	if count := sweeper.Sweep(); count != 1 {
+		t.Fatalf("expected one task removed, got %d", count)
+	}
+	if pool.GetTaskById("old") != nil || pool.GetTaskById("recent") == nil {
+		t.Fatal("only expired task should be removed")
+	}
+	if sortedIds(removed) != "old" {
+		t.Fatalf("remove listener should be notified, got %v", removed)
+	}
+	if count := sweeper.Sweep(); count != 0 {
+		t.Fatalf("second sweep should remove nothing, got %d", count)
+	}
+}
+
+func TestClearFinished(t *testing.T) {
+	module := NewTaskModule()
+	now := time.Now()
+	module.Pool.AddTask(newFinishedTask("scan", "scan", StatusNameMapping[StatusDone], now))
+	module.Pool.AddTask(newFinishedTask("tag", "tag", StatusNameMapping[StatusError], now))
+	module.Pool.AddTask(newFinishedTask("running", "scan", StatusNameMapping[StatusRunning], now))
+	if count := module.ClearFinished("scan", ""); count != 1 {
+		t.Fatalf("expected one scan task removed, got %d", count)
+	}
+	if count := module.ClearFinished("", "alice"); count != 0 {
+		t.Fatalf("tasks of other owners should be kept, got %d", count)
+	}
+	if count := module.ClearFinished("", ""); count != 1 || module.Pool.GetTaskById("running") == nil {
+		t.Fatal("running task should be kept")
+	}
+}
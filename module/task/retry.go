@@ -0,0 +1,224 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrTaskNotRetryable 只有 Error 状态的任务可以重试
+var ErrTaskNotRetryable = errors.New("only errored task can be retried")
+
+// Attempt 一次执行记录
+type Attempt struct {
+	Number    int    `json:"number"`
+	StartTime string `json:"startTime"`
+	EndTime   string `json:"endTime"`
+	Err       string `json:"err,omitempty"`
+}
+
+// TaskWithAttempts 可选接口：提供执行记录，BaseTask 已实现
+type TaskWithAttempts interface {
+	GetAttempts() []*Attempt
+}
+
+// TaskWithRetry 可选接口：可以使用相同输入重新执行的任务，BaseTask 已实现
+type TaskWithRetry interface {
+	ResetForRetry() error
+}
+
+type attemptRecorder interface {
+	recordAttempt(start time.Time, err error)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent 标记错误不可重试，Start 返回该错误时不再重试
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 错误是否被标记为不可重试
+func IsPermanent(err error) bool {
+	var target *permanentError
+	return errors.As(err, &target)
+}
+
+// RetryPolicy 重试策略，退避时间为 InitialBackoff * Multiplier^(n-1)，不超过 MaxBackoff
+type RetryPolicy struct {
+	// MaxAttempts 最多执行次数（包括第一次），小于等于 1 时不重试
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Multiplier 退避倍数，默认 2
+	Multiplier float64
+	// Jitter 随机抖动比例（0-1），实际退避在 [d*(1-Jitter), d] 之间
+	Jitter float64
+	// Retryable 判断错误是否可重试，为空时除取消与 Permanent 外的错误都会重试
+	Retryable func(err error) bool
+}
+
+// IsRetryable 取消与 Permanent 错误总是不可重试
+func (p *RetryPolicy) IsRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || IsPermanent(err) {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return true
+}
+
+// Backoff 第 attempt 次执行失败后的等待时间
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		backoff -= backoff * jitter * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+// RetryPolicies 按任务类型保存重试策略
+type RetryPolicies struct {
+	sync.RWMutex
+	policies map[string]*RetryPolicy
+}
+
+func NewRetryPolicies() *RetryPolicies {
+	return &RetryPolicies{
+		policies: map[string]*RetryPolicy{},
+	}
+}
+
+// Set 设置任务类型的重试策略，policy 为空时删除
+func (r *RetryPolicies) Set(taskType string, policy *RetryPolicy) {
+	r.Lock()
+	defer r.Unlock()
+	if policy == nil {
+		delete(r.policies, taskType)
+		return
+	}
+	r.policies[taskType] = policy
+}
+
+// Get 返回任务类型的重试策略，未设置时返回 nil
+func (r *RetryPolicies) Get(taskType string) *RetryPolicy {
+	if r == nil {
+		return nil
+	}
+	r.RLock()
+	defer r.RUnlock()
+	return r.policies[taskType]
+}
+
+// RunTaskWithRetry 与 RunTask 相同，但在 Start 失败时按 policy 重试并记录每次执行，policy 为空时只执行一次。
+// 退避等待期间任务状态为 Retrying，任务停止时立即结束
+func RunTaskWithRetry(wrap Wrap, policy *RetryPolicy) error {
+	wrap.SetStart()
+	ctx := context.Background()
+	if contextTask, ok := wrap.(interface{ Context() context.Context }); ok {
+		ctx = contextTask.Context()
+	}
+	setter, _ := wrap.(statusSetter)
+	// attempt 为本次运行的执行次数，手动重试时重新计算，执行记录中的序号则持续累加
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := wrap.Start()
+		if recorder, ok := wrap.(attemptRecorder); ok {
+			recorder.recordAttempt(start, err)
+		}
+		if err == nil {
+			wrap.Done()
+			return nil
+		}
+		if policy == nil || attempt >= policy.MaxAttempts || !policy.IsRetryable(err) || ctx.Err() != nil {
+			return wrap.AbortError(err)
+		}
+		if setter != nil {
+			setter.SetStatus(StatusNameMapping[StatusRetrying])
+		}
+		timer := time.NewTimer(policy.Backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return wrap.AbortError(err)
+		}
+		if setter != nil {
+			setter.SetStatus(StatusNameMapping[StatusRunning])
+		}
+	}
+}
+
+// GetAttempts 返回执行记录
+func (t *BaseTask) GetAttempts() []*Attempt {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]*Attempt{}, t.Attempts...)
+}
+
+// recordAttempt 记录一次执行
+func (t *BaseTask) recordAttempt(start time.Time, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	attempt := &Attempt{
+		Number:    len(t.Attempts) + 1,
+		StartTime: start.Format(templateTimeFormat),
+		EndTime:   time.Now().Format(templateTimeFormat),
+	}
+	if err != nil {
+		attempt.Err = err.Error()
+	}
+	t.Attempts = append(t.Attempts, attempt)
+}
+
+// ResetForRetry 将出错的任务重置为 Pending 以便使用相同输入重新执行。
+// 之前通过 RunTask 执行、没有执行记录的任务会先补记一次执行
+func (t *BaseTask) ResetForRetry() error {
+	t.lock.Lock()
+	if t.Status != StatusNameMapping[StatusError] {
+		t.lock.Unlock()
+		return ErrTaskNotRetryable
+	}
+	if len(t.Attempts) == 0 && !t.StartTime.IsZero() {
+		attempt := &Attempt{
+			Number:    1,
+			StartTime: t.StartTime.Format(templateTimeFormat),
+			EndTime:   t.EndTime.Format(templateTimeFormat),
+		}
+		if t.Err != nil {
+			attempt.Err = t.Err.Error()
+		}
+		t.Attempts = append(t.Attempts, attempt)
+	}
+	t.Err = nil
+	t.EndTime = time.Time{}
+	t.recordStatus(StatusNameMapping[StatusPending])
+	t.lock.Unlock()
+	t.notifyChange()
+	return nil
+}
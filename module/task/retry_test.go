@@ -0,0 +1,126 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("flaky")
+
+// flakyTask 前 failures 次执行返回 err
+type flakyTask struct {
+	*BaseTask
+	failures int
+	err      error
+	runs     int
+}
+
+func (f *flakyTask) Start() error {
+	f.runs++
+	if f.runs <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyTask) Output() (interface{}, error) {
+	return nil, nil
+}
+
+func newFlakyTask(failures int, err error) *flakyTask {
+	return &flakyTask{BaseTask: NewBaseTask("scan", "", StatusNameMapping[StatusPending]), failures: failures, err: err}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for index, backoff := range expected {
+		if got := policy.Backoff(index + 1); got != backoff*time.Millisecond {
+			t.Fatalf("attempt %d: expected %v, got %v", index+1, backoff*time.Millisecond, got)
+		}
+	}
+	policy = &RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 3}
+	if got := policy.Backoff(3); got != 900*time.Millisecond {
+		t.Fatalf("unexpected backoff with multiplier %v", got)
+	}
+	policy = &RetryPolicy{InitialBackoff: 100 * time.Millisecond, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if got := policy.Backoff(2); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("jitter out of range %v", got)
+		}
+	}
+}
+
+func TestRetryPolicyIsRetryable(t *testing.T) {
+	policy := &RetryPolicy{}
+	if !policy.IsRetryable(errFlaky) {
+		t.Fatal("plain error should be retryable")
+	}
+	if policy.IsRetryable(fmt.Errorf("wrapped: %w", Permanent(errFlaky))) {
+		t.Fatal("permanent error should not be retryable")
+	}
+	if policy.IsRetryable(context.Canceled) {
+		t.Fatal("cancellation should not be retryable")
+	}
+	policy.Retryable = func(err error) bool {
+		return !errors.Is(err, errFlaky)
+	}
+	if policy.IsRetryable(errFlaky) {
+		t.Fatal("custom retryable should be used")
+	}
+}
+
+func TestRunTaskWithRetry(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	task := newFlakyTask(2, errFlaky)
+	if err := RunTaskWithRetry(task, policy); err != nil {
+		t.Fatal(err)
+	}
+	attempts := task.GetAttempts()
+	if task.GetStatus() != StatusNameMapping[StatusDone] || len(attempts) != 3 || attempts[0].Err != "flaky" || attempts[2].Err != "" {
+		t.Fatalf("task should succeed on third attempt, got %s with %d attempts", task.GetStatus(), len(attempts))
+	}
+
+	task = newFlakyTask(5, errFlaky)
+	if err := RunTaskWithRetry(task, policy); !errors.Is(err, errFlaky) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if task.runs != 3 || task.GetStatus() != StatusNameMapping[StatusError] {
+		t.Fatalf("task should stop after max attempts, got %d runs", task.runs)
+	}
+	if err := task.ResetForRetry(); err != nil || task.GetStatus() != StatusNameMapping[StatusPending] || task.Error() != nil {
+		t.Fatal("errored task should be reset to pending")
+	}
+
+	task = newFlakyTask(5, Permanent(errFlaky))
+	RunTaskWithRetry(task, policy)
+	if task.runs != 1 {
+		t.Fatalf("permanent error should not be retried, got %d runs", task.runs)
+	}
+	if newFlakyTask(0, nil).ResetForRetry() != ErrTaskNotRetryable {
+		t.Fatal("only errored task can be reset")
+	}
+}
+
+func TestRunTaskWithRetryStopDuringBackoff(t *testing.T) {
+	task := newFlakyTask(5, errFlaky)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		task.Stop()
+	}()
+	done := make(chan error, 1)
+	go func() {
+		done <- RunTaskWithRetry(task, &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Minute})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stop should end the backoff wait")
+	}
+	if task.runs != 1 || task.GetStatus() != StatusNameMapping[StatusCancelled] {
+		t.Fatalf("stopped task should stay cancelled, got %s after %d runs", task.GetStatus(), task.runs)
+	}
+}
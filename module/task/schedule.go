@@ -0,0 +1,270 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/allentom/haruka"
+)
+
+// TaskFactory 为定时任务的每次执行创建新的任务
+type TaskFactory func() (QueueTask, error)
+
+type scheduleEntry struct {
+	name     string
+	spec     string
+	schedule Schedule
+	factory  TaskFactory
+	next     time.Time
+	lastRun  time.Time
+	lastTask Task
+	lastErr  string
+	skipped  int
+}
+
+// ScheduleInfo 定时任务状态
+type ScheduleInfo struct {
+	Name    string `json:"name"`
+	Spec    string `json:"spec"`
+	NextRun string `json:"nextRun,omitempty"`
+	LastRun string `json:"lastRun,omitempty"`
+	// LastTaskId LastStatus 最近一次执行的任务及其当前状态
+	LastTaskId string `json:"lastTaskId,omitempty"`
+	LastStatus string `json:"lastStatus,omitempty"`
+	LastErr    string `json:"lastErr,omitempty"`
+	// Skipped 因上一次执行未结束而跳过的次数
+	Skipped int `json:"skipped"`
+}
+
+// CronScheduler 按 cron 表达式或固定间隔创建任务，同一定时任务的上一次执行未结束时跳过本次执行。
+// 任务加入 TaskModule 的任务池，启用调度器时通过队列执行
+type CronScheduler struct {
+	sync.Mutex
+	module  *TaskModule
+	entries map[string]*scheduleEntry
+	wake    chan struct{}
+	stop    chan struct{}
+	now     func() time.Time
+}
+
+func newCronScheduler(module *TaskModule) *CronScheduler {
+	scheduler := &CronScheduler{
+		module:  module,
+		entries: map[string]*scheduleEntry{},
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		now:     time.Now,
+	}
+	go scheduler.loop()
+	return scheduler
+}
+
+// Add 注册定时任务，spec 为 cron 表达式或 @every <duration>，同名定时任务会被替换
+func (c *CronScheduler) Add(name string, spec string, factory TaskFactory) error {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+	c.AddSchedule(name, spec, schedule, factory)
+	return nil
+}
+
+// AddSchedule 使用自定义 Schedule 注册定时任务
+func (c *CronScheduler) AddSchedule(name string, spec string, schedule Schedule, factory TaskFactory) {
+	c.Lock()
+	c.entries[name] = &scheduleEntry{
+		name:     name,
+		spec:     spec,
+		schedule: schedule,
+		factory:  factory,
+		next:     schedule.Next(c.now()),
+	}
+	c.Unlock()
+	c.notify()
+}
+
+// Remove 删除定时任务，不影响已创建的任务
+func (c *CronScheduler) Remove(name string) {
+	c.Lock()
+	delete(c.entries, name)
+	c.Unlock()
+	c.notify()
+}
+
+// Stop 停止调度
+func (c *CronScheduler) Stop() {
+	c.Lock()
+	defer c.Unlock()
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+}
+
+func (c *CronScheduler) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// nextWakeup 最早的下一次执行时间，没有定时任务时返回零值
+func (c *CronScheduler) nextWakeup() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	var next time.Time
+	for _, entry := range c.entries {
+		if entry.next.IsZero() {
+			continue
+		}
+		if next.IsZero() || entry.next.Before(next) {
+			next = entry.next
+		}
+	}
+	return next
+}
+
+func (c *CronScheduler) loop() {
+	for {
+		var timer <-chan time.Time
+		next := c.nextWakeup()
+		if !next.IsZero() {
+			wait := next.Sub(c.now())
+			if wait < 0 {
+				wait = 0
+			}
+			timer = time.After(wait)
+		}
+		select {
+		case <-timer:
+			c.runDue()
+		case <-c.wake:
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *CronScheduler) runDue() {
+	now := c.now()
+	c.Lock()
+	due := make([]*scheduleEntry, 0)
+	for _, entry := range c.entries {
+		if !entry.next.IsZero() && !entry.next.After(now) {
+			due = append(due, entry)
+			entry.next = entry.schedule.Next(now)
+		}
+	}
+	c.Unlock()
+	for _, entry := range due {
+		c.run(entry, now)
+	}
+}
+
+// run 执行一次定时任务，上一次创建的任务未结束时跳过
+func (c *CronScheduler) run(entry *scheduleEntry, now time.Time) {
+	c.Lock()
+	if entry.lastTask != nil && !IsFinishedStatus(entry.lastTask.GetStatus()) {
+		entry.skipped++
+		c.Unlock()
+		TaskLogger.WithField("schedule", entry.name).Warn("previous run not finished, skip")
+		return
+	}
+	entry.lastRun = now
+	c.Unlock()
+	task, err := entry.factory()
+	if err == nil && task == nil {
+		err = errors.New("task factory returned nil task")
+	}
+	if err == nil {
+		err = c.module.Submit(task)
+	}
+	c.Lock()
+	defer c.Unlock()
+	if err != nil {
+		entry.lastTask = nil
+		entry.lastErr = err.Error()
+		TaskLogger.WithField("schedule", entry.name).Error(fmt.Sprintf("create scheduled task failed: %v", err))
+		return
+	}
+	entry.lastTask = task
+	entry.lastErr = ""
+}
+
+// List 按名称返回所有定时任务的状态
+func (c *CronScheduler) List() []*ScheduleInfo {
+	c.Lock()
+	defer c.Unlock()
+	list := make([]*ScheduleInfo, 0, len(c.entries))
+	for _, entry := range c.entries {
+		info := &ScheduleInfo{
+			Name:    entry.name,
+			Spec:    entry.spec,
+			LastErr: entry.lastErr,
+			Skipped: entry.skipped,
+		}
+		if !entry.next.IsZero() {
+			info.NextRun = entry.next.Format(templateTimeFormat)
+		}
+		if !entry.lastRun.IsZero() {
+			info.LastRun = entry.lastRun.Format(templateTimeFormat)
+		}
+		if entry.lastTask != nil {
+			info.LastTaskId = entry.lastTask.GetId()
+			info.LastStatus = entry.lastTask.GetStatus()
+			if entry.lastTask.Error() != nil {
+				info.LastErr = entry.lastTask.Error().Error()
+			}
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Submit 将任务加入任务池并执行：启用调度器时排队执行，否则在新的 goroutine 中执行
+func (t *TaskModule) Submit(task QueueTask) error {
+	if t.Scheduler != nil {
+		return t.Scheduler.Submit(task)
+	}
+	// 重试的任务已在任务池中
+	if t.Pool.GetTaskById(task.GetId()) == nil {
+		t.Pool.AddTask(task)
+	}
+	go RunTaskWithRetry(task, t.RetryPolicies.Get(task.GetType()))
+	return nil
+}
+
+func (t *TaskModule) cron() *CronScheduler {
+	t.cronOnce.Do(func() {
+		t.Cron = newCronScheduler(t)
+	})
+	return t.Cron
+}
+
+// AddSchedule 注册定时任务，spec 为 5 段 cron 表达式（分 时 日 月 周）、@daily 等描述符或 @every <duration>
+func (t *TaskModule) AddSchedule(name string, spec string, factory TaskFactory) error {
+	return t.cron().Add(name, spec, factory)
+}
+
+// AddIntervalSchedule 注册固定间隔的定时任务
+func (t *TaskModule) AddIntervalSchedule(name string, interval time.Duration, factory TaskFactory) {
+	t.cron().AddSchedule(name, fmt.Sprintf("@every %s", interval), Every(interval), factory)
+}
+
+func (t *TaskModule) schedulesHandler(context *haruka.Context) {
+	data := make([]*ScheduleInfo, 0)
+	if t.Cron != nil {
+		data = t.Cron.List()
+	}
+	context.JSON(haruka.JSON{
+		"success": true,
+		"data":    data,
+	})
+}
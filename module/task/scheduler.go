@@ -0,0 +1,237 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// ErrSchedulerStopped 调度器停止后不再接受任务
+var ErrSchedulerStopped = errors.New("task scheduler stopped")
+
+// QueueTask 可以提交到调度器的任务，通常为嵌入 *BaseTask 并实现 Start 的任务
+type QueueTask interface {
+	Task
+	Wrap
+}
+
+// statusSetter 可选接口：调度器通过它把任务标记为 Pending/Running，BaseTask 已实现
+type statusSetter interface {
+	SetStatus(status string)
+}
+
+type queueItem struct {
+	task     QueueTask
+	priority int
+	seq      uint64
+}
+
+// SchedulerStats 调度器队列状态
+type SchedulerStats struct {
+	Workers       int            `json:"workers"`
+	Queued        int            `json:"queued"`
+	Running       int            `json:"running"`
+	QueuedByType  map[string]int `json:"queuedByType"`
+	RunningByType map[string]int `json:"runningByType"`
+	TypeLimits    map[string]int `json:"typeLimits"`
+}
+
+// Scheduler 有界任务队列：固定数量的 worker 按优先级执行任务，同一类型的任务可设置并发上限
+type Scheduler struct {
+	sync.Mutex
+	Pool *TaskPool
+	// RetryPolicies 不为空时按任务类型的策略重试失败的任务
+	RetryPolicies *RetryPolicies
+	workers       int
+	typeLimits    map[string]int
+	queue         []*queueItem
+	running       map[string]int
+	seq           uint64
+	started       bool
+	stopped       bool
+	cond          *sync.Cond
+	wg            sync.WaitGroup
+}
+
+// NewScheduler 创建调度器，workers 小于等于 0 时使用 CPU 核数，pool 不为空时提交的任务会加入任务池
+func NewScheduler(pool *TaskPool, workers int) *Scheduler {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	scheduler := &Scheduler{
+		Pool:       pool,
+		workers:    workers,
+		typeLimits: map[string]int{},
+		queue:      []*queueItem{},
+		running:    map[string]int{},
+	}
+	scheduler.cond = sync.NewCond(&scheduler.Mutex)
+	return scheduler
+}
+
+// SetTypeLimit 设置某类型任务的最大并发数，小于等于 0 表示只受 worker 数量限制
+func (s *Scheduler) SetTypeLimit(taskType string, limit int) {
+	s.Lock()
+	defer s.Unlock()
+	if limit <= 0 {
+		delete(s.typeLimits, taskType)
+	} else {
+		s.typeLimits[taskType] = limit
+	}
+	s.cond.Broadcast()
+}
+
+// Start 启动 worker，重复调用无效
+func (s *Scheduler) Start() {
+	s.Lock()
+	defer s.Unlock()
+	if s.started || s.stopped {
+		return
+	}
+	s.started = true
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+}
+
+// Stop 停止接受新任务并等待正在执行的任务完成，队列中未执行的任务保持 Pending
+func (s *Scheduler) Stop() {
+	s.Lock()
+	s.stopped = true
+	s.cond.Broadcast()
+	s.Unlock()
+	s.wg.Wait()
+}
+
+// Submit 以默认优先级 0 提交任务
+func (s *Scheduler) Submit(task QueueTask) error {
+	return s.SubmitWithPriority(task, 0)
+}
+
+// SubmitWithPriority 提交任务，priority 越大越先执行，相同优先级按提交顺序执行。
+// 任务先标记为 Pending 并加入任务池，再放入队列，避免空闲的 worker 在此之前取走任务。
+// 标记状态与加入任务池会触发监听器，在持有调度器的锁之外进行，监听器中可以调用调度器的方法
+func (s *Scheduler) SubmitWithPriority(task QueueTask, priority int) error {
+	s.Lock()
+	stopped := s.stopped
+	s.Unlock()
+	if stopped {
+		return ErrSchedulerStopped
+	}
+	if setter, ok := task.(statusSetter); ok {
+		setter.SetStatus(StatusNameMapping[StatusPending])
+	}
+	// 重试的任务已在任务池中
+	if s.Pool != nil && s.Pool.GetTaskById(task.GetId()) == nil {
+		s.Pool.AddTask(task)
+	}
+	s.Lock()
+	defer s.Unlock()
+	// 加入任务池期间调度器已停止，任务与 Stop 时队列中的任务一样保持 Pending
+	if s.stopped {
+		return ErrSchedulerStopped
+	}
+	s.seq++
+	item := &queueItem{task: task, priority: priority, seq: s.seq}
+	index := sort.Search(len(s.queue), func(i int) bool {
+		return s.queue[i].priority < priority
+	})
+	s.queue = append(s.queue, nil)
+	copy(s.queue[index+1:], s.queue[index:])
+	s.queue[index] = item
+	s.cond.Broadcast()
+	return nil
+}
+
+// QueueDepth 等待执行的任务数量
+func (s *Scheduler) QueueDepth() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.queue)
+}
+
+// Stats 返回队列与执行中任务的统计
+func (s *Scheduler) Stats() *SchedulerStats {
+	s.Lock()
+	defer s.Unlock()
+	stats := &SchedulerStats{
+		Workers:       s.workers,
+		Queued:        len(s.queue),
+		QueuedByType:  map[string]int{},
+		RunningByType: map[string]int{},
+		TypeLimits:    map[string]int{},
+	}
+	for _, item := range s.queue {
+		stats.QueuedByType[item.task.GetType()]++
+	}
+	for taskType, count := range s.running {
+		stats.Running += count
+		stats.RunningByType[taskType] = count
+	}
+	for taskType, limit := range s.typeLimits {
+		stats.TypeLimits[taskType] = limit
+	}
+	return stats
+}
+
+// next 取出优先级最高且未达到类型并发上限的任务，调用方需持有锁
+func (s *Scheduler) next() *queueItem {
+	for index, item := range s.queue {
+		taskType := item.task.GetType()
+		if limit, ok := s.typeLimits[taskType]; ok && s.running[taskType] >= limit {
+			continue
+		}
+		s.queue = append(s.queue[:index], s.queue[index+1:]...)
+		s.running[taskType]++
+		return item
+	}
+	return nil
+}
+
+func (s *Scheduler) work() {
+	defer s.wg.Done()
+	for {
+		s.Lock()
+		var item *queueItem
+		for !s.stopped {
+			item = s.next()
+			if item != nil {
+				break
+			}
+			s.cond.Wait()
+		}
+		s.Unlock()
+		if item == nil {
+			return
+		}
+		s.run(item.task)
+		s.Lock()
+		s.running[item.task.GetType()]--
+		if s.running[item.task.GetType()] == 0 {
+			delete(s.running, item.task.GetType())
+		}
+		s.cond.Broadcast()
+		s.Unlock()
+	}
+}
+
+// run 执行任务，任务中的 panic 会作为错误结束任务。
+// 排队时已停止的任务直接跳过，已暂停的任务会在 Checkpoint 处阻塞直到恢复
+func (s *Scheduler) run(task QueueTask) {
+	defer func() {
+		if r := recover(); r != nil {
+			task.AbortError(fmt.Errorf("panic: %v", r))
+		}
+	}()
+	status := task.GetStatus()
+	if status == StatusNameMapping[StatusCancelled] {
+		return
+	}
+	if setter, ok := task.(statusSetter); ok && status == StatusNameMapping[StatusPending] {
+		setter.SetStatus(StatusNameMapping[StatusRunning])
+	}
+	RunTaskWithRetry(task, s.RetryPolicies.Get(task.GetType()))
+}
@@ -0,0 +1,88 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+type probeTask struct {
+	*BaseTask
+	pool    *TaskPool
+	inPool  bool
+	running bool
+}
+
+func (t *probeTask) Start() error {
+	t.inPool = t.pool.GetTaskById(t.GetId()) != nil
+	t.running = t.GetStatus() == StatusNameMapping[StatusRunning]
+	return nil
+}
+
+// SetStatus 放慢标记 Pending，使提交过程中的竞争更容易出现
+func (t *probeTask) SetStatus(status string) {
+	if status == StatusNameMapping[StatusPending] {
+		time.Sleep(time.Millisecond)
+	}
+	t.BaseTask.SetStatus(status)
+}
+
+func (t *probeTask) Output() (interface{}, error) {
+	return nil, nil
+}
+
+func TestSchedulerSubmitBeforeRun(t *testing.T) {
+	pool := NewTaskPool()
+	scheduler := NewScheduler(pool, 4)
+	scheduler.Start()
+	tasks := make([]*probeTask, 0)
+	for i := 0; i < 50; i++ {
+		task := &probeTask{BaseTask: NewBaseTask("probe", "", ""), pool: pool}
+		tasks = append(tasks, task)
+		if err := scheduler.Submit(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Stop 不会执行队列中剩余的任务，等待全部结束后再停止
+	deadline := time.Now().Add(5 * time.Second)
+	for _, task := range tasks {
+		for !IsFinishedStatus(task.GetStatus()) {
+			if time.Now().After(deadline) {
+				t.Fatalf("task not finished, status %s", task.GetStatus())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	scheduler.Stop()
+	for _, task := range tasks {
+		if !task.inPool || !task.running {
+			t.Fatalf("task should be in pool and running when started, inPool=%v running=%v", task.inPool, task.running)
+		}
+		if task.GetStatus() != StatusNameMapping[StatusDone] {
+			t.Fatalf("task should be done, got %s", task.GetStatus())
+		}
+	}
+}
+
+func TestSchedulerSubmitListenerUsesScheduler(t *testing.T) {
+	pool := NewTaskPool()
+	scheduler := NewScheduler(pool, 1)
+	// 监听器在提交过程中被调用，读取调度器状态不应死锁
+	pool.AddChangeListener(func(task Task) {
+		scheduler.QueueDepth()
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- scheduler.Submit(&probeTask{BaseTask: NewBaseTask("probe", "", ""), pool: pool})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("submit blocked by pool listener")
+	}
+	if scheduler.QueueDepth() != 1 {
+		t.Fatalf("expected 1 queued task, got %d", scheduler.QueueDepth())
+	}
+}
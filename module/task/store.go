@@ -0,0 +1,163 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"gorm.io/gorm"
+)
+
+// TaskStore 任务持久化存储，保存顶层任务的 Template 快照（包含子任务、状态变化与输出）
+type TaskStore interface {
+	// Save 写入或覆盖任务快照
+	Save(template *Template) error
+	// Delete 删除任务快照
+	Delete(id string) error
+	// List 按创建顺序返回所有任务快照
+	List() ([]*Template, error)
+	Close() error
+}
+
+var (
+	taskStoreBucket = "tasks"
+)
+
+// BoltTaskStore 基于 bbolt 的任务存储
+type BoltTaskStore struct {
+	DB *bolt.DB
+}
+
+// NewBoltTaskStore 打开 path 指定的 bolt 数据库作为任务存储
+func NewBoltTaskStore(path string) (*BoltTaskStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(taskStoreBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltTaskStore{DB: db}, nil
+}
+
+func (s *BoltTaskStore) Save(template *Template) error {
+	raw, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(taskStoreBucket)).Put([]byte(template.Id), raw)
+	})
+}
+
+func (s *BoltTaskStore) Delete(id string) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(taskStoreBucket)).Delete([]byte(id))
+	})
+}
+
+// List 任务 id 为 xid，按 key 顺序遍历即为创建顺序
+func (s *BoltTaskStore) List() ([]*Template, error) {
+	templates := make([]*Template, 0)
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(taskStoreBucket)).ForEach(func(k, v []byte) error {
+			template := &Template{}
+			err := json.Unmarshal(v, template)
+			if err != nil {
+				return err
+			}
+			templates = append(templates, template)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (s *BoltTaskStore) Close() error {
+	if s.DB == nil {
+		return nil
+	}
+	err := s.DB.Close()
+	s.DB = nil
+	return err
+}
+
+// TaskRecord gorm 任务存储的表结构，Data 为 Template 的 json
+type TaskRecord struct {
+	Id      string `gorm:"primaryKey;size:64"`
+	Type    string `gorm:"index;size:255"`
+	Status  string `gorm:"index;size:64"`
+	Created string `gorm:"index;size:32"`
+	Data    string `gorm:"type:longtext"`
+}
+
+func (TaskRecord) TableName() string {
+	return "harukap_tasks"
+}
+
+// GormTaskStore 基于 gorm 的任务存储，db 通常取自 datasource 插件的 DBS
+type GormTaskStore struct {
+	DB *gorm.DB
+}
+
+// NewGormTaskStore 创建 gorm 任务存储并迁移表结构
+func NewGormTaskStore(db *gorm.DB) (*GormTaskStore, error) {
+	if db == nil {
+		return nil, errors.New("task store database is nil")
+	}
+	err := db.AutoMigrate(&TaskRecord{})
+	if err != nil {
+		return nil, err
+	}
+	return &GormTaskStore{DB: db}, nil
+}
+
+func (s *GormTaskStore) Save(template *Template) error {
+	raw, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	return s.DB.Save(&TaskRecord{
+		Id:      template.Id,
+		Type:    template.Type,
+		Status:  template.Status,
+		Created: template.Created,
+		Data:    string(raw),
+	}).Error
+}
+
+func (s *GormTaskStore) Delete(id string) error {
+	return s.DB.Delete(&TaskRecord{}, "id = ?", id).Error
+}
+
+func (s *GormTaskStore) List() ([]*Template, error) {
+	var records []*TaskRecord
+	err := s.DB.Order("created asc, id asc").Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]*Template, 0, len(records))
+	for _, record := range records {
+		template := &Template{}
+		err = json.Unmarshal([]byte(record.Data), template)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+// Close 数据库连接由 datasource 插件管理，这里不关闭
+func (s *GormTaskStore) Close() error {
+	return nil
+}
@@ -0,0 +1,136 @@
+package task
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestStores(t *testing.T) map[string]TaskStore {
+	boltStore, err := NewBoltTaskStore(filepath.Join(t.TempDir(), "task.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "task.sqlite")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormStore, err := NewGormTaskStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		boltStore.Close()
+	})
+	return map[string]TaskStore{"bolt": boltStore, "gorm": gormStore}
+}
+
+func TestTaskStoreRoundTrip(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			first := &Template{
+				Id:      "c0000000000000000001",
+				Type:    "scan",
+				Status:  StatusNameMapping[StatusDone],
+				Owner:   "bob",
+				Created: "2026-01-01 10:00:00",
+				Output:  "ok",
+				SubTask: []*Template{
+					{Id: "c0000000000000000001-a", Type: "scan", Status: StatusNameMapping[StatusDone], ParentTaskId: "c0000000000000000001"},
+				},
+				Transitions: []*StatusTransition{{Status: StatusNameMapping[StatusDone], Time: "2026-01-01 10:01:00"}},
+				Progress:    &TaskProgress{Current: 1, Total: 1, Percent: 100},
+			}
+			second := &Template{Id: "c0000000000000000002", Type: "tag", Status: StatusNameMapping[StatusRunning], Created: "2026-01-01 11:00:00"}
+			for _, template := range []*Template{second, first} {
+				if err := store.Save(template); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// 覆盖写入
+			second.Status = StatusNameMapping[StatusError]
+			if err := store.Save(second); err != nil {
+				t.Fatal(err)
+			}
+			templates, err := store.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(templates) != 2 || templates[0].Id != first.Id || templates[1].Id != second.Id {
+				t.Fatalf("templates should be listed in created order, got %d", len(templates))
+			}
+			restored := templates[0]
+			if restored.Owner != "bob" || restored.Output != "ok" || len(restored.SubTask) != 1 ||
+				len(restored.Transitions) != 1 || restored.Progress == nil || restored.Progress.Percent != 100 {
+				t.Fatalf("template not restored: %+v", restored)
+			}
+			if templates[1].Status != StatusNameMapping[StatusError] {
+				t.Fatalf("saved template should be overwritten, got %s", templates[1].Status)
+			}
+			if err = store.Delete(first.Id); err != nil {
+				t.Fatal(err)
+			}
+			templates, _ = store.List()
+			if len(templates) != 1 {
+				t.Fatalf("deleted template should not be listed, got %d", len(templates))
+			}
+		})
+	}
+}
+
+func TestMarkInterrupted(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+	template := &Template{
+		Status: StatusNameMapping[StatusRunning],
+		SubTask: []*Template{
+			{Status: StatusNameMapping[StatusDone]},
+			{Status: StatusNameMapping[StatusPending]},
+		},
+	}
+	if !markInterrupted(template, at) {
+		t.Fatal("running task should be marked")
+	}
+	if template.Status != StatusNameMapping[StatusInterrupted] || template.Err != InterruptedMessage {
+		t.Fatalf("unexpected status %s %s", template.Status, template.Err)
+	}
+	if last := template.Transitions[len(template.Transitions)-1]; last.Time != "2026-01-01 12:00:00" {
+		t.Fatalf("transition should be recorded, got %s", last.Time)
+	}
+	if template.SubTask[0].Status != StatusNameMapping[StatusDone] {
+		t.Fatal("finished sub task should be kept")
+	}
+	if template.SubTask[1].Status != StatusNameMapping[StatusInterrupted] {
+		t.Fatal("pending sub task should be marked")
+	}
+	if markInterrupted(template, at) {
+		t.Fatal("marking twice should not change anything")
+	}
+}
+
+func TestUseStoreRestoresHistory(t *testing.T) {
+	store := newTestStores(t)["bolt"]
+	store.Save(&Template{Id: "c0000000000000000001", Type: "scan", Status: StatusNameMapping[StatusRunning], Created: "2026-01-01 10:00:00"})
+	module := NewTaskModule()
+	if err := module.UseStore(store); err != nil {
+		t.Fatal(err)
+	}
+	task := module.Pool.GetTaskById("c0000000000000000001")
+	if task == nil || task.GetStatus() != StatusNameMapping[StatusInterrupted] {
+		t.Fatalf("history should be restored as interrupted, got %v", task)
+	}
+	if task.Start() != ErrStoredTask {
+		t.Fatal("restored task should not run")
+	}
+	templates, _ := store.List()
+	if templates[0].Status != StatusNameMapping[StatusInterrupted] {
+		t.Fatal("interrupted status should be saved back")
+	}
+	module.Pool.RemoveTaskById("c0000000000000000001")
+	templates, _ = store.List()
+	if len(templates) != 0 {
+		t.Fatal("removed task should be deleted from store")
+	}
+}
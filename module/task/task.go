@@ -1,8 +1,10 @@
 package task
 
 import (
+	"context"
 	"fmt"
 	"github.com/rs/xid"
+	"sync"
 	"time"
 )
 
@@ -12,17 +14,32 @@ const (
 	StatusRunning = iota + 10000
 	StatusDone
 	StatusError
+	StatusInterrupted
+	StatusPending
+	StatusCancelled
+	StatusPaused
+	StatusRetrying
+	StatusSkipped
 )
 
 var StatusNameMapping map[int]string = map[int]string{
-	StatusRunning: "Running",
-	StatusDone:    "Done",
-	StatusError:   "Error",
+	StatusRunning:     "Running",
+	StatusDone:        "Done",
+	StatusError:       "Error",
+	StatusInterrupted: "Interrupted",
+	StatusPending:     "Pending",
+	StatusCancelled:   "Cancelled",
+	StatusPaused:      "Paused",
+	StatusRetrying:    "Retrying",
+	StatusSkipped:     "Skipped",
 }
 var (
 	SignalDone = Signal("init")
 )
 
+// templateTimeFormat Template 中时间字段的格式
+const templateTimeFormat = "2006-01-02 15:04:05"
+
 type Task interface {
 	GetId() string
 	GetType() string
@@ -45,6 +62,27 @@ type Wrap interface {
 	Start() error
 }
 
+// TaskWithOwner 可选接口：提供任务所属用户
+type TaskWithOwner interface {
+	GetOwner() string
+}
+
+// TaskWithTransitions 可选接口：提供任务状态变化记录
+type TaskWithTransitions interface {
+	GetTransitions() []*StatusTransition
+}
+
+// StatusTransition 一次状态变化
+type StatusTransition struct {
+	Status string `json:"status"`
+	Time   string `json:"time"`
+}
+
+// changeNotifier 加入 TaskPool 的任务通过该接口绑定变化通知，BaseTask 已实现
+type changeNotifier interface {
+	bindChangeListener(listener func())
+}
+
 type BaseTask struct {
 	Id           string
 	Type         string
@@ -57,13 +95,29 @@ type BaseTask struct {
 	StartTime    time.Time
 	EndTime      time.Time
 	ParentTaskId string
+	Transitions  []*StatusTransition
+	Attempts     []*Attempt
+	Progress     *TaskProgress
+	// onChange 由 TaskPool 绑定，状态或时间变化时调用
+	onChange func()
+	lock     sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+	// resume 暂停期间不为空，恢复时关闭
+	resume chan struct{}
+	// progressStart 用于估算 ETA
+	progressStart time.Time
 }
 
 func (t *BaseTask) GetStartTime() time.Time {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	return t.StartTime
 }
 
 func (t *BaseTask) GetEndTime() time.Time {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	return t.EndTime
 }
 
@@ -71,38 +125,128 @@ func (t *BaseTask) GetId() string {
 	return t.Id
 }
 func (t *BaseTask) Error() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	return t.Err
 }
 func (t *BaseTask) GetType() string {
 	return t.Type
 }
 func (t *BaseTask) GetStatus() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	return t.Status
 }
 func (t *BaseTask) GetCreated() time.Time {
 	return t.Created
 }
 func (t *BaseTask) SubTask() []Task {
-	return t.SubTaskList
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]Task{}, t.SubTaskList...)
+}
+func (t *BaseTask) GetOwner() string {
+	return t.Owner
+}
+func (t *BaseTask) GetTransitions() []*StatusTransition {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]*StatusTransition{}, t.Transitions...)
 }
 func (t *BaseTask) SetStart() {
+	t.lock.Lock()
 	t.StartTime = time.Now()
+	t.lock.Unlock()
+	t.notifyChange()
 }
 func (t *BaseTask) SetEnd() {
+	t.lock.Lock()
 	t.EndTime = time.Now()
+	t.lock.Unlock()
+	t.notifyChange()
+}
+
+// SetStatus 修改状态并记录状态变化，直接修改 Status 字段不会被持久化
+func (t *BaseTask) SetStatus(status string) {
+	t.lock.Lock()
+	t.recordStatus(status)
+	t.lock.Unlock()
+	t.notifyChange()
+}
+
+// AddSubTask 添加子任务，子任务的变化会通知到父任务所在的 TaskPool
+func (t *BaseTask) AddSubTask(task Task) {
+	t.lock.Lock()
+	t.SubTaskList = append(t.SubTaskList, task)
+	onChange := t.onChange
+	t.lock.Unlock()
+	if notifier, ok := task.(changeNotifier); ok && onChange != nil {
+		notifier.bindChangeListener(onChange)
+	}
+	// 父任务已取消时子任务也随之取消
+	if t.IsCancelled() {
+		task.Stop()
+	}
+	t.notifyChange()
+}
+
+// recordStatus 调用方需持有 lock
+func (t *BaseTask) recordStatus(status string) {
+	t.Status = status
+	if status == "" {
+		return
+	}
+	t.Transitions = append(t.Transitions, &StatusTransition{
+		Status: status,
+		Time:   time.Now().Format(templateTimeFormat),
+	})
+}
+
+func (t *BaseTask) bindChangeListener(listener func()) {
+	t.lock.Lock()
+	t.onChange = listener
+	t.lock.Unlock()
+	for _, subTask := range t.SubTask() {
+		if notifier, ok := subTask.(changeNotifier); ok {
+			notifier.bindChangeListener(listener)
+		}
+	}
+}
+
+func (t *BaseTask) notifyChange() {
+	t.lock.Lock()
+	onChange := t.onChange
+	t.lock.Unlock()
+	if onChange != nil {
+		onChange()
+	}
 }
 func (t *BaseTask) GetParentTaskId() string {
 	return t.ParentTaskId
 }
+
+// AbortError 以错误结束任务，已取消的任务保持 Cancelled 状态
 func (t *BaseTask) AbortError(err error) error {
-	t.Err = err
+	t.lock.Lock()
 	t.EndTime = time.Now()
-	t.Status = StatusNameMapping[StatusError]
+	if t.Status != StatusNameMapping[StatusCancelled] {
+		t.Err = err
+		t.recordStatus(StatusNameMapping[StatusError])
+	}
+	t.lock.Unlock()
+	t.notifyChange()
 	return err
 }
+
+// Done 标记任务完成，已取消的任务保持 Cancelled 状态
 func (t *BaseTask) Done() {
-	t.Status = StatusNameMapping[StatusDone]
+	t.lock.Lock()
 	t.EndTime = time.Now()
+	if t.Status != StatusNameMapping[StatusCancelled] {
+		t.recordStatus(StatusNameMapping[StatusDone])
+	}
+	t.lock.Unlock()
+	t.notifyChange()
 	return
 }
 
@@ -119,28 +263,31 @@ func GetStatusText(extraMapping map[int]string, status int) string {
 }
 func NewBaseTask(Type string, owner string, status string) *BaseTask {
 	id := xid.New().String()
-	return &BaseTask{
+	task := &BaseTask{
 		Id:          id,
 		Type:        Type,
 		Owner:       owner,
-		Status:      status,
 		OnDone:      make(chan Signal),
 		Created:     time.Now(),
 		SubTaskList: []Task{},
 	}
+	task.recordStatus(status)
+	return task
 }
 
 func NewSubTask(Type string, owner string, status string, parentId string) *BaseTask {
 	id := xid.New().String()
-	return &BaseTask{
-		Id:          fmt.Sprintf("%s-%s", parentId, id),
-		Type:        Type,
-		Owner:       owner,
-		Status:      status,
-		OnDone:      make(chan Signal),
-		Created:     time.Now(),
-		SubTaskList: []Task{},
+	task := &BaseTask{
+		Id:           fmt.Sprintf("%s-%s", parentId, id),
+		Type:         Type,
+		Owner:        owner,
+		OnDone:       make(chan Signal),
+		Created:      time.Now(),
+		SubTaskList:  []Task{},
+		ParentTaskId: parentId,
 	}
+	task.recordStatus(status)
+	return task
 }
 
 func RunTask(wrap Wrap) error {
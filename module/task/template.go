@@ -1 +1,113 @@
 package task
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrStoredTask 从存储中恢复的历史任务不能再次执行
+var ErrStoredTask = errors.New("task restored from store can not be executed")
+
+// InterruptedMessage 进程退出时未结束的任务，恢复后记录的错误信息
+const InterruptedMessage = "task interrupted by process exit"
+
+// StoredTask 由 Template 快照恢复的历史任务
+type StoredTask struct {
+	Template *Template
+	subTasks []Task
+}
+
+// NewStoredTask 由快照创建历史任务
+func NewStoredTask(template *Template) *StoredTask {
+	task := &StoredTask{
+		Template: template,
+		subTasks: []Task{},
+	}
+	for _, subTemplate := range template.SubTask {
+		task.subTasks = append(task.subTasks, NewStoredTask(subTemplate))
+	}
+	return task
+}
+
+func parseTemplateTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	parsed, err := time.ParseInLocation(templateTimeFormat, value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return parsed
+}
+
+func (t *StoredTask) GetId() string {
+	return t.Template.Id
+}
+func (t *StoredTask) GetType() string {
+	return t.Template.Type
+}
+func (t *StoredTask) GetStatus() string {
+	return t.Template.Status
+}
+func (t *StoredTask) GetOwner() string {
+	return t.Template.Owner
+}
+func (t *StoredTask) GetTransitions() []*StatusTransition {
+	return t.Template.Transitions
+}
+func (t *StoredTask) GetAttempts() []*Attempt {
+	return t.Template.Attempts
+}
+func (t *StoredTask) GetProgress() *TaskProgress {
+	return t.Template.Progress
+}
+func (t *StoredTask) Stop() error {
+	return ErrStoredTask
+}
+func (t *StoredTask) Start() error {
+	return ErrStoredTask
+}
+func (t *StoredTask) Error() error {
+	if t.Template.Err == "" {
+		return nil
+	}
+	return errors.New(t.Template.Err)
+}
+func (t *StoredTask) GetCreated() time.Time {
+	return parseTemplateTime(t.Template.Created)
+}
+func (t *StoredTask) Output() (interface{}, error) {
+	return t.Template.Output, nil
+}
+func (t *StoredTask) SubTask() []Task {
+	return t.subTasks
+}
+func (t *StoredTask) GetStartTime() time.Time {
+	return parseTemplateTime(t.Template.StartTime)
+}
+func (t *StoredTask) GetEndTime() time.Time {
+	return parseTemplateTime(t.Template.EndTime)
+}
+func (t *StoredTask) GetParentTaskId() string {
+	return t.Template.ParentTaskId
+}
+
+// markInterrupted 将快照中未结束的任务（包括子任务）标记为 Interrupted，返回是否有修改
+func markInterrupted(template *Template, at time.Time) bool {
+	changed := false
+	if !IsFinishedStatus(template.Status) {
+		template.Status = StatusNameMapping[StatusInterrupted]
+		template.Err = InterruptedMessage
+		template.Transitions = append(template.Transitions, &StatusTransition{
+			Status: template.Status,
+			Time:   at.Format(templateTimeFormat),
+		})
+		changed = true
+	}
+	for _, subTemplate := range template.SubTask {
+		if markInterrupted(subTemplate, at) {
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,83 @@
+package task
+
+import (
+	"sync"
+	"time"
+)
+
+type throttleState struct {
+	status   string
+	lastSent time.Time
+	pending  *time.Timer
+}
+
+// changeThrottle 合并频繁的任务变化（如进度更新）：状态变化立即处理，其余变化在 interval 内最多处理一次，
+// 被合并的变化会在间隔结束时补发，保证最后一次变化不会丢失
+type changeThrottle struct {
+	sync.Mutex
+	interval time.Duration
+	handler  func(task Task)
+	states   map[string]*throttleState
+}
+
+func newChangeThrottle(interval time.Duration, handler func(task Task)) *changeThrottle {
+	return &changeThrottle{
+		interval: interval,
+		handler:  handler,
+		states:   map[string]*throttleState{},
+	}
+}
+
+func (c *changeThrottle) onChange(task Task) {
+	id := task.GetId()
+	status := task.GetStatus()
+	now := time.Now()
+	c.Lock()
+	state, ok := c.states[id]
+	if !ok {
+		state = &throttleState{}
+		c.states[id] = state
+	}
+	if state.status != status || now.Sub(state.lastSent) >= c.interval {
+		state.status = status
+		state.lastSent = now
+		if state.pending != nil {
+			state.pending.Stop()
+			state.pending = nil
+		}
+		c.Unlock()
+		c.handler(task)
+		return
+	}
+	if state.pending == nil {
+		var timer *time.Timer
+		timer = time.AfterFunc(c.interval-now.Sub(state.lastSent), func() {
+			c.Lock()
+			// 等待锁期间已被立即处理的变化取代
+			if state.pending != timer {
+				c.Unlock()
+				return
+			}
+			state.pending = nil
+			state.status = task.GetStatus()
+			state.lastSent = time.Now()
+			c.Unlock()
+			c.handler(task)
+		})
+		state.pending = timer
+	}
+	c.Unlock()
+}
+
+func (c *changeThrottle) onRemove(id string) {
+	c.Lock()
+	defer c.Unlock()
+	state, ok := c.states[id]
+	if !ok {
+		return
+	}
+	if state.pending != nil {
+		state.pending.Stop()
+	}
+	delete(c.states, id)
+}
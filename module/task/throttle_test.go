@@ -0,0 +1,56 @@
+package task
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type throttleRecorder struct {
+	sync.Mutex
+	statuses []string
+}
+
+func (r *throttleRecorder) handle(task Task) {
+	r.Lock()
+	defer r.Unlock()
+	r.statuses = append(r.statuses, task.GetStatus())
+}
+
+func (r *throttleRecorder) count() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.statuses)
+}
+
+func TestChangeThrottle(t *testing.T) {
+	recorder := &throttleRecorder{}
+	throttle := newChangeThrottle(50*time.Millisecond, recorder.handle)
+	task := &outputTask{BaseTask: NewBaseTask("scan", "", StatusNameMapping[StatusRunning])}
+	for i := 0; i < 10; i++ {
+		throttle.onChange(task)
+	}
+	if got := recorder.count(); got != 1 {
+		t.Fatalf("changes in interval should be merged, got %d", got)
+	}
+	// 状态变化立即处理，并取代等待补发的变化
+	task.SetStatus(StatusNameMapping[StatusDone])
+	throttle.onChange(task)
+	if got := recorder.count(); got != 2 {
+		t.Fatalf("status change should be handled immediately, got %d", got)
+	}
+	throttle.onChange(task)
+	time.Sleep(100 * time.Millisecond)
+	if got := recorder.count(); got != 3 {
+		t.Fatalf("merged change should be sent after interval, got %d", got)
+	}
+
+	// 间隔已过的变化立即处理，之后的变化等待补发时任务被移除
+	throttle.onChange(task)
+	throttle.onChange(task)
+	throttle.onRemove(task.GetId())
+	time.Sleep(100 * time.Millisecond)
+	if got := recorder.count(); got != 4 {
+		t.Fatalf("removed task should not be sent, got %d", got)
+	}
+}
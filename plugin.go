@@ -1,6 +1,11 @@
 package harukap
 
-import "github.com/allentom/harukap/commons"
+import (
+	"context"
+
+	"github.com/allentom/harukap/commons"
+	"github.com/allentom/harukap/config"
+)
 
 type HarukaPlugin interface {
 	OnInit(e *HarukaAppEngine) error
@@ -11,6 +16,45 @@ type PluginWithConfig interface {
 	GetPluginConfig() map[string]interface{}
 }
 
+// PluginWithShutdown 可选接口：插件实现后会在引擎关闭时按初始化的逆序被调用
+type PluginWithShutdown interface {
+	OnShutdown() error
+}
+
+// PluginWithHealthCheck 可选接口：插件实现后会参与 /healthz 与 /readyz 检查。
+// 插件未启用时返回 ErrHealthCheckDisabled
+type PluginWithHealthCheck interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// PluginWithConfigReload 可选接口：插件实现后会在配置文件中 ConfigPrefixes 下的键变化时收到通知，
+// 返回错误即拒绝本次变更
+type PluginWithConfigReload interface {
+	ConfigPrefixes() []string
+	OnConfigChange(change *config.ConfigChange) error
+}
+
+// PluginWithName 可选接口：为插件声明名称，供依赖声明与 GetPluginByName 使用。
+// 内置插件在各自的包中导出 PluginName 常量，其他插件声明依赖时直接引用该常量
+type PluginWithName interface {
+	PluginName() string
+}
+
+// PluginWithDependencies 可选接口：声明必需依赖的插件名称，依赖缺失时引擎启动失败
+type PluginWithDependencies interface {
+	PluginDependencies() []string
+}
+
+// PluginWithOptionalDependencies 可选接口：声明可选依赖，仅在依赖已注册时影响初始化顺序
+type PluginWithOptionalDependencies interface {
+	PluginOptionalDependencies() []string
+}
+
+// PluginWithConfigSchema 可选接口：声明插件的配置项，引擎在初始化插件前统一校验并应用默认值
+type PluginWithConfigSchema interface {
+	ConfigSchema() *config.Schema
+}
+
 type AuthPlugin interface {
 	GetAuthInfo() (*commons.AuthInfo, error)
 	AuthName() string
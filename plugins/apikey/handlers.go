@@ -0,0 +1,210 @@
+package apikey
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/allentom/haruka"
+	"github.com/allentom/harukap/module/auth"
+	"github.com/project-xpolaris/youplustoolkit/youlink"
+	"gorm.io/gorm"
+)
+
+// Template key 的输出，不包含哈希
+type Template struct {
+	Id         uint     `json:"id"`
+	Name       string   `json:"name"`
+	Owner      string   `json:"owner"`
+	Hint       string   `json:"hint"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  string   `json:"expiresAt,omitempty"`
+	LastUsedAt string   `json:"lastUsedAt,omitempty"`
+	Revoked    bool     `json:"revoked"`
+	Created    string   `json:"created"`
+}
+
+const templateTimeFormat = "2006-01-02 15:04:05"
+
+func NewTemplate(key *APIKey) *Template {
+	template := &Template{
+		Id:      key.Id,
+		Name:    key.Name,
+		Owner:   key.Owner,
+		Hint:    key.Hint,
+		Scopes:  key.ScopeList(),
+		Revoked: key.Revoked,
+		Created: key.CreatedAt.Format(templateTimeFormat),
+	}
+	if key.ExpiresAt != nil {
+		template.ExpiresAt = key.ExpiresAt.Format(templateTimeFormat)
+	}
+	if key.LastUsedAt != nil {
+		template.LastUsedAt = key.LastUsedAt.Format(templateTimeFormat)
+	}
+	return template
+}
+
+type createRequestBody struct {
+	Name string `json:"name"`
+	// Owner 为空时为调用方，为其他用户创建需要 PermissionManage
+	Owner  string   `json:"owner"`
+	Scopes []string `json:"scopes"`
+	// ExpiresIn 有效秒数，为 0 时不过期
+	ExpiresIn int64 `json:"expiresIn"`
+}
+
+// principal 管理接口的调用方
+func (p *Plugin) principal(context *haruka.Context) (*auth.Principal, error) {
+	authorizer := p.Authorizer
+	if authorizer == nil {
+		authorizer = &auth.Authorizer{}
+	}
+	return authorizer.Principal(context)
+}
+
+// resolveOwner owner 为空时返回调用方，不是调用方时要求 PermissionManage
+func resolveOwner(principal *auth.Principal, owner string) (string, error) {
+	userId := principal.UserId()
+	if owner == "" {
+		owner = userId
+	}
+	if owner == "" {
+		return "", &auth.ForbiddenError{Reason: "caller has no user id"}
+	}
+	if owner != userId && !principal.HasPermission(PermissionManage) {
+		return "", &auth.ForbiddenError{Reason: fmt.Sprintf("require permission %s", PermissionManage)}
+	}
+	return owner, nil
+}
+
+// abortAuthError 未认证返回 401，没有权限返回 403，其他错误（如读取角色权限失败）返回 500
+func abortAuthError(err error, context *haruka.Context) {
+	var unauthorized *auth.UnauthorizedError
+	var forbidden *auth.ForbiddenError
+	switch {
+	case errors.As(err, &unauthorized):
+		youlink.AbortErrorWithStatus(err, context, http.StatusUnauthorized)
+	case errors.As(err, &forbidden):
+		youlink.AbortErrorWithStatus(err, context, http.StatusForbidden)
+	default:
+		youlink.AbortErrorWithStatus(err, context, http.StatusInternalServerError)
+	}
+}
+
+func (p *Plugin) createHandler(context *haruka.Context) {
+	principal, err := p.principal(context)
+	if err != nil {
+		abortAuthError(err, context)
+		return
+	}
+	var body createRequestBody
+	err = context.ParseJson(&body)
+	if err != nil {
+		youlink.AbortErrorWithStatus(err, context, http.StatusBadRequest)
+		return
+	}
+	if body.Name == "" {
+		youlink.AbortErrorWithStatus(errors.New("name is required"), context, http.StatusBadRequest)
+		return
+	}
+	owner, err := resolveOwner(principal, body.Owner)
+	if err != nil {
+		abortAuthError(err, context)
+		return
+	}
+	// key 的权限不能超出调用方的权限
+	for _, scope := range body.Scopes {
+		if !principal.HasPermission(scope) {
+			abortAuthError(&auth.ForbiddenError{Reason: fmt.Sprintf("cannot grant scope %s", scope)}, context)
+			return
+		}
+	}
+	key, apiKey, err := p.Create(CreateOption{
+		Name:   body.Name,
+		Owner:  owner,
+		Scopes: body.Scopes,
+		TTL:    time.Duration(body.ExpiresIn) * time.Second,
+	})
+	if err != nil {
+		youlink.AbortErrorWithStatus(err, context, http.StatusInternalServerError)
+		return
+	}
+	context.JSON(haruka.JSON{
+		"success": true,
+		"data": haruka.JSON{
+			"key":  key,
+			"info": NewTemplate(apiKey),
+		},
+	})
+}
+
+// listHandler owner 为空时列出调用方的 key，拥有 PermissionManage 时可以指定 owner 或使用 owner=* 列出全部
+func (p *Plugin) listHandler(context *haruka.Context) {
+	principal, err := p.principal(context)
+	if err != nil {
+		abortAuthError(err, context)
+		return
+	}
+	owner := context.GetQueryString("owner")
+	if owner == "*" && principal.HasPermission(PermissionManage) {
+		owner = ""
+	} else if owner, err = resolveOwner(principal, owner); err != nil {
+		abortAuthError(err, context)
+		return
+	}
+	keys, err := p.List(owner)
+	if err != nil {
+		youlink.AbortErrorWithStatus(err, context, http.StatusInternalServerError)
+		return
+	}
+	data := make([]*Template, 0, len(keys))
+	for _, key := range keys {
+		data = append(data, NewTemplate(key))
+	}
+	context.JSON(haruka.JSON{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// revokeHandler 只能吊销调用方自己的 key，拥有 PermissionManage 时可以吊销任意 key
+func (p *Plugin) revokeHandler(context *haruka.Context) {
+	principal, err := p.principal(context)
+	if err != nil {
+		abortAuthError(err, context)
+		return
+	}
+	id, err := strconv.ParseUint(context.GetQueryString("id"), 10, 64)
+	if err != nil {
+		youlink.AbortErrorWithStatus(errors.New("invalid id"), context, http.StatusBadRequest)
+		return
+	}
+	apiKey, err := p.Get(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		youlink.AbortErrorWithStatus(err, context, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		youlink.AbortErrorWithStatus(err, context, http.StatusInternalServerError)
+		return
+	}
+	if _, err = resolveOwner(principal, apiKey.Owner); err != nil {
+		abortAuthError(err, context)
+		return
+	}
+	err = p.Revoke(apiKey.Id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		youlink.AbortErrorWithStatus(err, context, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		youlink.AbortErrorWithStatus(err, context, http.StatusInternalServerError)
+		return
+	}
+	context.JSON(haruka.JSON{
+		"success": true,
+	})
+}
@@ -0,0 +1,105 @@
+package apikey
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/allentom/haruka"
+)
+
+type testCaller struct {
+	id          string
+	permissions []string
+}
+
+func (c *testCaller) GetUserId() string {
+	return c.id
+}
+
+func (c *testCaller) GetPermissions() []string {
+	return c.permissions
+}
+
+func callHandler(handler haruka.RequestHandler, caller *testCaller, method string, url string, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	context := &haruka.Context{
+		Request: httptest.NewRequest(method, url, strings.NewReader(body)),
+		Writer:  recorder,
+		Param:   map[string]interface{}{},
+	}
+	if caller != nil {
+		context.Param["claim"] = caller
+	}
+	handler(context)
+	return recorder
+}
+
+func TestCreateHandlerOwnerAndScopes(t *testing.T) {
+	plugin := newTestPlugin(t)
+	bob := &testCaller{id: "bob", permissions: []string{"task:*"}}
+	cases := []struct {
+		name   string
+		caller *testCaller
+		body   string
+		status int
+	}{
+		{"anonymous", nil, `{"name":"ci"}`, http.StatusUnauthorized},
+		{"own key", bob, `{"name":"ci","scopes":["task:read"]}`, http.StatusOK},
+		{"scope not granted", bob, `{"name":"ci","scopes":["user:delete"]}`, http.StatusForbidden},
+		{"wildcard not granted", bob, `{"name":"ci","scopes":["*"]}`, http.StatusForbidden},
+		{"other owner", bob, `{"name":"ci","owner":"alice"}`, http.StatusForbidden},
+		{"manager for other owner", &testCaller{id: "admin", permissions: []string{PermissionManage}}, `{"name":"ci","owner":"alice"}`, http.StatusOK},
+	}
+	for _, c := range cases {
+		recorder := callHandler(plugin.createHandler, c.caller, "POST", "/apikeys", c.body)
+		if recorder.Code != c.status {
+			t.Fatalf("%s: expected %d, got %d %s", c.name, c.status, recorder.Code, recorder.Body.String())
+		}
+	}
+	keys, _ := plugin.List("bob")
+	if len(keys) != 1 || keys[0].Scopes != "task:read" {
+		t.Fatalf("owner should default to caller, got %d keys", len(keys))
+	}
+	if keys, _ = plugin.List("alice"); len(keys) != 1 {
+		t.Fatal("manager should create key for other owner")
+	}
+}
+
+func TestListAndRevokeHandlerOwner(t *testing.T) {
+	plugin := newTestPlugin(t)
+	_, bobKey, _ := plugin.Create(CreateOption{Name: "ci", Owner: "bob"})
+	_, aliceKey, _ := plugin.Create(CreateOption{Name: "ci", Owner: "alice"})
+	bob := &testCaller{id: "bob"}
+	admin := &testCaller{id: "admin", permissions: []string{PermissionManage}}
+
+	recorder := callHandler(plugin.listHandler, bob, "GET", "/apikeys", "")
+	if recorder.Code != http.StatusOK || strings.Contains(recorder.Body.String(), "alice") {
+		t.Fatalf("caller should only list own keys, got %s", recorder.Body.String())
+	}
+	if recorder = callHandler(plugin.listHandler, bob, "GET", "/apikeys?owner=alice", ""); recorder.Code != http.StatusForbidden {
+		t.Fatalf("listing other owner should be forbidden, got %d", recorder.Code)
+	}
+	recorder = callHandler(plugin.listHandler, admin, "GET", "/apikeys?owner=*", "")
+	if !strings.Contains(recorder.Body.String(), "alice") || !strings.Contains(recorder.Body.String(), "bob") {
+		t.Fatalf("manager should list all keys, got %s", recorder.Body.String())
+	}
+
+	revoke := func(caller *testCaller, id uint) int {
+		return callHandler(plugin.revokeHandler, caller, "POST", fmt.Sprintf("/apikeys/revoke?id=%d", id), "").Code
+	}
+	if status := revoke(bob, aliceKey.Id); status != http.StatusForbidden {
+		t.Fatalf("revoking other owner's key should be forbidden, got %d", status)
+	}
+	if status := revoke(bob, bobKey.Id); status != http.StatusOK {
+		t.Fatalf("caller should revoke own key, got %d", status)
+	}
+	if status := revoke(admin, aliceKey.Id); status != http.StatusOK {
+		t.Fatalf("manager should revoke any key, got %d", status)
+	}
+	if status := revoke(admin, 999); status != http.StatusNotFound {
+		t.Fatalf("unknown key should be not found, got %d", status)
+	}
+}
@@ -0,0 +1,181 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/allentom/harukap/module/auth"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidKey = auth.NewCredentialError("invalid api key")
+	ErrKeyExpired = auth.NewCredentialError("api key expired")
+	ErrKeyRevoked = auth.NewCredentialError("api key revoked")
+)
+
+// ScopeAll 拥有所有权限
+const ScopeAll = "*"
+
+// APIKey 已创建的 key，KeyHash 为明文的 sha256
+type APIKey struct {
+	Id      uint   `gorm:"primaryKey"`
+	Name    string `gorm:"size:255"`
+	Owner   string `gorm:"index;size:255"`
+	KeyHash string `gorm:"uniqueIndex;size:64"`
+	// Hint 明文的前几位，用于在列表中区分 key
+	Hint string `gorm:"size:32"`
+	// Scopes 以空格分隔
+	Scopes     string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	Revoked    bool
+	CreatedAt  time.Time
+}
+
+func (APIKey) TableName() string {
+	return "harukap_api_keys"
+}
+
+// ScopeList 返回 key 的权限列表
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// HasScope 是否拥有指定权限
+func (k *APIKey) HasScope(scope string) bool {
+	for _, item := range k.ScopeList() {
+		if item == scope || item == ScopeAll {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPermissions 实现 auth.UserWithPermissions，key 的权限即其 scopes
+func (k *APIKey) GetPermissions() []string {
+	return k.ScopeList()
+}
+
+// GetUserId 实现 auth.UserWithId，key 代表其所有者
+func (k *APIKey) GetUserId() string {
+	return k.Owner
+}
+
+// CreateOption 创建 key 的参数，TTL 为 0 时不过期
+type CreateOption struct {
+	Name   string
+	Owner  string
+	Scopes []string
+	TTL    time.Duration
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (p *Plugin) generateKey() (string, error) {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	return p.KeyPrefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Create 创建 key，返回的明文不会被保存，只能在此时交给调用方
+func (p *Plugin) Create(option CreateOption) (string, *APIKey, error) {
+	key, err := p.generateKey()
+	if err != nil {
+		return "", nil, err
+	}
+	hintLength := len(p.KeyPrefix) + 4
+	apiKey := &APIKey{
+		Name:    option.Name,
+		Owner:   option.Owner,
+		KeyHash: hashKey(key),
+		Hint:    key[:hintLength],
+		Scopes:  strings.Join(option.Scopes, " "),
+	}
+	if option.TTL > 0 {
+		expiresAt := time.Now().Add(option.TTL)
+		apiKey.ExpiresAt = &expiresAt
+	}
+	err = p.DB.Create(apiKey).Error
+	if err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
+
+// List 列出 key，owner 为空时列出全部
+func (p *Plugin) List(owner string) ([]*APIKey, error) {
+	keys := make([]*APIKey, 0)
+	query := p.DB.Order("id desc")
+	if owner != "" {
+		query = query.Where("owner = ?", owner)
+	}
+	err := query.Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Get 按 id 查找 key
+func (p *Plugin) Get(id uint) (*APIKey, error) {
+	apiKey := &APIKey{}
+	err := p.DB.First(apiKey, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+// Revoke 吊销 key
+func (p *Plugin) Revoke(id uint) error {
+	result := p.DB.Model(&APIKey{}).Where("id = ?", id).Update("revoked", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Authenticate 校验 key 并记录最近使用时间
+func (p *Plugin) Authenticate(key string) (*APIKey, error) {
+	if !strings.HasPrefix(key, p.KeyPrefix) {
+		return nil, ErrInvalidKey
+	}
+	apiKey := &APIKey{}
+	err := p.DB.Where("key_hash = ?", hashKey(key)).First(apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.Revoked {
+		return nil, ErrKeyRevoked
+	}
+	now := time.Now()
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return nil, ErrKeyExpired
+	}
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= p.LastUsedInterval {
+		err = p.DB.Model(&APIKey{}).Where("id = ?", apiKey.Id).Update("last_used_at", now).Error
+		if err != nil {
+			return nil, err
+		}
+		apiKey.LastUsedAt = &now
+	}
+	return apiKey, nil
+}
@@ -0,0 +1,120 @@
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestPlugin(t *testing.T) *Plugin {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "apikey.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&APIKey{}); err != nil {
+		t.Fatal(err)
+	}
+	return &Plugin{DB: db, KeyPrefix: DefaultKeyPrefix, LastUsedInterval: time.Hour}
+}
+
+func TestCreateStoresHashOnly(t *testing.T) {
+	plugin := newTestPlugin(t)
+	key, apiKey, err := plugin.Create(CreateOption{Name: "ci", Owner: "bob", Scopes: []string{"task:read", "task:write"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, DefaultKeyPrefix) || !strings.HasPrefix(key, apiKey.Hint) || len(apiKey.Hint) != len(DefaultKeyPrefix)+4 {
+		t.Fatalf("unexpected key %s with hint %s", key, apiKey.Hint)
+	}
+	stored := &APIKey{}
+	plugin.DB.First(stored, apiKey.Id)
+	sum := sha256.Sum256([]byte(key))
+	if stored.KeyHash != hex.EncodeToString(sum[:]) || strings.Contains(stored.KeyHash, key) {
+		t.Fatal("only sha256 of key should be stored")
+	}
+	if !stored.HasScope("task:write") || stored.HasScope("task:delete") || stored.GetUserId() != "bob" {
+		t.Fatalf("unexpected scopes %v", stored.ScopeList())
+	}
+	other, _, _ := plugin.Create(CreateOption{Name: "ci", Owner: "bob"})
+	if other == key {
+		t.Fatal("keys should be random")
+	}
+	plugin.Create(CreateOption{Name: "admin", Owner: "alice", Scopes: []string{ScopeAll}})
+	keys, _ := plugin.List("bob")
+	if len(keys) != 2 {
+		t.Fatalf("keys should be listed by owner, got %d", len(keys))
+	}
+	keys, _ = plugin.List("")
+	if len(keys) != 3 || !keys[0].HasScope("anything") {
+		t.Fatalf("all keys should be listed newest first, got %d", len(keys))
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	plugin := newTestPlugin(t)
+	key, apiKey, _ := plugin.Create(CreateOption{Name: "ci", Owner: "bob"})
+	authenticated, err := plugin.Authenticate(key)
+	if err != nil || authenticated.Id != apiKey.Id {
+		t.Fatalf("key should authenticate, got %v", err)
+	}
+	for _, invalid := range []string{"", "other_" + key, key + "x", DefaultKeyPrefix + "unknown"} {
+		if _, err = plugin.Authenticate(invalid); err != ErrInvalidKey {
+			t.Fatalf("%q should be invalid, got %v", invalid, err)
+		}
+	}
+	if err = plugin.Revoke(apiKey.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = plugin.Authenticate(key); err != ErrKeyRevoked {
+		t.Fatalf("revoked key should be rejected, got %v", err)
+	}
+	if err = plugin.Revoke(999); err != gorm.ErrRecordNotFound {
+		t.Fatalf("unknown key should be reported, got %v", err)
+	}
+
+	expiring, apiKey, _ := plugin.Create(CreateOption{Name: "short", Owner: "bob", TTL: time.Hour})
+	if _, err = plugin.Authenticate(expiring); err != nil {
+		t.Fatal(err)
+	}
+	plugin.DB.Model(apiKey).Update("expires_at", time.Now().Add(-time.Second))
+	if _, err = plugin.Authenticate(expiring); err != ErrKeyExpired {
+		t.Fatalf("expired key should be rejected, got %v", err)
+	}
+}
+
+func TestAuthenticateLastUsed(t *testing.T) {
+	plugin := newTestPlugin(t)
+	key, apiKey, _ := plugin.Create(CreateOption{Name: "ci", Owner: "bob"})
+	lastUsed := func() time.Time {
+		stored := &APIKey{}
+		plugin.DB.First(stored, apiKey.Id)
+		if stored.LastUsedAt == nil {
+			return time.Time{}
+		}
+		return *stored.LastUsedAt
+	}
+	if !lastUsed().IsZero() {
+		t.Fatal("new key should not have last used time")
+	}
+	plugin.Authenticate(key)
+	first := lastUsed()
+	if first.IsZero() {
+		t.Fatal("last used time should be recorded")
+	}
+	// 间隔内不重复写入
+	plugin.Authenticate(key)
+	if !lastUsed().Equal(first) {
+		t.Fatal("last used time should not be updated within interval")
+	}
+	plugin.DB.Model(apiKey).Update("last_used_at", first.Add(-2*time.Hour))
+	plugin.Authenticate(key)
+	if !lastUsed().After(first.Add(-time.Hour)) {
+		t.Fatal("last used time should be updated after interval")
+	}
+}
@@ -0,0 +1,155 @@
+package apikey
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/allentom/haruka"
+	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/commons"
+	"github.com/allentom/harukap/config"
+	"github.com/allentom/harukap/module/auth"
+	"github.com/allentom/harukap/plugins/datasource"
+	"gorm.io/gorm"
+)
+
+const PluginName = "apikey"
+
+const (
+	// AuthType auth.<name>.type 的取值
+	AuthType = "apikey"
+	// DefaultKeyPrefix 生成的 key 的前缀，便于识别与密钥扫描
+	DefaultKeyPrefix = "hpk_"
+	// DefaultLastUsedInterval 最近使用时间的最小更新间隔，避免每次请求都写库
+	DefaultLastUsedInterval = time.Minute
+	// PermissionManage 拥有该权限的调用方可以管理其他用户的 key
+	PermissionManage = "apikey:manage"
+)
+
+// Plugin 面向脚本与服务的 API key 认证，key 只保存哈希，明文只在创建时返回一次。
+// 配置位于 type 为 apikey 的 auth.<name> 节点下。管理接口需在 AuthMiddleware 之后使用，
+// 调用方只能管理自己的 key，且 key 的 scopes 不能超出调用方的权限
+type Plugin struct {
+	ConfigPrefix string
+	DB           *gorm.DB
+	KeyPrefix    string
+	// AuthUserFromKey 将 key 转换为 AuthUser，为空时返回 *APIKey
+	AuthUserFromKey  func(key *APIKey) (commons.AuthUser, error)
+	LastUsedInterval time.Duration
+	// Authorizer 管理接口用于展开调用方的角色权限，为空时只使用调用方自身的权限
+	Authorizer    *auth.Authorizer
+	CreateHandler haruka.RequestHandler
+	ListHandler   haruka.RequestHandler
+	RevokeHandler haruka.RequestHandler
+}
+
+func (p *Plugin) getConfig(name string) string {
+	return p.ConfigPrefix + "." + name
+}
+
+func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
+	logger := e.LoggerPlugin.Logger.NewScope("APIKeyPlugin")
+	configer := e.ConfigProvider.Config()
+	if p.ConfigPrefix == "" {
+		prefix, err := auth.FindConfigPrefix(e.ConfigProvider, AuthType)
+		if err != nil {
+			return err
+		}
+		p.ConfigPrefix = prefix
+	}
+	if p.KeyPrefix == "" {
+		p.KeyPrefix = configer.GetString(p.getConfig("keyPrefix"))
+		if p.KeyPrefix == "" {
+			p.KeyPrefix = DefaultKeyPrefix
+		}
+	}
+	if p.LastUsedInterval == 0 {
+		p.LastUsedInterval = DefaultLastUsedInterval
+	}
+	if p.DB == nil {
+		db, err := datasource.ResolveDB(e, configer.GetString(p.getConfig("datasource")))
+		if err != nil {
+			return err
+		}
+		p.DB = db
+	}
+	logger.WithFields(map[string]interface{}{
+		"prefix":    p.ConfigPrefix,
+		"keyPrefix": p.KeyPrefix,
+	}).Info("apikey config")
+	if !e.IsDryRun() {
+		err := p.DB.AutoMigrate(&APIKey{})
+		if err != nil {
+			return fmt.Errorf("migrate apikey table failed: %v", err)
+		}
+	}
+	p.CreateHandler = p.createHandler
+	p.ListHandler = p.listHandler
+	p.RevokeHandler = p.revokeHandler
+	return nil
+}
+
+func (p *Plugin) PluginName() string {
+	return PluginName
+}
+
+func (p *Plugin) PluginDependencies() []string {
+	return []string{datasource.PluginName}
+}
+
+// ConfigSchema auth 下的配置由多种认证方式共享，不检查未声明的键
+func (p *Plugin) ConfigSchema() *config.Schema {
+	prefix := "auth.*"
+	if p.ConfigPrefix != "" {
+		prefix = p.ConfigPrefix
+	}
+	return &config.Schema{
+		Description: "apikey",
+		When:        map[string]string{"type": AuthType},
+		Fields: []config.Field{
+			{Key: prefix + ".datasource", Type: config.FieldTypeString, Default: "default", Description: "datasource to store api keys"},
+			{Key: prefix + ".keyPrefix", Type: config.FieldTypeString, Default: DefaultKeyPrefix, Description: "prefix of generated keys"},
+		},
+	}
+}
+
+func (p *Plugin) GetAuthInfo() (*commons.AuthInfo, error) {
+	return &commons.AuthInfo{
+		Name: "API Key",
+		Type: commons.AuthTypeAPIKey,
+		Url:  "",
+	}, nil
+}
+
+func (p *Plugin) AuthName() string {
+	return AuthType
+}
+
+// TokenTypeName API key 不是 jwt，不会通过 iss 匹配到本插件
+func (p *Plugin) TokenTypeName() string {
+	return AuthType
+}
+
+// GetAuthUserByToken 与 ParseAPIKey 相同
+func (p *Plugin) GetAuthUserByToken(token string) (commons.AuthUser, error) {
+	return p.ParseAPIKey(token)
+}
+
+// ParseAPIKey 实现 auth.APIKeyAuthenticator
+func (p *Plugin) ParseAPIKey(key string) (commons.AuthUser, error) {
+	apiKey, err := p.Authenticate(key)
+	if err != nil {
+		return nil, err
+	}
+	if p.AuthUserFromKey != nil {
+		return p.AuthUserFromKey(apiKey)
+	}
+	return apiKey, nil
+}
+
+func (p *Plugin) GetPluginConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"prefix":    p.ConfigPrefix,
+		"keyPrefix": p.KeyPrefix,
+	}
+}
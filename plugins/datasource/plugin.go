@@ -1,22 +1,28 @@
 package datasource
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/config"
 	util "github.com/allentom/harukap/utils"
 	"github.com/spf13/viper"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+const PluginName = "datasource"
+
 type Plugin struct {
 	DataSource  Datasource
 	Dialector   gorm.Dialector
 	OnConnected func(db *gorm.DB)
 	DBS         map[string]*gorm.DB
+	// targets 数据源地址，用于 doctor 输出
+	targets map[string]string
 }
 
 // 验证数据源配置
@@ -34,13 +40,14 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 	initLogger := e.LoggerPlugin.Logger.NewScope("DatasourcePlugin")
 	initLogger.Info("initializing datasource plugin")
 
-	configure := e.ConfigProvider.Manager
+	configure := e.ConfigProvider.Config()
 	dataSourceList := configure.GetStringMap("datasource")
 	if len(dataSourceList) == 0 {
 		return errors.New("no datasource configuration found")
 	}
 
 	p.DBS = make(map[string]*gorm.DB)
+	p.targets = make(map[string]string)
 
 	for source := range dataSourceList {
 		initLogger.Info("initializing datasource", "source", source)
@@ -64,6 +71,7 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 		case "sqlite":
 			dbSource = &Sqlite{}
 			fields["path"] = configure.GetString(fmt.Sprintf("%s.path", prefix))
+			p.targets[source] = fmt.Sprintf("%v", fields["path"])
 		case "mysql":
 			dbSource = &Mysql{}
 			fields["host"] = configure.GetString(fmt.Sprintf("%s.host", prefix))
@@ -73,13 +81,14 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 			pwd := configure.GetString(fmt.Sprintf("%s.password", prefix))
 			fields["username"] = util.MaskKeepHeadTail(user, 1, 1)
 			fields["password"] = util.MaskKeepHeadTail(pwd, 1, 2)
+			p.targets[source] = fmt.Sprintf("%v:%v/%v", fields["host"], fields["port"], fields["database"])
 		default:
 			return fmt.Errorf("unknown datasource type: %s", datasourceType)
 		}
 
 		initLogger.WithFields(fields).Info("datasource config")
 
-		dia, err := dbSource.OnGetDialector(e.ConfigProvider.Manager, prefix)
+		dia, err := dbSource.OnGetDialector(e.ConfigProvider.Config(), prefix)
 		if err != nil {
 			return fmt.Errorf("failed to create dialector for %s: %v", source, err)
 		}
@@ -108,7 +117,8 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 		sqlDB.SetMaxOpenConns(100)
 		sqlDB.SetConnMaxLifetime(time.Hour)
 
-		if p.OnConnected != nil {
+		// 试运行时不执行迁移等连接回调
+		if p.OnConnected != nil && !e.IsDryRun() {
 			p.OnConnected(db)
 		}
 
@@ -119,6 +129,41 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 	return nil
 }
 
+func (p *Plugin) PluginName() string {
+	return PluginName
+}
+
+// ConfigSchema datasource 下每一项为一个数据源
+func (p *Plugin) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "datasource",
+		Description: "database connections",
+		Fields: []config.Field{
+			{Key: "*.type", Type: config.FieldTypeString, Required: true, Enum: []string{"sqlite", "mysql"}, Description: "datasource type"},
+			{Key: "*.path", Type: config.FieldTypeString, Description: "sqlite database file"},
+			{Key: "*.host", Type: config.FieldTypeString, Description: "mysql host"},
+			{Key: "*.port", Type: config.FieldTypeString, Description: "mysql port"},
+			{Key: "*.database", Type: config.FieldTypeString, Description: "mysql database"},
+			{Key: "*.username", Type: config.FieldTypeString, Description: "mysql username", Secret: true},
+			{Key: "*.password", Type: config.FieldTypeString, Description: "mysql password", Secret: true},
+			{Key: "*.params", Type: config.FieldTypeMap, Description: "mysql dsn params"},
+		},
+		Validate: func(provider *config.Provider) config.ValidationErrors {
+			errs := make(config.ValidationErrors, 0)
+			for name := range provider.Config().GetStringMap("datasource") {
+				prefix := fmt.Sprintf("datasource.%s", name)
+				switch provider.Config().GetString(prefix + ".type") {
+				case "sqlite":
+					errs = append(errs, provider.RequireKeys(prefix+".path")...)
+				case "mysql":
+					errs = append(errs, provider.RequireKeys(prefix+".username", prefix+".password", prefix+".host", prefix+".port", prefix+".database")...)
+				}
+			}
+			return errs
+		},
+	}
+}
+
 func (p *Plugin) GetPluginConfig() map[string]interface{} {
 	cfg := map[string]interface{}{}
 	list := map[string]string{}
@@ -129,6 +174,87 @@ func (p *Plugin) GetPluginConfig() map[string]interface{} {
 	return cfg
 }
 
+// HealthCheck 依次 ping 所有数据源
+func (p *Plugin) HealthCheck(ctx context.Context) error {
+	for name, db := range p.DBS {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return fmt.Errorf("failed to get database instance for %s: %v", name, err)
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to ping database %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Probe 逐个 ping 数据源并查询数据库版本
+func (p *Plugin) Probe(ctx context.Context) []*harukap.ProbeResult {
+	results := make([]*harukap.ProbeResult, 0, len(p.DBS))
+	for name, db := range p.DBS {
+		results = append(results, harukap.Probe(ctx, fmt.Sprintf("datasource.%s", name), p.targets[name], func(ctx context.Context) (string, error) {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return "", err
+			}
+			if err = sqlDB.PingContext(ctx); err != nil {
+				return "", err
+			}
+			versionQuery := "SELECT VERSION()"
+			if db.Dialector.Name() == "sqlite" {
+				versionQuery = "SELECT sqlite_version()"
+			}
+			// 版本查询失败不影响连通性结果
+			var version string
+			_ = sqlDB.QueryRowContext(ctx, versionQuery).Scan(&version)
+			return version, nil
+		}))
+	}
+	return results
+}
+
+// OnShutdown 关闭所有数据源的连接池
+func (p *Plugin) OnShutdown() error {
+	var lastErr error
+	for name, db := range p.DBS {
+		sqlDB, err := db.DB()
+		if err != nil {
+			lastErr = fmt.Errorf("failed to get database instance for %s: %v", name, err)
+			continue
+		}
+		if err := sqlDB.Close(); err != nil {
+			lastErr = fmt.Errorf("failed to close database %s: %v", name, err)
+		}
+	}
+	return lastErr
+}
+
+// GetDB 按名称返回数据源，name 为空时使用 default，只有一个数据源时直接使用该数据源
+func (p *Plugin) GetDB(name string) (*gorm.DB, error) {
+	if name == "" {
+		name = "default"
+		if len(p.DBS) == 1 {
+			for sourceName := range p.DBS {
+				name = sourceName
+			}
+		}
+	}
+	db := p.DBS[name]
+	if db == nil {
+		return nil, fmt.Errorf("datasource %s not found", name)
+	}
+	return db, nil
+}
+
+// ResolveDB 供依赖数据源的插件使用：从引擎中找到 datasource 插件并按 GetDB 的规则返回数据源
+func ResolveDB(e *harukap.HarukaAppEngine, name string) (*gorm.DB, error) {
+	plugin, ok := harukap.GetPlugin[*Plugin](e)
+	if !ok {
+		return nil, errors.New("datasource plugin not registered")
+	}
+	return plugin.GetDB(name)
+}
+
 type Datasource interface {
 	OnGetDialector(config *viper.Viper, prefix string) (gorm.Dialector, error)
 }
@@ -1,12 +1,16 @@
 package deepdanbooru
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/config"
 )
 
+const PluginName = "deepdanbooru"
+
 type Plugin struct {
 	Client *Client
 	Enable bool
@@ -18,7 +22,7 @@ func NewPlugin() *Plugin {
 func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 	initLogger := e.LoggerPlugin.Logger.NewScope("DeepdanbooruPlugin")
 	initLogger.Info("init deepdanbooru plugin")
-	configure := e.ConfigProvider.Manager
+	configure := e.ConfigProvider.Config()
 	enable := configure.GetBool("deepdanbooru.enable")
 	if !enable {
 		initLogger.Info("deepdanbooru is disabled")
@@ -50,6 +54,23 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 	return nil
 }
 
+func (p *Plugin) PluginName() string {
+	return PluginName
+}
+
+func (p *Plugin) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "deepdanbooru",
+		Description: "deepdanbooru tagging service",
+		EnableKey:   "enable",
+		Fields: []config.Field{
+			{Key: "enable", Type: config.FieldTypeBool, Description: "enable deepdanbooru"},
+			{Key: "host", Type: config.FieldTypeString, Required: true, Description: "service url"},
+			{Key: "timeout", Type: config.FieldTypeInt, Default: 10000, Description: "request timeout in milliseconds"},
+		},
+	}
+}
+
 func (p *Plugin) GetPluginConfig() map[string]interface{} {
 	url := ""
 	if p.Client != nil && p.Client.conf != nil {
@@ -60,3 +81,18 @@ func (p *Plugin) GetPluginConfig() map[string]interface{} {
 		"url":    url,
 	}
 }
+
+// HealthCheck 检查 deepdanbooru 服务是否可用
+func (p *Plugin) HealthCheck(ctx context.Context) error {
+	if !p.Enable || p.Client == nil {
+		return harukap.ErrHealthCheckDisabled
+	}
+	infoResponse, err := p.Client.Info()
+	if err != nil {
+		return err
+	}
+	if !infoResponse.Success {
+		return fmt.Errorf("info response success is false")
+	}
+	return nil
+}
@@ -1,11 +1,15 @@
 package imageclassify
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/config"
 )
 
+const PluginName = "imageclassify"
+
 type Plugin struct {
 	Client *Client
 	Enable bool
@@ -17,7 +21,7 @@ func NewPlugin() *Plugin {
 func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 	initLogger := e.LoggerPlugin.Logger.NewScope("ImageClassifyPlugin")
 	initLogger.Info("init ImageClassify plugin")
-	configure := e.ConfigProvider.Manager
+	configure := e.ConfigProvider.Config()
 	enable := configure.GetBool("imageclassify.enable")
 	if !enable {
 		initLogger.Info("imageclassify is disabled")
@@ -42,6 +46,22 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 	return nil
 }
 
+func (p *Plugin) PluginName() string {
+	return PluginName
+}
+
+func (p *Plugin) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "imageclassify",
+		Description: "image classify service",
+		EnableKey:   "enable",
+		Fields: []config.Field{
+			{Key: "enable", Type: config.FieldTypeBool, Description: "enable image classify"},
+			{Key: "host", Type: config.FieldTypeString, Required: true, Description: "service url"},
+		},
+	}
+}
+
 func (p *Plugin) GetPluginConfig() map[string]interface{} {
 	url := ""
 	if p.Client != nil {
@@ -52,3 +72,18 @@ func (p *Plugin) GetPluginConfig() map[string]interface{} {
 		"url":    url,
 	}
 }
+
+// HealthCheck 检查 imageclassify 服务是否可用
+func (p *Plugin) HealthCheck(ctx context.Context) error {
+	if !p.Enable || p.Client == nil {
+		return harukap.ErrHealthCheckDisabled
+	}
+	infoResponse, err := p.Client.Info()
+	if err != nil {
+		return err
+	}
+	if !infoResponse.Success {
+		return fmt.Errorf("info response success is false")
+	}
+	return nil
+}
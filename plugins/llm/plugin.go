@@ -8,9 +8,12 @@ import (
 	"sync"
 
 	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/config"
 	"github.com/project-xpolaris/youplustoolkit/youlog"
 )
 
+const PluginName = "llm"
+
 // NewPlugin 创建新的LLM插件实例
 func NewPlugin() *LLMPlugin {
 	return &LLMPlugin{
@@ -25,6 +28,8 @@ type LLMPlugin struct {
 	providers map[string]LLMProvider
 	engine    *harukap.HarukaAppEngine
 	mutex     sync.RWMutex // 保护配置和提供商的并发访问
+	// fromConfigFile 配置是否来自配置文件，仅此时才响应配置文件变化
+	fromConfigFile bool
 }
 
 // SetConfig 设置配置
@@ -41,18 +46,19 @@ func (p *LLMPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 	// 如果没有配置，从配置文件加载
 	if p.Config == nil {
 		p.logger.Info("no config, use default config source")
+		p.fromConfigFile = true
 		p.Config = &LLMConfig{
-			Enable:  e.ConfigProvider.Manager.GetBool("llm.enable"),
-			Default: e.ConfigProvider.Manager.GetString("llm.default"),
+			Enable:  e.ConfigProvider.Config().GetBool("llm.enable"),
+			Default: e.ConfigProvider.Config().GetString("llm.default"),
 		}
 
 		// 加载OpenAI配置
-		if e.ConfigProvider.Manager.GetBool("llm.openai.enable") {
+		if e.ConfigProvider.Config().GetBool("llm.openai.enable") {
 			p.Config.OpenAI = &OpenAIConfig{
-				Enable:  e.ConfigProvider.Manager.GetBool("llm.openai.enable"),
-				APIKey:  e.ConfigProvider.Manager.GetString("llm.openai.api_key"),
-				BaseURL: e.ConfigProvider.Manager.GetString("llm.openai.base_url"),
-				Model:   e.ConfigProvider.Manager.GetString("llm.openai.model"),
+				Enable:  e.ConfigProvider.Config().GetBool("llm.openai.enable"),
+				APIKey:  e.ConfigProvider.Config().GetString("llm.openai.api_key"),
+				BaseURL: e.ConfigProvider.Config().GetString("llm.openai.base_url"),
+				Model:   e.ConfigProvider.Config().GetString("llm.openai.model"),
 			}
 			if p.Config.OpenAI.Model == "" {
 				p.Config.OpenAI.Model = "gpt-3.5-turbo" // 默认模型
@@ -60,11 +66,11 @@ func (p *LLMPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 		}
 
 		// 加载Ollama配置
-		if e.ConfigProvider.Manager.GetBool("llm.ollama.enable") {
+		if e.ConfigProvider.Config().GetBool("llm.ollama.enable") {
 			p.Config.Ollama = &OllamaConfig{
-				Enable:  e.ConfigProvider.Manager.GetBool("llm.ollama.enable"),
-				BaseURL: e.ConfigProvider.Manager.GetString("llm.ollama.base_url"),
-				Model:   e.ConfigProvider.Manager.GetString("llm.ollama.model"),
+				Enable:  e.ConfigProvider.Config().GetBool("llm.ollama.enable"),
+				BaseURL: e.ConfigProvider.Config().GetString("llm.ollama.base_url"),
+				Model:   e.ConfigProvider.Config().GetString("llm.ollama.model"),
 			}
 			if p.Config.Ollama.BaseURL == "" {
 				p.Config.Ollama.BaseURL = "http://localhost:11434" // 默认地址
@@ -75,13 +81,13 @@ func (p *LLMPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 		}
 
 		// 加载Gemini配置
-		if e.ConfigProvider.Manager.GetBool("llm.gemini.enable") {
+		if e.ConfigProvider.Config().GetBool("llm.gemini.enable") {
 			p.Config.Gemini = &GeminiConfig{
-				Enable:   e.ConfigProvider.Manager.GetBool("llm.gemini.enable"),
-				APIKey:   e.ConfigProvider.Manager.GetString("llm.gemini.api_key"),
-				Model:    e.ConfigProvider.Manager.GetString("llm.gemini.model"),
-				Location: e.ConfigProvider.Manager.GetString("llm.gemini.location"),
-				Project:  e.ConfigProvider.Manager.GetString("llm.gemini.project"),
+				Enable:   e.ConfigProvider.Config().GetBool("llm.gemini.enable"),
+				APIKey:   e.ConfigProvider.Config().GetString("llm.gemini.api_key"),
+				Model:    e.ConfigProvider.Config().GetString("llm.gemini.model"),
+				Location: e.ConfigProvider.Config().GetString("llm.gemini.location"),
+				Project:  e.ConfigProvider.Config().GetString("llm.gemini.project"),
 			}
 			if p.Config.Gemini.Model == "" {
 				p.Config.Gemini.Model = "gemini-pro" // 默认模型
@@ -132,6 +138,51 @@ func (p *LLMPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 	return nil
 }
 
+func (p *LLMPlugin) PluginName() string {
+	return PluginName
+}
+
+func (p *LLMPlugin) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "llm",
+		Description: "large language model providers",
+		EnableKey:   "enable",
+		Fields: []config.Field{
+			{Key: "enable", Type: config.FieldTypeBool, Description: "enable llm"},
+			{Key: "default", Type: config.FieldTypeString, Enum: []string{"openai", "ollama", "gemini"}, Description: "default provider"},
+			{Key: "openai.enable", Type: config.FieldTypeBool, Description: "enable openai"},
+			{Key: "openai.api_key", Type: config.FieldTypeString, Description: "openai api key", Secret: true},
+			{Key: "openai.base_url", Type: config.FieldTypeString, Description: "openai compatible base url"},
+			{Key: "openai.model", Type: config.FieldTypeString, Description: "openai model"},
+			{Key: "ollama.enable", Type: config.FieldTypeBool, Description: "enable ollama"},
+			{Key: "ollama.base_url", Type: config.FieldTypeString, Description: "ollama base url"},
+			{Key: "ollama.model", Type: config.FieldTypeString, Description: "ollama model"},
+			{Key: "gemini.enable", Type: config.FieldTypeBool, Description: "enable gemini"},
+			{Key: "gemini.api_key", Type: config.FieldTypeString, Description: "gemini api key", Secret: true},
+			{Key: "gemini.model", Type: config.FieldTypeString, Description: "gemini model"},
+			{Key: "gemini.location", Type: config.FieldTypeString, Description: "vertex ai location"},
+			{Key: "gemini.project", Type: config.FieldTypeString, Description: "vertex ai project"},
+		},
+		Validate: func(provider *config.Provider) config.ValidationErrors {
+			errs := make(config.ValidationErrors, 0)
+			manager := provider.Config()
+			if manager.GetBool("llm.openai.enable") {
+				errs = append(errs, provider.RequireKeys("llm.openai.api_key", "llm.openai.model")...)
+			}
+			if manager.GetBool("llm.ollama.enable") {
+				errs = append(errs, provider.RequireKeys("llm.ollama.base_url", "llm.ollama.model")...)
+			}
+			if manager.GetBool("llm.gemini.enable") {
+				errs = append(errs, provider.RequireKeys("llm.gemini.model")...)
+				if manager.GetString("llm.gemini.api_key") == "" {
+					errs = append(errs, provider.RequireKeys("llm.gemini.project", "llm.gemini.location")...)
+				}
+			}
+			return errs
+		},
+	}
+}
+
 // initProviders 初始化各个LLM提供商
 func (p *LLMPlugin) initProviders(ctx context.Context) error {
 	// 初始化OpenAI提供商
@@ -499,17 +550,17 @@ func (p *LLMPlugin) ReloadConfigWithPersistence(persist bool) error {
 
 	// 从配置文件重新加载配置
 	newConfig := &LLMConfig{
-		Enable:  p.engine.ConfigProvider.Manager.GetBool("llm.enable"),
-		Default: p.engine.ConfigProvider.Manager.GetString("llm.default"),
+		Enable:  p.engine.ConfigProvider.Config().GetBool("llm.enable"),
+		Default: p.engine.ConfigProvider.Config().GetString("llm.default"),
 	}
 
 	// 加载OpenAI配置
-	if p.engine.ConfigProvider.Manager.GetBool("llm.openai.enable") {
+	if p.engine.ConfigProvider.Config().GetBool("llm.openai.enable") {
 		newConfig.OpenAI = &OpenAIConfig{
-			Enable:  p.engine.ConfigProvider.Manager.GetBool("llm.openai.enable"),
-			APIKey:  p.engine.ConfigProvider.Manager.GetString("llm.openai.api_key"),
-			BaseURL: p.engine.ConfigProvider.Manager.GetString("llm.openai.base_url"),
-			Model:   p.engine.ConfigProvider.Manager.GetString("llm.openai.model"),
+			Enable:  p.engine.ConfigProvider.Config().GetBool("llm.openai.enable"),
+			APIKey:  p.engine.ConfigProvider.Config().GetString("llm.openai.api_key"),
+			BaseURL: p.engine.ConfigProvider.Config().GetString("llm.openai.base_url"),
+			Model:   p.engine.ConfigProvider.Config().GetString("llm.openai.model"),
 		}
 		if newConfig.OpenAI.Model == "" {
 			newConfig.OpenAI.Model = "gpt-3.5-turbo"
@@ -517,11 +568,11 @@ func (p *LLMPlugin) ReloadConfigWithPersistence(persist bool) error {
 	}
 
 	// 加载Ollama配置
-	if p.engine.ConfigProvider.Manager.GetBool("llm.ollama.enable") {
+	if p.engine.ConfigProvider.Config().GetBool("llm.ollama.enable") {
 		newConfig.Ollama = &OllamaConfig{
-			Enable:  p.engine.ConfigProvider.Manager.GetBool("llm.ollama.enable"),
-			BaseURL: p.engine.ConfigProvider.Manager.GetString("llm.ollama.base_url"),
-			Model:   p.engine.ConfigProvider.Manager.GetString("llm.ollama.model"),
+			Enable:  p.engine.ConfigProvider.Config().GetBool("llm.ollama.enable"),
+			BaseURL: p.engine.ConfigProvider.Config().GetString("llm.ollama.base_url"),
+			Model:   p.engine.ConfigProvider.Config().GetString("llm.ollama.model"),
 		}
 		if newConfig.Ollama.BaseURL == "" {
 			newConfig.Ollama.BaseURL = "http://localhost:11434"
@@ -532,13 +583,13 @@ func (p *LLMPlugin) ReloadConfigWithPersistence(persist bool) error {
 	}
 
 	// 加载Gemini配置
-	if p.engine.ConfigProvider.Manager.GetBool("llm.gemini.enable") {
+	if p.engine.ConfigProvider.Config().GetBool("llm.gemini.enable") {
 		newConfig.Gemini = &GeminiConfig{
-			Enable:   p.engine.ConfigProvider.Manager.GetBool("llm.gemini.enable"),
-			APIKey:   p.engine.ConfigProvider.Manager.GetString("llm.gemini.api_key"),
-			Model:    p.engine.ConfigProvider.Manager.GetString("llm.gemini.model"),
-			Location: p.engine.ConfigProvider.Manager.GetString("llm.gemini.location"),
-			Project:  p.engine.ConfigProvider.Manager.GetString("llm.gemini.project"),
+			Enable:   p.engine.ConfigProvider.Config().GetBool("llm.gemini.enable"),
+			APIKey:   p.engine.ConfigProvider.Config().GetString("llm.gemini.api_key"),
+			Model:    p.engine.ConfigProvider.Config().GetString("llm.gemini.model"),
+			Location: p.engine.ConfigProvider.Config().GetString("llm.gemini.location"),
+			Project:  p.engine.ConfigProvider.Config().GetString("llm.gemini.project"),
 		}
 		if newConfig.Gemini.Model == "" {
 			newConfig.Gemini.Model = "gemini-pro"
@@ -559,6 +610,19 @@ func (p *LLMPlugin) ReloadConfigWithPersistence(persist bool) error {
 	return p.UpdateConfigWithPersistence(newConfig, persist)
 }
 
+// ConfigPrefixes 订阅 llm 下的配置变化
+func (p *LLMPlugin) ConfigPrefixes() []string {
+	return []string{"llm"}
+}
+
+// OnConfigChange 配置文件变化时重新加载，新配置校验或初始化失败时拒绝变更
+func (p *LLMPlugin) OnConfigChange(change *config.ConfigChange) error {
+	if !p.fromConfigFile {
+		return nil
+	}
+	return p.ReloadConfig()
+}
+
 // GetCurrentConfig 获取当前配置的副本
 func (p *LLMPlugin) GetCurrentConfig() *LLMConfig {
 	p.mutex.RLock()
@@ -694,11 +758,11 @@ func (p *LLMPlugin) GetScenarioInfo(scenarioName string) (*BusinessScenarioConfi
 
 // saveConfigToFile 保存配置到配置文件
 func (p *LLMPlugin) saveConfigToFile() error {
-	if p.engine == nil || p.engine.ConfigProvider == nil || p.engine.ConfigProvider.Manager == nil {
+	if p.engine == nil || p.engine.ConfigProvider == nil || p.engine.ConfigProvider.Config() == nil {
 		return fmt.Errorf("configuration manager is not available")
 	}
 
-	manager := p.engine.ConfigProvider.Manager
+	manager := p.engine.ConfigProvider.Config()
 
 	// 记录保存前的配置状态
 	p.logger.WithFields(map[string]interface{}{
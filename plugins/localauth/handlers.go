@@ -0,0 +1,85 @@
+package localauth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/allentom/haruka"
+	"github.com/project-xpolaris/youplustoolkit/youlink"
+)
+
+type loginRequestBody struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type refreshRequestBody struct {
+	RefreshToken string `json:"refreshToken"`
+	// All 退出时使所有设备的 token 失效
+	All bool `json:"all"`
+}
+
+// errorStatus 认证相关错误返回 401，其余返回 500
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidCredentials),
+		errors.Is(err, ErrUserDisabled),
+		errors.Is(err, ErrInvalidToken),
+		errors.Is(err, ErrTokenRevoked):
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+func (p *Plugin) loginHandler(context *haruka.Context) {
+	var body loginRequestBody
+	err := context.ParseJson(&body)
+	if err != nil {
+		youlink.AbortErrorWithStatus(err, context, http.StatusBadRequest)
+		return
+	}
+	tokens, err := p.Login(body.Username, body.Password)
+	if err != nil {
+		youlink.AbortErrorWithStatus(err, context, errorStatus(err))
+		return
+	}
+	context.JSON(haruka.JSON{
+		"success": true,
+		"data":    tokens,
+	})
+}
+
+func (p *Plugin) refreshHandler(context *haruka.Context) {
+	var body refreshRequestBody
+	err := context.ParseJson(&body)
+	if err != nil {
+		youlink.AbortErrorWithStatus(err, context, http.StatusBadRequest)
+		return
+	}
+	tokens, err := p.Refresh(body.RefreshToken)
+	if err != nil {
+		youlink.AbortErrorWithStatus(err, context, errorStatus(err))
+		return
+	}
+	context.JSON(haruka.JSON{
+		"success": true,
+		"data":    tokens,
+	})
+}
+
+func (p *Plugin) logoutHandler(context *haruka.Context) {
+	var body refreshRequestBody
+	err := context.ParseJson(&body)
+	if err != nil {
+		youlink.AbortErrorWithStatus(err, context, http.StatusBadRequest)
+		return
+	}
+	err = p.Logout(body.RefreshToken, body.All)
+	if err != nil {
+		youlink.AbortErrorWithStatus(err, context, errorStatus(err))
+		return
+	}
+	context.JSON(haruka.JSON{
+		"success": true,
+	})
+}
@@ -0,0 +1,228 @@
+package localauth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/allentom/haruka"
+	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/commons"
+	"github.com/allentom/harukap/config"
+	"github.com/allentom/harukap/module/auth"
+	"github.com/allentom/harukap/plugins/datasource"
+	util "github.com/allentom/harukap/utils"
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+)
+
+const PluginName = "localauth"
+
+const (
+	// AuthType auth.<name>.type 的取值
+	AuthType = "local"
+	// TokenIssuer 签发 token 的 iss，AuthModule 据此找到本插件
+	TokenIssuer = "local"
+)
+
+const (
+	DefaultAccessTokenTTL  = time.Hour
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Plugin 基于 datasource 插件的本地用户名密码认证，签发自己的 access token 与 refresh token。
+// 配置位于 type 为 local 的 auth.<name> 节点下
+type Plugin struct {
+	ConfigPrefix string
+	DB           *gorm.DB
+	Hasher       PasswordHasher
+	// AuthUserFromUser 将用户转换为 AuthUser，为空时返回 *User
+	AuthUserFromUser func(user *User) (commons.AuthUser, error)
+	// LoginUrl 登录地址，通过 GetAuthInfo 提供给客户端
+	LoginUrl        string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	LoginHandler    haruka.RequestHandler
+	RefreshHandler  haruka.RequestHandler
+	LogoutHandler   haruka.RequestHandler
+	secret          []byte
+	dummyOnce       sync.Once
+	dummyHash       string
+}
+
+func (p *Plugin) getConfig(name string) string {
+	return p.ConfigPrefix + "." + name
+}
+
+func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
+	logger := e.LoggerPlugin.Logger.NewScope("LocalAuthPlugin")
+	configer := e.ConfigProvider.Config()
+	if p.ConfigPrefix == "" {
+		prefix, err := auth.FindConfigPrefix(e.ConfigProvider, AuthType)
+		if err != nil {
+			return err
+		}
+		p.ConfigPrefix = prefix
+	}
+	p.secret = []byte(configer.GetString(p.getConfig("secret")))
+	if len(p.secret) == 0 {
+		return fmt.Errorf("%s is required", p.getConfig("secret"))
+	}
+	if p.AccessTokenTTL == 0 {
+		p.AccessTokenTTL = DefaultAccessTokenTTL
+		if configer.IsSet(p.getConfig("accessTokenTtl")) {
+			p.AccessTokenTTL = time.Duration(configer.GetInt(p.getConfig("accessTokenTtl"))) * time.Second
+		}
+	}
+	if p.RefreshTokenTTL == 0 {
+		p.RefreshTokenTTL = DefaultRefreshTokenTTL
+		if configer.IsSet(p.getConfig("refreshTokenTtl")) {
+			p.RefreshTokenTTL = time.Duration(configer.GetInt(p.getConfig("refreshTokenTtl"))) * time.Second
+		}
+	}
+	if p.LoginUrl == "" {
+		p.LoginUrl = configer.GetString(p.getConfig("loginUrl"))
+	}
+	if p.Hasher == nil {
+		p.Hasher = &BcryptHasher{Cost: configer.GetInt(p.getConfig("bcryptCost"))}
+	}
+	if p.DB == nil {
+		db, err := datasource.ResolveDB(e, configer.GetString(p.getConfig("datasource")))
+		if err != nil {
+			return err
+		}
+		p.DB = db
+	}
+	logger.WithFields(map[string]interface{}{
+		"prefix":          p.ConfigPrefix,
+		"secret":          util.MaskKeepHeadTail(string(p.secret), 1, 2),
+		"accessTokenTtl":  p.AccessTokenTTL.String(),
+		"refreshTokenTtl": p.RefreshTokenTTL.String(),
+	}).Info("localauth config")
+	if !e.IsDryRun() {
+		err := p.DB.AutoMigrate(&User{}, &RefreshToken{})
+		if err != nil {
+			return fmt.Errorf("migrate localauth tables failed: %v", err)
+		}
+		err = p.initUser(configer.GetString(p.getConfig("init.username")), configer.GetString(p.getConfig("init.password")))
+		if err != nil {
+			return err
+		}
+	}
+	p.LoginHandler = p.loginHandler
+	p.RefreshHandler = p.refreshHandler
+	p.LogoutHandler = p.logoutHandler
+	return nil
+}
+
+// initUser 没有任何用户时创建 init.username 指定的初始用户
+func (p *Plugin) initUser(username string, password string) error {
+	if username == "" || password == "" {
+		return nil
+	}
+	var count int64
+	err := p.DB.Model(&User{}).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err = p.CreateUser(username, password)
+	if err != nil {
+		return fmt.Errorf("create init user failed: %v", err)
+	}
+	return nil
+}
+
+func (p *Plugin) PluginName() string {
+	return PluginName
+}
+
+func (p *Plugin) PluginDependencies() []string {
+	return []string{datasource.PluginName}
+}
+
+// ConfigSchema auth 下的配置由多种认证方式共享，不检查未声明的键
+func (p *Plugin) ConfigSchema() *config.Schema {
+	prefix := "auth.*"
+	if p.ConfigPrefix != "" {
+		prefix = p.ConfigPrefix
+	}
+	return &config.Schema{
+		Description: "localauth",
+		When:        map[string]string{"type": AuthType},
+		Fields: []config.Field{
+			{Key: prefix + ".secret", Type: config.FieldTypeString, Description: "secret to sign local tokens", Secret: true},
+			{Key: prefix + ".datasource", Type: config.FieldTypeString, Default: "default", Description: "datasource to store users"},
+			{Key: prefix + ".accessTokenTtl", Type: config.FieldTypeInt, Default: int(DefaultAccessTokenTTL.Seconds()), Description: "access token ttl in seconds"},
+			{Key: prefix + ".refreshTokenTtl", Type: config.FieldTypeInt, Default: int(DefaultRefreshTokenTTL.Seconds()), Description: "refresh token ttl in seconds"},
+			{Key: prefix + ".loginUrl", Type: config.FieldTypeString, Description: "login url returned in auth info"},
+			{Key: prefix + ".bcryptCost", Type: config.FieldTypeInt, Description: "bcrypt cost, default 10"},
+			{Key: prefix + ".init.username", Type: config.FieldTypeString, Description: "user created when there is no user"},
+			{Key: prefix + ".init.password", Type: config.FieldTypeString, Description: "password of init user", Secret: true},
+		},
+		Validate: func(provider *config.Provider) config.ValidationErrors {
+			errs := make(config.ValidationErrors, 0)
+			for key := range provider.Config().GetStringMap("auth") {
+				if provider.Config().GetString(fmt.Sprintf("auth.%s.type", key)) != AuthType {
+					continue
+				}
+				errs = append(errs, provider.RequireKeys(fmt.Sprintf("auth.%s.secret", key))...)
+			}
+			return errs
+		},
+	}
+}
+
+func (p *Plugin) GetAuthInfo() (*commons.AuthInfo, error) {
+	return &commons.AuthInfo{
+		Name: "Local",
+		Type: commons.AuthTypeBase,
+		Url:  p.LoginUrl,
+	}, nil
+}
+
+func (p *Plugin) AuthName() string {
+	return AuthType
+}
+
+func (p *Plugin) TokenTypeName() string {
+	return TokenIssuer
+}
+
+// IssuerKey AuthModule 通过它注册本插件签发 token 的 HMAC 密钥，密钥在 OnInit 后可用
+func (p *Plugin) IssuerKey() auth.KeyProvider {
+	return auth.KeyFunc(func(token *jwt.Token) (interface{}, error) {
+		if len(p.secret) == 0 {
+			return nil, errors.New("localauth plugin not initialized")
+		}
+		return p.secret, nil
+	})
+}
+
+// GetAuthUserByToken 校验 access token，并确认用户仍然可用且 token 未因退出所有设备而失效
+func (p *Plugin) GetAuthUserByToken(token string) (commons.AuthUser, error) {
+	claims, err := p.parseToken(token, tokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+	user, err := p.activeUser(claims)
+	if err != nil {
+		return nil, err
+	}
+	if p.AuthUserFromUser != nil {
+		return p.AuthUserFromUser(user)
+	}
+	return user, nil
+}
+
+func (p *Plugin) GetPluginConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"prefix":          p.ConfigPrefix,
+		"accessTokenTtl":  p.AccessTokenTTL.String(),
+		"refreshTokenTtl": p.RefreshTokenTTL.String(),
+		"loginUrl":        p.LoginUrl,
+	}
+}
@@ -0,0 +1,164 @@
+package localauth
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/allentom/harukap/module/auth"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestPlugin(t *testing.T) *Plugin {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "auth.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&User{}, &RefreshToken{}); err != nil {
+		t.Fatal(err)
+	}
+	plugin := &Plugin{
+		DB:              db,
+		Hasher:          &BcryptHasher{Cost: bcrypt.MinCost},
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+		secret:          []byte("secret"),
+	}
+	if _, err = plugin.CreateUser("bob", "password"); err != nil {
+		t.Fatal(err)
+	}
+	return plugin
+}
+
+func TestLogin(t *testing.T) {
+	plugin := newTestPlugin(t)
+	if _, err := plugin.CreateUser("bob", "other"); err != ErrUserExists {
+		t.Fatalf("duplicate user should be rejected, got %v", err)
+	}
+	pair, err := plugin.Login("bob", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := plugin.GetAuthUserByToken(pair.AccessToken)
+	if err != nil || user.(*User).Username != "bob" {
+		t.Fatalf("access token should resolve user, got %v", err)
+	}
+	if _, err = plugin.GetAuthUserByToken(pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("refresh token should not be accepted as access token, got %v", err)
+	}
+	// AuthModule 通过 IssuerKey 校验本插件签发的 token
+	verifier := auth.NewTokenVerifier()
+	verifier.SetIssuerKey(TokenIssuer, plugin.IssuerKey())
+	if _, err = verifier.Verify(pair.AccessToken); err != nil {
+		t.Fatalf("issuer key should verify access token, got %v", err)
+	}
+
+	if _, err = plugin.Login("bob", "wrong"); err != ErrInvalidCredentials {
+		t.Fatalf("wrong password should be rejected, got %v", err)
+	}
+	if _, err = plugin.Login("alice", "password"); err != ErrInvalidCredentials {
+		t.Fatalf("unknown user should be rejected with the same error, got %v", err)
+	}
+	if plugin.dummyHash == "" {
+		t.Fatal("unknown user should be compared against dummy hash")
+	}
+	plugin.SetDisabled("bob", true)
+	if _, err = plugin.Login("bob", "password"); err != ErrUserDisabled {
+		t.Fatalf("disabled user should be rejected, got %v", err)
+	}
+	if _, err = plugin.GetAuthUserByToken(pair.AccessToken); err != ErrUserDisabled {
+		t.Fatalf("token of disabled user should be rejected, got %v", err)
+	}
+}
+
+func TestRefreshRotation(t *testing.T) {
+	plugin := newTestPlugin(t)
+	pair, _ := plugin.Login("bob", "password")
+	next, err := plugin.Refresh(pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.RefreshToken == pair.RefreshToken {
+		t.Fatal("refresh token should be rotated")
+	}
+	if _, err = plugin.Refresh(pair.RefreshToken); err != ErrTokenRevoked {
+		t.Fatalf("used refresh token should be revoked, got %v", err)
+	}
+	if _, err = plugin.Refresh(next.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("access token should not be accepted as refresh token, got %v", err)
+	}
+	if err = plugin.Logout(next.RefreshToken, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = plugin.Refresh(next.RefreshToken); err != ErrTokenRevoked {
+		t.Fatalf("logged out refresh token should be revoked, got %v", err)
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	plugin := newTestPlugin(t)
+	first, _ := plugin.Login("bob", "password")
+	second, _ := plugin.Login("bob", "password")
+	if err := plugin.Logout(first.RefreshToken, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plugin.Refresh(second.RefreshToken); err != ErrTokenRevoked {
+		t.Fatalf("refresh tokens of other sessions should be revoked, got %v", err)
+	}
+	for _, pair := range []*TokenPair{first, second} {
+		if _, err := plugin.GetAuthUserByToken(pair.AccessToken); err != ErrTokenRevoked {
+			t.Fatalf("access tokens should be revoked by token version, got %v", err)
+		}
+	}
+	pair, err := plugin.Login("bob", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = plugin.GetAuthUserByToken(pair.AccessToken); err != nil {
+		t.Fatalf("new login should work after logout all, got %v", err)
+	}
+}
+
+func TestSetPasswordRevokesTokens(t *testing.T) {
+	plugin := newTestPlugin(t)
+	pair, _ := plugin.Login("bob", "password")
+	if err := plugin.SetPassword("bob", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plugin.GetAuthUserByToken(pair.AccessToken); err != ErrTokenRevoked {
+		t.Fatalf("token issued before password change should be revoked, got %v", err)
+	}
+	if _, err := plugin.Login("bob", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := plugin.SetPassword("alice", "changed"); err != gorm.ErrRecordNotFound {
+		t.Fatalf("unknown user should be reported, got %v", err)
+	}
+}
+
+func TestExpiredTokens(t *testing.T) {
+	plugin := newTestPlugin(t)
+	plugin.AccessTokenTTL = -time.Minute
+	plugin.RefreshTokenTTL = -time.Minute
+	pair, err := plugin.Login("bob", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = plugin.GetAuthUserByToken(pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired access token should be rejected, got %v", err)
+	}
+	if _, err = plugin.Refresh(pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired refresh token should be rejected, got %v", err)
+	}
+	if err = plugin.CleanExpiredTokens(); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	plugin.DB.Model(&RefreshToken{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expired refresh tokens should be cleaned, got %d", count)
+	}
+}
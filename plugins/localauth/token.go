@@ -0,0 +1,184 @@
+package localauth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/allentom/harukap/module/auth"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+var (
+	ErrInvalidToken = auth.NewCredentialError("invalid token")
+	ErrTokenRevoked = auth.NewCredentialError("token revoked")
+)
+
+// RefreshToken 已签发的 refresh token，刷新时轮换，退出时吊销
+type RefreshToken struct {
+	Id        string    `gorm:"primaryKey;size:64"`
+	UserId    uint      `gorm:"index"`
+	ExpiresAt time.Time `gorm:"index"`
+	Revoked   bool
+	CreatedAt time.Time
+}
+
+func (RefreshToken) TableName() string {
+	return "harukap_local_refresh_tokens"
+}
+
+// TokenPair 登录与刷新返回的 token
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	// ExpiresIn access token 有效秒数
+	ExpiresIn int64  `json:"expiresIn"`
+	TokenType string `json:"tokenType"`
+	Username  string `json:"username"`
+}
+
+type tokenClaims struct {
+	jwt.StandardClaims
+	Uid     uint   `json:"uid"`
+	Type    string `json:"typ"`
+	Version int    `json:"ver"`
+}
+
+func (p *Plugin) signToken(user *User, tokenType string, id string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := tokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        id,
+			Issuer:    TokenIssuer,
+			Subject:   user.Username,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+		Uid:     user.Id,
+		Type:    tokenType,
+		Version: user.TokenVersion,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(p.secret)
+}
+
+// IssueTokens 为用户签发 access token 与 refresh token
+func (p *Plugin) IssueTokens(user *User) (*TokenPair, error) {
+	accessToken, err := p.signToken(user, tokenTypeAccess, xid.New().String(), p.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh := &RefreshToken{
+		Id:        xid.New().String(),
+		UserId:    user.Id,
+		ExpiresAt: time.Now().Add(p.RefreshTokenTTL),
+	}
+	refreshToken, err := p.signToken(user, tokenTypeRefresh, refresh.Id, p.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	err = p.DB.Create(refresh).Error
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(p.AccessTokenTTL.Seconds()),
+		TokenType:    "Bearer",
+		Username:     user.Username,
+	}, nil
+}
+
+// parseToken 校验签名、有效期与 token 类型
+func (p *Plugin) parseToken(token string, tokenType string) (*tokenClaims, error) {
+	claims := &tokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return p.secret, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Issuer != TokenIssuer || claims.Type != tokenType {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// activeUser 用户仍然存在、未禁用且 token 版本一致
+func (p *Plugin) activeUser(claims *tokenClaims) (*User, error) {
+	user := &User{}
+	err := p.DB.First(user, claims.Uid).Error
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+	if user.TokenVersion != claims.Version {
+		return nil, ErrTokenRevoked
+	}
+	return user, nil
+}
+
+// Login 校验用户名密码并签发 token
+func (p *Plugin) Login(username string, password string) (*TokenPair, error) {
+	user, err := p.Authenticate(username, password)
+	if err != nil {
+		return nil, err
+	}
+	return p.IssueTokens(user)
+}
+
+// Refresh 使用 refresh token 换取新的 token，旧的 refresh token 随即吊销
+func (p *Plugin) Refresh(refreshToken string) (*TokenPair, error) {
+	claims, err := p.parseToken(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	result := p.DB.Model(&RefreshToken{}).
+		Where("id = ? AND revoked = ? AND expires_at > ?", claims.Id, false, time.Now()).
+		Update("revoked", true)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrTokenRevoked
+	}
+	user, err := p.activeUser(claims)
+	if err != nil {
+		return nil, err
+	}
+	return p.IssueTokens(user)
+}
+
+// Logout 吊销 refresh token，all 为 true 时使该用户所有已签发的 token 失效
+func (p *Plugin) Logout(refreshToken string, all bool) error {
+	claims, err := p.parseToken(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+	if all {
+		err = p.DB.Model(&RefreshToken{}).Where("user_id = ?", claims.Uid).Update("revoked", true).Error
+		if err != nil {
+			return err
+		}
+		return p.DB.Model(&User{}).Where("id = ?", claims.Uid).
+			Update("token_version", gorm.Expr("token_version + 1")).Error
+	}
+	return p.DB.Model(&RefreshToken{}).Where("id = ?", claims.Id).Update("revoked", true).Error
+}
+
+// CleanExpiredTokens 删除已过期或已吊销的 refresh token 记录
+func (p *Plugin) CleanExpiredTokens() error {
+	return p.DB.Where("expires_at < ? OR revoked = ?", time.Now(), true).Delete(&RefreshToken{}).Error
+}
@@ -0,0 +1,168 @@
+package localauth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/allentom/harukap/module/auth"
+	"github.com/rs/xid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCredentials = auth.NewCredentialError("invalid username or password")
+	ErrUserDisabled       = auth.NewCredentialError("user disabled")
+	ErrUserExists         = errors.New("user already exists")
+)
+
+// User 本地用户
+type User struct {
+	Id           uint   `gorm:"primaryKey" json:"id"`
+	Username     string `gorm:"uniqueIndex;size:255" json:"username"`
+	PasswordHash string `json:"-"`
+	Disabled     bool   `json:"disabled"`
+	// Roles 以空格分隔，权限由 auth.PolicyStore 按角色提供
+	Roles string `json:"roles"`
+	// TokenVersion 退出所有设备时递增，旧版本的 token 全部失效
+	TokenVersion int       `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+func (User) TableName() string {
+	return "harukap_local_users"
+}
+
+// GetRoles 实现 auth.UserWithRoles
+func (u *User) GetRoles() []string {
+	return strings.Fields(u.Roles)
+}
+
+// GetUserId 实现 auth.UserWithId
+func (u *User) GetUserId() string {
+	return u.Username
+}
+
+// PasswordHasher 密码哈希，默认为 bcrypt，可替换为 argon2 等实现
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Compare(hash string, password string) error
+}
+
+// BcryptHasher Cost 为 0 时使用 bcrypt.DefaultCost
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	cost := h.Cost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) Compare(hash string, password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
+
+// CreateUser 创建用户
+func (p *Plugin) CreateUser(username string, password string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return nil, errors.New("username and password are required")
+	}
+	var count int64
+	err := p.DB.Model(&User{}).Where("username = ?", username).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrUserExists
+	}
+	hash, err := p.Hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &User{Username: username, PasswordHash: hash}
+	err = p.DB.Create(user).Error
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetPassword 修改密码并使该用户已签发的 token 全部失效
+func (p *Plugin) SetPassword(username string, password string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+	hash, err := p.Hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	result := p.DB.Model(&User{}).Where("username = ?", username).Updates(map[string]interface{}{
+		"password_hash": hash,
+		"token_version": gorm.Expr("token_version + 1"),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetDisabled 禁用或启用用户，禁用后已签发的 token 立即失效
+func (p *Plugin) SetDisabled(username string, disabled bool) error {
+	return p.DB.Model(&User{}).Where("username = ?", username).Update("disabled", disabled).Error
+}
+
+// SetRoles 设置用户角色，下一次请求即生效
+func (p *Plugin) SetRoles(username string, roles ...string) error {
+	return p.DB.Model(&User{}).Where("username = ?", username).Update("roles", strings.Join(roles, " ")).Error
+}
+
+// GetUser 按用户名查找用户
+func (p *Plugin) GetUser(username string) (*User, error) {
+	user := &User{}
+	err := p.DB.Where("username = ?", username).First(user).Error
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// getDummyHash 用户不存在时用于比较的哈希，与真实密码使用相同的 Hasher，使两种情况耗时一致
+func (p *Plugin) getDummyHash() string {
+	p.dummyOnce.Do(func() {
+		p.dummyHash, _ = p.Hasher.Hash(xid.New().String())
+	})
+	return p.dummyHash
+}
+
+// Authenticate 校验用户名密码，用户不存在与密码错误返回相同的错误且耗时相近
+func (p *Plugin) Authenticate(username string, password string) (*User, error) {
+	user, err := p.GetUser(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		p.Hasher.Compare(p.getDummyHash(), password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.Hasher.Compare(user.PasswordHash, password) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+	return user, nil
+}
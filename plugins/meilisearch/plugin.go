@@ -1,20 +1,26 @@
 package meilisearch
 
 import (
+	"context"
+	"fmt"
 	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/config"
 	util "github.com/allentom/harukap/utils"
 	"github.com/meilisearch/meilisearch-go"
 )
 
+const PluginName = "meilisearch"
+
 type Plugin struct {
 	Client     meilisearch.ServiceManager
 	OnComplete func()
+	host       string
 }
 
 func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 	initLogger := e.LoggerPlugin.Logger.NewScope("MeiliSearchPlugin")
 	initLogger.Info("init MeiliSearch plugin")
-	configure := e.ConfigProvider.Manager
+	configure := e.ConfigProvider.Config()
 	enable := configure.GetBool("meilisearch.enable")
 	if !enable {
 		initLogger.Info("meilisearch is disabled")
@@ -34,6 +40,7 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 	initLogger.WithFields(map[string]interface{}{
 		"host": host,
 	}).Info("init meilisearch client")
+	p.host = host
 	p.Client = meilisearch.New(host, meilisearch.WithAPIKey(apiKey))
 	initLogger.Info("test meilisearch connection")
 	status, err := p.Client.Health()
@@ -41,15 +48,66 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 		return err
 	}
 	initLogger.Info("meilisearch connection success ", "status = ", status.Status)
-	if p.OnComplete != nil {
+	if p.OnComplete != nil && !e.IsDryRun() {
 		p.OnComplete()
 	}
 	return err
 }
 
+func (p *Plugin) PluginName() string {
+	return PluginName
+}
+
+func (p *Plugin) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "meilisearch",
+		Description: "meilisearch",
+		EnableKey:   "enable",
+		Fields: []config.Field{
+			{Key: "enable", Type: config.FieldTypeBool, Description: "enable meilisearch"},
+			{Key: "host", Type: config.FieldTypeString, Required: true, Description: "meilisearch url"},
+			{Key: "apiKey", Type: config.FieldTypeString, Description: "meilisearch api key", Secret: true},
+		},
+	}
+}
+
 func (p *Plugin) GetPluginConfig() map[string]interface{} {
 	return map[string]interface{}{
 		"host":   "configured",
 		"apiKey": "***",
 	}
 }
+
+// Probe 检查 MeiliSearch 服务状态并获取版本
+func (p *Plugin) Probe(ctx context.Context) []*harukap.ProbeResult {
+	if p.Client == nil {
+		return nil
+	}
+	return []*harukap.ProbeResult{
+		harukap.Probe(ctx, PluginName, p.host, func(ctx context.Context) (string, error) {
+			if err := p.HealthCheck(ctx); err != nil {
+				return "", err
+			}
+			version, err := p.Client.VersionWithContext(ctx)
+			if err != nil {
+				return "", nil
+			}
+			return version.PkgVersion, nil
+		}),
+	}
+}
+
+// HealthCheck 检查 MeiliSearch 服务状态
+func (p *Plugin) HealthCheck(ctx context.Context) error {
+	if p.Client == nil {
+		return harukap.ErrHealthCheckDisabled
+	}
+	status, err := p.Client.HealthWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	if status.Status != "available" {
+		return fmt.Errorf("meilisearch status = %s", status.Status)
+	}
+	return nil
+}
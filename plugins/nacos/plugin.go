@@ -1,6 +1,7 @@
 package nacos
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strconv"
@@ -16,6 +17,8 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const PluginName = "nacos"
+
 type NacosConfig struct {
 	Enable      bool   `json:"enable"`
 	Server      string `json:"server"`
@@ -32,6 +35,8 @@ type NacosPlugin struct {
 	namingClient naming_client.INamingClient
 	Config       *NacosConfig
 	Port         int
+	// registered OnInit 成功注册实例后为 true，试运行不注册，关闭时也不注销
+	registered bool
 }
 
 func NewNacosPlugin(config *NacosConfig, port int) *NacosPlugin {
@@ -47,10 +52,10 @@ func NewNacosPlugin(config *NacosConfig, port int) *NacosPlugin {
 // nacos.group, nacos.serviceName, nacos.serviceIp
 // 端口默认从 addr 解析（格式 host:port），失败则使用 fallbackPort
 func NewNacosPluginFromYAML(provider *config.Provider, defaultServiceName string, fallbackPort int) (*NacosPlugin, error) {
-	if provider == nil || provider.Manager == nil {
+	if provider == nil || provider.Config() == nil {
 		return nil, fmt.Errorf("nil config provider")
 	}
-	v := provider.Manager
+	v := provider.Config()
 	cfg := &NacosConfig{
 		Enable:      v.GetBool("nacos.enable"),
 		Server:      v.GetString("nacos.server"),
@@ -145,6 +150,10 @@ func (p *NacosPlugin) OnInit(engine *harukap.HarukaAppEngine) error {
 		return fmt.Errorf("failed to create nacos naming client: %v", err)
 	}
 
+	// 试运行只检查连接，不注册实例
+	if engine.IsDryRun() {
+		return nil
+	}
 	// 注册服务实例
 	success, err := p.namingClient.RegisterInstance(vo.RegisterInstanceParam{
 		Ip:          p.Config.ServiceIp,
@@ -162,10 +171,91 @@ func (p *NacosPlugin) OnInit(engine *harukap.HarukaAppEngine) error {
 		return fmt.Errorf("failed to register service instance: %v", err)
 	}
 
+	p.registered = true
 	p.Logger.Info("service registered to nacos successfully")
+	// 依赖健康状态变化时同步到注册实例，不健康的实例不会被其他服务选中
+	engine.OnHealthChange(func(report *harukap.HealthReport) {
+		err := p.updateInstanceHealth(report.Healthy())
+		if err != nil {
+			p.Logger.Error(err)
+		}
+	})
+	return nil
+}
+
+// updateInstanceHealth 更新注册实例的启用状态与 health 元数据
+func (p *NacosPlugin) updateInstanceHealth(healthy bool) error {
+	if p.namingClient == nil || !p.registered {
+		return nil
+	}
+	status := harukap.HealthStatusUp
+	if !healthy {
+		status = harukap.HealthStatusDown
+	}
+	_, err := p.namingClient.UpdateInstance(vo.UpdateInstanceParam{
+		Ip:          p.Config.ServiceIp,
+		Port:        uint64(p.Port),
+		ServiceName: p.Config.ServiceName,
+		Weight:      10,
+		Enable:      healthy,
+		Healthy:     true,
+		Ephemeral:   true,
+		GroupName:   p.Config.Group,
+		ClusterName: "DEFAULT",
+		Metadata:    map[string]string{"health": status},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update service instance health: %v", err)
+	}
+	p.Logger.Info(fmt.Sprintf("update nacos instance health = %s", status))
+	return nil
+}
+
+// Probe 检查与 Nacos 服务端的连接
+func (p *NacosPlugin) Probe(ctx context.Context) []*harukap.ProbeResult {
+	if p.namingClient == nil {
+		return nil
+	}
+	return []*harukap.ProbeResult{
+		harukap.Probe(ctx, PluginName, p.Config.Server, func(ctx context.Context) (string, error) {
+			return "", p.HealthCheck(ctx)
+		}),
+	}
+}
+
+// HealthCheck 检查与 Nacos 服务端的连接
+func (p *NacosPlugin) HealthCheck(ctx context.Context) error {
+	if p.namingClient == nil {
+		return harukap.ErrHealthCheckDisabled
+	}
+	if !p.namingClient.ServerHealthy() {
+		return fmt.Errorf("nacos server is unhealthy")
+	}
 	return nil
 }
 
+func (p *NacosPlugin) PluginName() string {
+	return PluginName
+}
+
+func (p *NacosPlugin) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "nacos",
+		Description: "nacos service discovery",
+		EnableKey:   "enable",
+		Fields: []config.Field{
+			{Key: "enable", Type: config.FieldTypeBool, Description: "enable nacos"},
+			{Key: "server", Type: config.FieldTypeString, Required: true, Description: "nacos server, host:port"},
+			{Key: "namespaceId", Type: config.FieldTypeString, Description: "namespace id"},
+			{Key: "username", Type: config.FieldTypeString, Description: "nacos username"},
+			{Key: "password", Type: config.FieldTypeString, Description: "nacos password", Secret: true},
+			{Key: "group", Type: config.FieldTypeString, Default: "DEFAULT_GROUP", Description: "service group"},
+			{Key: "serviceName", Type: config.FieldTypeString, Description: "registered service name, defaults to the application name"},
+			{Key: "serviceIp", Type: config.FieldTypeString, Description: "registered ip, detected when empty"},
+		},
+	}
+}
+
 func (p *NacosPlugin) GetPluginConfig() map[string]interface{} {
 	if p.Config == nil {
 		return nil
@@ -184,7 +274,10 @@ func (p *NacosPlugin) GetPluginConfig() map[string]interface{} {
 }
 
 func (p *NacosPlugin) OnShutdown() error {
-	if p.namingClient != nil {
+	if p.namingClient == nil {
+		return nil
+	}
+	if p.registered {
 		_, err := p.namingClient.DeregisterInstance(vo.DeregisterInstanceParam{
 			Ip:          p.Config.ServiceIp,
 			Port:        uint64(p.Port),
@@ -195,8 +288,11 @@ func (p *NacosPlugin) OnShutdown() error {
 		if err != nil {
 			return fmt.Errorf("failed to deregister service instance: %v", err)
 		}
+		p.registered = false
 		p.Logger.Info("service deregistered from nacos successfully")
 	}
+	p.namingClient.CloseClient()
+	p.namingClient = nil
 	return nil
 }
 
@@ -2,6 +2,7 @@ package nsfwcheck
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	util "github.com/allentom/harukap/utils"
@@ -48,8 +49,14 @@ func (c *Client) Predict(reader io.Reader) ([]Predictions, error) {
 	return result.Data, nil
 }
 func (c *Client) Info() (*InfoResponse, error) {
+	return c.InfoWithContext(context.Background())
+}
+
+// InfoWithContext 与 Info 相同，ctx 取消时中断请求
+func (c *Client) InfoWithContext(ctx context.Context) (*InfoResponse, error) {
 	result := &InfoResponse{}
 	_, err := c.client.R().
+		SetContext(ctx).
 		SetResult(result).
 		Get(c.BaseUrl + "/info")
 	if err != nil {
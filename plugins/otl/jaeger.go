@@ -9,8 +9,8 @@ import (
 )
 
 func newDefaultJaegerExporter(e *harukap.HarukaAppEngine) (*trace.TracerProvider, error) {
-	endpointUrl := e.ConfigProvider.Manager.GetString("otl.exporter.jaeger.endpoint")
-	serviceName := e.ConfigProvider.Manager.GetString("otl.name")
+	endpointUrl := e.ConfigProvider.Config().GetString("otl.exporter.jaeger.endpoint")
+	serviceName := e.ConfigProvider.Config().GetString("otl.name")
 	exp, err := jaeger.New(
 		jaeger.WithCollectorEndpoint(
 			jaeger.WithEndpoint(endpointUrl),
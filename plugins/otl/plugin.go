@@ -1,11 +1,16 @@
 package otl
 
 import (
+	"context"
+
 	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/config"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
 )
 
+const PluginName = "otl"
+
 type OpenTelemetryPlugin struct {
 	Provider trace.TracerProvider
 }
@@ -18,8 +23,8 @@ func NewOpenTelemetryPlugin(Provider trace.TracerProvider) *OpenTelemetryPlugin
 
 func (o *OpenTelemetryPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 	logger := e.LoggerPlugin.Logger.NewScope("OpenTelemetryPlugin")
-	endpointUrl := e.ConfigProvider.Manager.GetString("otl.exporter.jaeger.endpoint")
-	serviceName := e.ConfigProvider.Manager.GetString("otl.name")
+	endpointUrl := e.ConfigProvider.Config().GetString("otl.exporter.jaeger.endpoint")
+	serviceName := e.ConfigProvider.Config().GetString("otl.name")
 	logger.WithFields(map[string]interface{}{
 		"exporter.jaeger.endpoint": endpointUrl,
 		"serviceName":              serviceName,
@@ -36,9 +41,35 @@ func (o *OpenTelemetryPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 	return nil
 }
 
+func (o *OpenTelemetryPlugin) PluginName() string {
+	return PluginName
+}
+
+func (o *OpenTelemetryPlugin) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "otl",
+		Description: "opentelemetry",
+		Fields: []config.Field{
+			{Key: "name", Type: config.FieldTypeString, Required: true, Description: "service name"},
+			{Key: "exporter.jaeger.endpoint", Type: config.FieldTypeString, Required: true, Description: "jaeger collector endpoint"},
+		},
+	}
+}
+
 func (o *OpenTelemetryPlugin) GetPluginConfig() map[string]interface{} {
 	return map[string]interface{}{
 		"enabled":         true,
 		"exporter.jaeger": "configured",
 	}
 }
+
+// OnShutdown 刷新并关闭支持 Shutdown 的 TracerProvider
+func (o *OpenTelemetryPlugin) OnShutdown() error {
+	provider, ok := otel.GetTracerProvider().(interface {
+		Shutdown(ctx context.Context) error
+	})
+	if !ok {
+		return nil
+	}
+	return provider.Shutdown(context.Background())
+}
@@ -13,6 +13,7 @@ import (
 type RegisterClient struct {
 	Client    *clientv3.Client
 	Endpoints []string
+	Services  []*Service
 }
 
 func (c *RegisterClient) Init() error {
@@ -41,9 +42,9 @@ func RegisterFromFile(configPath string, client *RegisterClient) error {
 	serviceList := data["services"].(map[string]interface{})
 	for _, rawService := range serviceList {
 		content := rawService.(map[string]interface{})
-		service := Service{}
+		service := &Service{}
 		service.Id = fmt.Sprintf("%s-%s", prefix, xid.New().String())
-		err = mapstructure.Decode(content, &service)
+		err = mapstructure.Decode(content, service)
 		if err != nil {
 			return err
 		}
@@ -55,6 +56,24 @@ func RegisterFromFile(configPath string, client *RegisterClient) error {
 		if err != nil {
 			return err
 		}
+		client.Services = append(client.Services, service)
 	}
 	return nil
 }
+
+// Close 注销所有已注册的服务并关闭 etcd 客户端
+func (c *RegisterClient) Close() error {
+	var lastErr error
+	for _, service := range c.Services {
+		if err := service.UnRegister(); err != nil {
+			lastErr = err
+		}
+	}
+	c.Services = nil
+	if c.Client != nil {
+		if err := c.Client.Close(); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
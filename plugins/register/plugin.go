@@ -1,12 +1,18 @@
 package register
 
 import (
+	"context"
+
 	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/config"
 	"github.com/project-xpolaris/youplustoolkit/youlog"
 )
 
+const PluginName = "register"
+
 type RegisterPlugin struct {
 	Config *RegisterConfig
+	Client *RegisterClient
 	logger *youlog.Scope
 }
 
@@ -18,9 +24,9 @@ func (p *RegisterPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 	if p.Config == nil {
 		p.logger.Info("no config,use default config source")
 		p.Config = &RegisterConfig{
-			Enable:    e.ConfigProvider.Manager.GetBool("register.enable"),
-			Endpoints: e.ConfigProvider.Manager.GetStringSlice("register.endpoints"),
-			RegPath:   e.ConfigProvider.Manager.GetString("register.regpath"),
+			Enable:    e.ConfigProvider.Config().GetBool("register.enable"),
+			Endpoints: e.ConfigProvider.Config().GetStringSlice("register.endpoints"),
+			RegPath:   e.ConfigProvider.Config().GetString("register.regpath"),
 		}
 	}
 	p.logger.WithFields(map[string]interface{}{
@@ -32,14 +38,60 @@ func (p *RegisterPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 		p.logger.Info("register plugin is disabled")
 		return nil
 	}
-	client := RegisterClient{
+	p.Client = &RegisterClient{
 		Endpoints: p.Config.Endpoints,
 	}
-	err := client.Init()
+	err := p.Client.Init()
 	if err != nil {
 		return err
 	}
-	return RegisterFromFile(p.Config.RegPath, &client)
+	if e.IsDryRun() {
+		return nil
+	}
+	return RegisterFromFile(p.Config.RegPath, p.Client)
+}
+
+func (p *RegisterPlugin) PluginName() string {
+	return PluginName
+}
+
+func (p *RegisterPlugin) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "register",
+		Description: "etcd service register",
+		EnableKey:   "enable",
+		Fields: []config.Field{
+			{Key: "enable", Type: config.FieldTypeBool, Description: "enable service register"},
+			{Key: "endpoints", Type: config.FieldTypeList, Required: true, Description: "etcd endpoints"},
+			{Key: "regpath", Type: config.FieldTypeString, Required: true, Description: "register yaml file"},
+		},
+	}
+}
+
+// Probe 逐个查询 etcd 节点状态与版本
+func (p *RegisterPlugin) Probe(ctx context.Context) []*harukap.ProbeResult {
+	if p.Client == nil || p.Client.Client == nil {
+		return nil
+	}
+	results := make([]*harukap.ProbeResult, 0, len(p.Client.Endpoints))
+	for _, endpoint := range p.Client.Endpoints {
+		results = append(results, harukap.Probe(ctx, "etcd", endpoint, func(ctx context.Context) (string, error) {
+			status, err := p.Client.Client.Status(ctx, endpoint)
+			if err != nil {
+				return "", err
+			}
+			return status.Version, nil
+		}))
+	}
+	return results
+}
+
+// OnShutdown 注销服务并关闭 etcd 客户端
+func (p *RegisterPlugin) OnShutdown() error {
+	if p.Client == nil {
+		return nil
+	}
+	return p.Client.Close()
 }
 
 func (p *RegisterPlugin) GetPluginConfig() map[string]interface{} {
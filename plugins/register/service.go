@@ -60,5 +60,11 @@ func (s *Service) UnRegister() error {
 	if err != nil {
 		return err
 	}
+	if s.lease != nil {
+		_, err = s.lease.Revoke(context.TODO(), s.leaseId)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
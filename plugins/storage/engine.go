@@ -1,18 +1,38 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"sync"
 
 	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/config"
 )
 
+const PluginName = "storage"
+
 type Engine struct {
 	storages map[string]FileSystem
+	engine   *harukap.HarukaAppEngine
+	mutex    sync.RWMutex
 }
 
 func (e *Engine) OnInit(engine *harukap.HarukaAppEngine) error {
-	e.storages = make(map[string]FileSystem)
-	manager := engine.ConfigProvider.Manager
+	e.engine = engine
+	storages, err := e.loadStorages(engine)
+	if err != nil {
+		return err
+	}
+	e.mutex.Lock()
+	e.storages = storages
+	e.mutex.Unlock()
+	return nil
+}
+
+// loadStorages 按 storage 配置创建所有存储
+func (e *Engine) loadStorages(engine *harukap.HarukaAppEngine) (map[string]FileSystem, error) {
+	storages := make(map[string]FileSystem)
+	manager := engine.ConfigProvider.Config()
 	rawStorageConfig := manager.GetStringMapString("storage")
 	for name := range rawStorageConfig {
 		storageType := manager.GetString(fmt.Sprintf("storage.%s.type", name))
@@ -31,30 +51,112 @@ func (e *Engine) OnInit(engine *harukap.HarukaAppEngine) error {
 			}
 			err := s3Plugin.OnInit(engine)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			e.storages[name] = s3Plugin
+			storages[name] = s3Plugin
 		case "local":
 			localPlugin := &LocalStorage{
 				ConfigName: name,
 			}
 			err := localPlugin.OnInit(engine)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			e.storages[name] = localPlugin
+			storages[name] = localPlugin
 		default:
-			return fmt.Errorf("unknown strage type: %s", storageType)
+			return nil, fmt.Errorf("unknown strage type: %s", storageType)
 		}
 	}
+	return storages, nil
+}
+
+func (e *Engine) PluginName() string {
+	return PluginName
+}
+
+func (e *Engine) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "storage",
+		Description: "file storages",
+		Fields: []config.Field{
+			{Key: "*.type", Type: config.FieldTypeString, Required: true, Enum: []string{"s3", "local"}, Description: "storage type"},
+			{Key: "*.path", Type: config.FieldTypeString, Description: "local storage root"},
+			{Key: "*.id", Type: config.FieldTypeString, Description: "s3 access key id", Secret: true},
+			{Key: "*.secret", Type: config.FieldTypeString, Description: "s3 secret access key", Secret: true},
+			{Key: "*.region", Type: config.FieldTypeString, Description: "s3 region"},
+			{Key: "*.token", Type: config.FieldTypeString, Description: "s3 session token", Secret: true},
+			{Key: "*.endpoint", Type: config.FieldTypeString, Description: "s3 endpoint"},
+			{Key: "*.password", Type: config.FieldTypeString, Description: "s3 password", Secret: true},
+		},
+		Validate: func(provider *config.Provider) config.ValidationErrors {
+			errs := make(config.ValidationErrors, 0)
+			for name := range provider.Config().GetStringMap("storage") {
+				prefix := fmt.Sprintf("storage.%s", name)
+				if provider.Config().GetString(prefix+".type") == "local" {
+					errs = append(errs, provider.RequireKeys(prefix+".path")...)
+				}
+			}
+			return errs
+		},
+	}
+}
+
+// ConfigPrefixes 订阅 storage 下的配置变化
+func (e *Engine) ConfigPrefixes() []string {
+	return []string{"storage"}
+}
+
+// OnConfigChange 按新配置重建所有存储，创建失败时拒绝变更并保留原有存储
+func (e *Engine) OnConfigChange(change *config.ConfigChange) error {
+	storages, err := e.loadStorages(e.engine)
+	if err != nil {
+		return err
+	}
+	e.mutex.Lock()
+	e.storages = storages
+	e.mutex.Unlock()
 	return nil
 }
 
+// Probe 逐个检查已配置的存储
+func (e *Engine) Probe(ctx context.Context) []*harukap.ProbeResult {
+	e.mutex.RLock()
+	storages := make(map[string]FileSystem, len(e.storages))
+	for name, fs := range e.storages {
+		storages[name] = fs
+	}
+	e.mutex.RUnlock()
+	results := make([]*harukap.ProbeResult, 0, len(storages))
+	for name, fs := range storages {
+		target := ""
+		switch storage := fs.(type) {
+		case *S3Client:
+			target = storage.Config.Endpoint
+		case *LocalStorage:
+			target = storage.Config.Path
+		}
+		pinger, ok := fs.(interface {
+			Ping(ctx context.Context) error
+		})
+		if !ok {
+			continue
+		}
+		results = append(results, harukap.Probe(ctx, fmt.Sprintf("storage.%s", name), target, func(ctx context.Context) (string, error) {
+			return "", pinger.Ping(ctx)
+		}))
+	}
+	return results
+}
+
 func (e *Engine) GetStorage(name string) FileSystem {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
 	return e.storages[name]
 }
 
 func (e *Engine) GetPluginConfig() map[string]interface{} {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
 	cfg := map[string]interface{}{}
 	for name, fs := range e.storages {
 		_ = fs
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 
 	"github.com/allentom/harukap"
@@ -64,7 +65,7 @@ func (l *LocalStorage) OnInit(e *harukap.HarukaAppEngine) error {
 	baseKeyPath := fmt.Sprintf("storage.%s", l.ConfigName)
 	if l.Config == nil {
 		l.Config = &LocalStorageConfig{
-			Path: e.ConfigProvider.Manager.GetString(baseKeyPath + ".path"),
+			Path: e.ConfigProvider.Config().GetString(baseKeyPath + ".path"),
 		}
 	}
 	logger := e.LoggerPlugin.Logger.NewScope("LocalStorage")
@@ -103,6 +104,18 @@ func (l *LocalStorage) Init() error {
 	return nil
 }
 
+// Ping 检查存储目录是否存在
+func (l *LocalStorage) Ping(ctx context.Context) error {
+	info, err := os.Stat(l.Config.Path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", l.Config.Path)
+	}
+	return nil
+}
+
 func (l *LocalStorage) Get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
 	file, err := l.fs.Open(filepath.Join(bucket, key))
 	if err != nil {
@@ -77,12 +77,12 @@ func (c *S3Client) OnInit(e *harukap.HarukaAppEngine) error {
 	baseKeyPath := fmt.Sprintf("storage.%s", c.ConfigName)
 	if c.Config == nil {
 		c.Config = &S3ClientConfig{
-			Id:       e.ConfigProvider.Manager.GetString(baseKeyPath + ".id"),
-			Secret:   e.ConfigProvider.Manager.GetString(baseKeyPath + ".secret"),
-			Region:   e.ConfigProvider.Manager.GetString(baseKeyPath + ".region"),
-			Token:    e.ConfigProvider.Manager.GetString(baseKeyPath + ".token"),
-			Endpoint: e.ConfigProvider.Manager.GetString(baseKeyPath + ".endpoint"),
-			Password: e.ConfigProvider.Manager.GetString(baseKeyPath + ".password"),
+			Id:       e.ConfigProvider.Config().GetString(baseKeyPath + ".id"),
+			Secret:   e.ConfigProvider.Config().GetString(baseKeyPath + ".secret"),
+			Region:   e.ConfigProvider.Config().GetString(baseKeyPath + ".region"),
+			Token:    e.ConfigProvider.Config().GetString(baseKeyPath + ".token"),
+			Endpoint: e.ConfigProvider.Config().GetString(baseKeyPath + ".endpoint"),
+			Password: e.ConfigProvider.Config().GetString(baseKeyPath + ".password"),
 		}
 	}
 	logger := e.LoggerPlugin.Logger.NewScope("S3Storage")
@@ -114,6 +114,12 @@ func (c *S3Client) Init() error {
 	return nil
 }
 
+// Ping 列出 bucket 以检查端点与凭据是否可用
+func (c *S3Client) Ping(ctx context.Context) error {
+	_, err := c.Service.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+	return err
+}
+
 func (c *S3Client) Upload(ctx context.Context, body io.Reader, bucket string, key string) error {
 	buf, err := ioutil.ReadAll(body)
 	if err != nil {
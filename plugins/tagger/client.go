@@ -2,6 +2,7 @@ package tagger
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -158,6 +159,11 @@ func (c *Client) TagImage(reader io.Reader, taggerModel string, threshold float6
 }
 
 func (c *Client) GetInfo() (*ServiceInfo, error) {
+	return c.GetInfoWithContext(context.Background())
+}
+
+// GetInfoWithContext 与 GetInfo 相同，ctx 取消时中断请求
+func (c *Client) GetInfoWithContext(ctx context.Context) (*ServiceInfo, error) {
 	// 获取服务地址
 	serviceUrl, err := c.getServiceUrl()
 	if err != nil {
@@ -166,6 +172,7 @@ func (c *Client) GetInfo() (*ServiceInfo, error) {
 
 	result := &ServiceInfo{}
 	_, err = c.client.R().
+		SetContext(ctx).
 		SetResult(result).
 		Get(fmt.Sprintf("%s/info", serviceUrl))
 	if err != nil {
@@ -1,12 +1,16 @@
 package tagger
 
 import (
+	"context"
 	"fmt"
 	"io"
 
 	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/config"
 )
 
+const PluginName = "imagetagger"
+
 // Config 是插件的配置结构体
 type Config struct {
 	Enable bool
@@ -46,6 +50,11 @@ type ImageTagger interface {
 	GetTaggerState() (*TaggerState, error)
 }
 
+// imageTaggerWithContext 可选接口：健康检查时随 ctx 取消请求
+type imageTaggerWithContext interface {
+	GetInfoWithContext(ctx context.Context) (*ServiceInfo, error)
+}
+
 // ImageTaggerPlugin 是图片标签插件
 type ImageTaggerPlugin struct {
 	client ImageTagger
@@ -153,7 +162,7 @@ func (i *ImageTaggerPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 	logger := e.LoggerPlugin.Logger.NewScope("ImageTaggerPlugin")
 
 	config := &Config{
-		Enable: e.ConfigProvider.Manager.GetBool("imagetagger.enable"),
+		Enable: e.ConfigProvider.Config().GetBool("imagetagger.enable"),
 	}
 
 	if !config.Enable {
@@ -163,7 +172,7 @@ func (i *ImageTaggerPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 
 	logger.Info("Init ImageTaggerPlugin")
 
-	config.URL = e.ConfigProvider.Manager.GetString("imagetagger.url")
+	config.URL = e.ConfigProvider.Config().GetString("imagetagger.url")
 	logger.WithFields(map[string]interface{}{
 		"enable": config.Enable,
 		"url":    config.URL,
@@ -184,6 +193,22 @@ func (i *ImageTaggerPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 	return nil
 }
 
+func (i *ImageTaggerPlugin) PluginName() string {
+	return PluginName
+}
+
+func (i *ImageTaggerPlugin) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "imagetagger",
+		Description: "image tagger service",
+		EnableKey:   "enable",
+		Fields: []config.Field{
+			{Key: "enable", Type: config.FieldTypeBool, Description: "enable image tagger"},
+			{Key: "url", Type: config.FieldTypeString, Required: true, Description: "service url"},
+		},
+	}
+}
+
 func (i *ImageTaggerPlugin) GetPluginConfig() map[string]interface{} {
 	if i.config == nil {
 		return map[string]interface{}{"enable": i.enable}
@@ -195,3 +220,27 @@ func (i *ImageTaggerPlugin) GetPluginConfig() map[string]interface{} {
 	cfg["url"] = i.config.URL
 	return cfg
 }
+
+// HealthCheck 检查标签服务是否可用
+func (i *ImageTaggerPlugin) HealthCheck(ctx context.Context) error {
+	if !i.enable {
+		return harukap.ErrHealthCheckDisabled
+	}
+	client, err := i.GetClient()
+	if err != nil {
+		return err
+	}
+	var info *ServiceInfo
+	if withContext, ok := client.(imageTaggerWithContext); ok {
+		info, err = withContext.GetInfoWithContext(ctx)
+	} else {
+		info, err = client.GetInfo()
+	}
+	if err != nil {
+		return err
+	}
+	if !info.Success {
+		return ErrConnectionFailed
+	}
+	return nil
+}
@@ -2,11 +2,16 @@ package thumbnail
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/config"
 	"io"
+	"sync"
 )
 
+const PluginName = "thumbnail"
+
 type ThumbnailOption struct {
 	MaxWidth  int    `hsource:"query" hname:"maxWidth"`
 	MaxHeight int    `hsource:"query" hname:"maxHeight"`
@@ -15,6 +20,10 @@ type ThumbnailOption struct {
 type Engine struct {
 	Process   map[string]ThumbnailProcess
 	UseEngine string
+	engine    *harukap.HarukaAppEngine
+	// configured 为由配置文件创建的处理器名称，重新加载配置时只替换这部分
+	configured []string
+	mutex      sync.RWMutex
 }
 
 func NewEngine() *Engine {
@@ -24,7 +33,9 @@ func NewEngine() *Engine {
 }
 
 func (t *Engine) Resize(ctx context.Context, input io.ReadCloser, option ThumbnailOption) (io.ReadCloser, error) {
+	t.mutex.RLock()
 	defaultEngine := t.Process[t.UseEngine]
+	t.mutex.RUnlock()
 	if defaultEngine == nil {
 		return nil, fmt.Errorf("no default engine")
 	}
@@ -32,7 +43,29 @@ func (t *Engine) Resize(ctx context.Context, input io.ReadCloser, option Thumbna
 }
 
 func (t *Engine) OnInit(e *harukap.HarukaAppEngine) error {
-	configManager := e.ConfigProvider.Manager
+	t.engine = e
+	process, configured, err := t.loadProcess(e)
+	if err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.Process == nil {
+		t.Process = map[string]ThumbnailProcess{}
+	}
+	for name, p := range process {
+		t.Process[name] = p
+	}
+	t.configured = configured
+	t.UseEngine = e.ConfigProvider.Config().GetString("thumbnails.default")
+	return nil
+}
+
+// loadProcess 按 thumbnails 配置创建处理器，返回处理器与其配置名
+func (t *Engine) loadProcess(e *harukap.HarukaAppEngine) (map[string]ThumbnailProcess, []string, error) {
+	process := map[string]ThumbnailProcess{}
+	configured := make([]string, 0)
+	configManager := e.ConfigProvider.Config()
 	rawThumbnails := configManager.GetStringMap("thumbnails")
 	for name := range rawThumbnails {
 		storageType := configManager.GetString(fmt.Sprintf("thumbnails.%s.type", name))
@@ -46,20 +79,122 @@ func (t *Engine) OnInit(e *harukap.HarukaAppEngine) error {
 			}
 			err := thumbnailServicePlugin.OnInit(e)
 			if err != nil {
-				return err
+				return nil, nil, err
 			}
-			t.Process[name] = thumbnailServicePlugin
+			process[name] = thumbnailServicePlugin
 		case "local":
 			local := &LocalThumbnailProcess{}
-			t.Process[name] = local
+			process[name] = local
 		case "vips":
 			target := configManager.GetString(fmt.Sprintf("thumbnails.%s.target", name))
 			vips := &VipsThumbnailEngine{
 				Target: target,
 			}
-			t.Process[name] = vips
+			process[name] = vips
+		default:
+			continue
+		}
+		configured = append(configured, name)
+	}
+	return process, configured, nil
+}
+
+// ConfigPrefixes 订阅 thumbnails 下的配置变化
+func (t *Engine) ConfigPrefixes() []string {
+	return []string{"thumbnails"}
+}
+
+// OnConfigChange 按新配置重建处理器，通过代码添加的处理器保持不变
+func (t *Engine) OnConfigChange(change *config.ConfigChange) error {
+	process, configured, err := t.loadProcess(t.engine)
+	if err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, name := range t.configured {
+		delete(t.Process, name)
+	}
+	for name, p := range process {
+		t.Process[name] = p
+	}
+	t.configured = configured
+	t.UseEngine = t.engine.ConfigProvider.Config().GetString("thumbnails.default")
+	return nil
+}
+
+func (t *Engine) PluginName() string {
+	return PluginName
+}
+
+func (t *Engine) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "thumbnails",
+		Description: "thumbnail processes",
+		Fields: []config.Field{
+			{Key: "default", Type: config.FieldTypeString, Description: "default thumbnail process name"},
+			{Key: "*.type", Type: config.FieldTypeString, Required: true, Enum: []string{"thumbnailservice", "local", "vips"}, Description: "process type"},
+			{Key: "*.target", Type: config.FieldTypeString, Description: "vips executable"},
+			{Key: "*.url", Type: config.FieldTypeString, Description: "thumbnail service url"},
+			{Key: "*.enable", Type: config.FieldTypeBool, Description: "enable thumbnail service"},
+		},
+	}
+}
+
+// Probe 逐个检查支持健康检查的缩略图处理器
+func (t *Engine) Probe(ctx context.Context) []*harukap.ProbeResult {
+	t.mutex.RLock()
+	processList := make(map[string]ThumbnailProcess, len(t.Process))
+	for name, process := range t.Process {
+		processList[name] = process
+	}
+	t.mutex.RUnlock()
+	results := make([]*harukap.ProbeResult, 0, len(processList))
+	for name, process := range processList {
+		checker, ok := process.(interface {
+			HealthCheck(ctx context.Context) error
+		})
+		if !ok {
+			continue
+		}
+		target := ""
+		switch p := process.(type) {
+		case *ThumbnailServicePlugin:
+			if p.config != nil {
+				target = p.config.ServiceUrl
+			}
+		case *VipsThumbnailEngine:
+			target = p.Target
+		}
+		results = append(results, harukap.Probe(ctx, fmt.Sprintf("thumbnails.%s", name), target, func(ctx context.Context) (string, error) {
+			return "", checker.HealthCheck(ctx)
+		}))
+	}
+	return results
+}
+
+// HealthCheck 检查所有支持健康检查的缩略图处理器
+func (t *Engine) HealthCheck(ctx context.Context) error {
+	t.mutex.RLock()
+	processList := make(map[string]ThumbnailProcess, len(t.Process))
+	for name, process := range t.Process {
+		processList[name] = process
+	}
+	t.mutex.RUnlock()
+	if len(processList) == 0 {
+		return harukap.ErrHealthCheckDisabled
+	}
+	for name, process := range processList {
+		checker, ok := process.(interface {
+			HealthCheck(ctx context.Context) error
+		})
+		if !ok {
+			continue
+		}
+		err := checker.HealthCheck(ctx)
+		if err != nil && !errors.Is(err, harukap.ErrHealthCheckDisabled) {
+			return fmt.Errorf("thumbnail %s: %w", name, err)
 		}
 	}
-	t.UseEngine = configManager.GetString("thumbnails.default")
 	return nil
 }
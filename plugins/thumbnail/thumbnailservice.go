@@ -45,8 +45,8 @@ func (p *ThumbnailServicePlugin) OnInit(e *harukap.HarukaAppEngine) error {
 			prefix += p.Prefix
 		}
 		p.config = &ThumbnailServiceConfig{
-			ServiceUrl: e.ConfigProvider.Manager.GetString(fmt.Sprintf("%s.url", prefix)),
-			Enable:     e.ConfigProvider.Manager.GetBool(fmt.Sprintf("%s.enable", prefix)),
+			ServiceUrl: e.ConfigProvider.Config().GetString(fmt.Sprintf("%s.url", prefix)),
+			Enable:     e.ConfigProvider.Config().GetBool(fmt.Sprintf("%s.enable", prefix)),
 		}
 	}
 	logger.WithFields(map[string]interface{}{
@@ -67,6 +67,14 @@ func (p *ThumbnailServicePlugin) OnInit(e *harukap.HarukaAppEngine) error {
 	return nil
 }
 
+// HealthCheck 检查缩略图服务是否可用
+func (p *ThumbnailServicePlugin) HealthCheck(ctx context.Context) error {
+	if p.config == nil || !p.config.Enable || p.Client == nil {
+		return harukap.ErrHealthCheckDisabled
+	}
+	return p.Client.Check()
+}
+
 func (p *ThumbnailServicePlugin) GetPluginConfig() map[string]interface{} {
 	if p.config == nil {
 		return nil
@@ -48,3 +48,9 @@ func (e *VipsThumbnailEngine) Resize(ctx context.Context, input io.ReadCloser, o
 func NewVipsThumbnailEngine(target string) *VipsThumbnailEngine {
 	return &VipsThumbnailEngine{Target: target}
 }
+
+// HealthCheck 检查 vips 可执行文件是否存在
+func (e *VipsThumbnailEngine) HealthCheck(ctx context.Context) error {
+	_, err := exec.LookPath(e.Target)
+	return err
+}
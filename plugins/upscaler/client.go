@@ -2,6 +2,7 @@ package upscaler
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	util "github.com/allentom/harukap/utils"
@@ -83,8 +84,14 @@ func (c *Client) Upscale(reader io.Reader, option *UpscaleOptions) ([]byte, erro
 }
 
 func (c *Client) GetInfo() (*ServiceInfo, error) {
+	return c.GetInfoWithContext(context.Background())
+}
+
+// GetInfoWithContext 与 GetInfo 相同，ctx 取消时中断请求
+func (c *Client) GetInfoWithContext(ctx context.Context) (*ServiceInfo, error) {
 	result := &ServiceInfo{}
 	_, err := c.client.R().
+		SetContext(ctx).
 		SetResult(result).
 		Get(c.BaseUrl + "/info")
 	if err != nil {
@@ -1,9 +1,14 @@
 package upscaler
 
 import (
+	"context"
+	"fmt"
 	"github.com/allentom/harukap"
+	"github.com/allentom/harukap/config"
 )
 
+const PluginName = "imageupscaler"
+
 type ImageUpscalerPlugin struct {
 	Client *Client
 	Enable bool
@@ -12,7 +17,7 @@ type ImageUpscalerPlugin struct {
 func (i *ImageUpscalerPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 	logger := e.LoggerPlugin.Logger.NewScope("ImageUpscalerPlugin")
 
-	isEnable := e.ConfigProvider.Manager.GetBool("imageupscaler.enable")
+	isEnable := e.ConfigProvider.Config().GetBool("imageupscaler.enable")
 	i.Enable = isEnable
 	if !isEnable {
 		logger.Info("ImageUpscalerPlugin is disabled")
@@ -20,7 +25,7 @@ func (i *ImageUpscalerPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 	}
 
 	logger.Info("Init ImageUpscalerPlugin")
-	baseUrl := e.ConfigProvider.Manager.GetString("imageupscaler.url")
+	baseUrl := e.ConfigProvider.Config().GetString("imageupscaler.url")
 	logger.WithFields(map[string]interface{}{
 		"enable":  isEnable,
 		"baseUrl": baseUrl,
@@ -42,6 +47,22 @@ func (i *ImageUpscalerPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 	return nil
 }
 
+func (i *ImageUpscalerPlugin) PluginName() string {
+	return PluginName
+}
+
+func (i *ImageUpscalerPlugin) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "imageupscaler",
+		Description: "image upscaler service",
+		EnableKey:   "enable",
+		Fields: []config.Field{
+			{Key: "enable", Type: config.FieldTypeBool, Description: "enable image upscaler"},
+			{Key: "url", Type: config.FieldTypeString, Required: true, Description: "service url"},
+		},
+	}
+}
+
 func (i *ImageUpscalerPlugin) IsEnable() bool {
 	return i.Enable && i.Client != nil
 }
@@ -60,3 +81,21 @@ func (i *ImageUpscalerPlugin) GetPluginConfig() map[string]interface{} {
 		"baseUrl": base,
 	}
 }
+
+// HealthCheck 检查超分服务是否可用，初始化时连接失败的插件会报告为不可用
+func (i *ImageUpscalerPlugin) HealthCheck(ctx context.Context) error {
+	if !i.Enable {
+		return harukap.ErrHealthCheckDisabled
+	}
+	if i.Client == nil {
+		return fmt.Errorf("imageupscaler client is not connected")
+	}
+	info, err := i.Client.GetInfoWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !info.Success {
+		return fmt.Errorf("connection failed")
+	}
+	return nil
+}
@@ -8,11 +8,14 @@ import (
 	"github.com/allentom/haruka"
 	"github.com/allentom/harukap"
 	"github.com/allentom/harukap/commons"
+	"github.com/allentom/harukap/config"
 	"github.com/allentom/harukap/plugins/nacos"
 	util "github.com/allentom/harukap/utils"
 	"github.com/project-xpolaris/youplustoolkit/youlink"
 )
 
+const PluginName = "youauth"
+
 type OauthPlugin struct {
 	Client          *YouAuthClient
 	ConfigPrefix    string
@@ -32,7 +35,7 @@ func (p *OauthPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 		p.ConfigPrefix = "auth"
 	}
 	// 自动定位到 auth.<providerKey>（如 auth.youauth），便于将 nacos 配置放在 youauth 节点下
-	configer := e.ConfigProvider.Manager
+	configer := e.ConfigProvider.Config()
 	if p.ConfigPrefix == "auth" {
 		for key := range configer.GetStringMap("auth") {
 			if configer.GetString(fmt.Sprintf("auth.%s.type", key)) == "youauth" {
@@ -57,13 +60,10 @@ func (p *OauthPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 		if scheme == "" {
 			scheme = "http"
 		}
-		for _, pl := range e.Plugins {
-			if np, ok := pl.(*nacos.NacosPlugin); ok && np != nil {
-				inst, err := np.GetServiceInstance(serviceName, group)
-				if err == nil && inst != nil && inst.Ip != "" && inst.Port > 0 {
-					p.Client.BaseUrl = fmt.Sprintf("%s://%s:%d", scheme, inst.Ip, inst.Port)
-					break
-				}
+		if np, ok := harukap.GetPlugin[*nacos.NacosPlugin](e); ok && np != nil {
+			inst, err := np.GetServiceInstance(serviceName, group)
+			if err == nil && inst != nil && inst.Ip != "" && inst.Port > 0 {
+				p.Client.BaseUrl = fmt.Sprintf("%s://%s:%d", scheme, inst.Ip, inst.Port)
 			}
 		}
 	}
@@ -107,6 +107,38 @@ func (p *OauthPlugin) OnInit(e *harukap.HarukaAppEngine) error {
 	p.Client.Init()
 	return nil
 }
+
+func (p *OauthPlugin) PluginName() string {
+	return PluginName
+}
+
+// ConfigSchema auth 下的配置由多种认证方式共享，不检查未声明的键
+func (p *OauthPlugin) ConfigSchema() *config.Schema {
+	prefix := "auth.*"
+	if p.ConfigPrefix != "" && p.ConfigPrefix != "auth" {
+		prefix = p.ConfigPrefix
+	}
+	return &config.Schema{
+		Description: "youauth",
+		When:        map[string]string{"type": "youauth"},
+		Fields: []config.Field{
+			{Key: prefix + ".type", Type: config.FieldTypeString, Description: "auth type, youauth"},
+			{Key: prefix + ".enable", Type: config.FieldTypeBool, Description: "enable this auth"},
+			{Key: prefix + ".url", Type: config.FieldTypeString, Description: "youauth url, used when nacos is disabled"},
+			{Key: prefix + ".appid", Type: config.FieldTypeString, Description: "youauth app id"},
+			{Key: prefix + ".secret", Type: config.FieldTypeString, Description: "youauth app secret", Secret: true},
+			{Key: prefix + ".nacos.enable", Type: config.FieldTypeBool, Description: "discover youauth through nacos"},
+			{Key: prefix + ".nacos.serviceName", Type: config.FieldTypeString, Default: "youauth", Description: "youauth service name"},
+			{Key: prefix + ".nacos.group", Type: config.FieldTypeString, Default: "DEFAULT_GROUP", Description: "youauth service group"},
+			{Key: prefix + ".nacos.scheme", Type: config.FieldTypeString, Default: "http", Description: "youauth url scheme"},
+		},
+	}
+}
+
+// PluginOptionalDependencies 注册了 Nacos 插件时需先完成其初始化，以便通过服务发现定位 YouAuth
+func (p *OauthPlugin) PluginOptionalDependencies() []string {
+	return []string{nacos.PluginName}
+}
 func (p *OauthPlugin) GetOauthPlugin() *OauthAuthPlugin {
 	return &OauthAuthPlugin{
 		OauthPlugin: p,
@@ -204,6 +236,11 @@ func (p *PasswordAuthPlugin) TokenTypeName() string {
 	return "youauth"
 }
 
+// IssuerUnverified 实现 auth.AuthPluginWithUnverifiedIssuer，YouAuth 签发的 token 由 AuthFromToken 向 YouAuth 校验
+func (p *OauthPlugin) IssuerUnverified() bool {
+	return true
+}
+
 func (p *OauthPlugin) GetPluginConfig() map[string]interface{} {
 	cfg := map[string]interface{}{}
 	if p.Client != nil {
@@ -7,14 +7,51 @@ import (
 	"github.com/mitchellh/mapstructure"
 	"github.com/project-xpolaris/youplustoolkit/youlog"
 	"github.com/rs/xid"
+	"github.com/sirupsen/logrus"
 	"time"
 )
 
 type YouLogPluginConfig struct {
 	Instance    string `json:"instance"`
 	Application string `json:"application"`
-	Engines     []interface{}
+	// Level 最低输出级别：debug、info、warn、error，为空时输出全部
+	Level   string `json:"level"`
+	Engines []interface{}
 }
+
+var levelMapping = map[string]int64{
+	"debug": youlog.LEVEL_DEBUG,
+	"info":  youlog.LEVEL_INFO,
+	"warn":  youlog.LEVEL_WARN,
+	"error": youlog.LEVEL_ERROR,
+}
+
+// levelFilterEngine 丢弃低于最低级别的日志
+type levelFilterEngine struct {
+	youlog.LogEngine
+	level int64
+}
+
+func (e *levelFilterEngine) WriteLog(ctx context.Context, scope *youlog.Scope, message string, level int64) error {
+	if level < e.level {
+		return nil
+	}
+	return e.LogEngine.WriteLog(ctx, scope, message, level)
+}
+
+// ConfigSchema 日志配置声明
+var ConfigSchema = &config.Schema{
+	Prefix:      "log.youlog",
+	Description: "youlog logger",
+	Fields: []config.Field{
+		{Key: "application", Type: config.FieldTypeString, Description: "application name"},
+		{Key: "instance", Type: config.FieldTypeString, Description: "instance name, generated when empty"},
+		{Key: "level", Type: config.FieldTypeString, Enum: []string{"debug", "info", "warn", "error"}, Description: "minimum log level"},
+		{Key: "engine", Type: config.FieldTypeMap, Description: "log engines"},
+		{Key: "engine.*.type", Type: config.FieldTypeString, Required: true, Enum: []string{"logrus", "youlogservice", "fluentd"}, Description: "log engine type"},
+	},
+}
+
 type Plugin struct {
 	Logger *youlog.LogClient
 	Config *YouLogPluginConfig
@@ -24,8 +61,9 @@ func (p *Plugin) OnInit(configProvider *config.Provider) error {
 	p.Logger = &youlog.LogClient{}
 	if p.Config == nil {
 		p.Config = &YouLogPluginConfig{
-			Application: configProvider.Manager.GetString("log.youlog.application"),
-			Instance:    configProvider.Manager.GetString("log.youlog.instance"),
+			Application: configProvider.Config().GetString("log.youlog.application"),
+			Instance:    configProvider.Config().GetString("log.youlog.instance"),
+			Level:       configProvider.Config().GetString("log.youlog.level"),
 		}
 	}
 	if len(p.Config.Instance) == 0 {
@@ -34,7 +72,7 @@ func (p *Plugin) OnInit(configProvider *config.Provider) error {
 	p.Logger.Init(p.Config.Application, p.Config.Instance)
 	// add engine
 	if p.Config.Engines == nil {
-		rawEngineConfig := configProvider.Manager.GetStringMap("log.youlog.engine")
+		rawEngineConfig := configProvider.Config().GetStringMap("log.youlog.engine")
 		for _, engine := range rawEngineConfig {
 			engineConfig := engine.(map[string]interface{})
 			switch engineConfig["type"].(string) {
@@ -79,10 +117,31 @@ func (p *Plugin) OnInit(configProvider *config.Provider) error {
 		}
 	}
 
-	timeout, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	if p.Config.Level != "" {
+		level, ok := levelMapping[p.Config.Level]
+		if !ok {
+			return fmt.Errorf("unknown log level: %s", p.Config.Level)
+		}
+		for i, engine := range p.Logger.Engines {
+			p.Logger.Engines[i] = &levelFilterEngine{LogEngine: engine, level: level}
+		}
+	}
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	err := p.Logger.InitEngines(timeout)
 	if err != nil {
 		return err
 	}
+	// logrus 默认只输出 info 及以上级别
+	if p.Config.Level == "debug" {
+		for _, engine := range p.Logger.Engines {
+			if filter, ok := engine.(*levelFilterEngine); ok {
+				engine = filter.LogEngine
+			}
+			if logrusEngine, ok := engine.(*youlog.LogrusEngine); ok {
+				logrusEngine.Logger.SetLevel(logrus.DebugLevel)
+			}
+		}
+	}
 	return nil
 }
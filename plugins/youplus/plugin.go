@@ -6,12 +6,15 @@ import (
 
 	"github.com/allentom/harukap"
 	"github.com/allentom/harukap/commons"
+	"github.com/allentom/harukap/config"
 	util "github.com/allentom/harukap/utils"
 	"github.com/project-xpolaris/youplustoolkit/youplus"
 	entry "github.com/project-xpolaris/youplustoolkit/youplus/entity"
 	youplustoolkitrpc "github.com/project-xpolaris/youplustoolkit/youplus/rpc"
 )
 
+const PluginName = "youplus"
+
 type Plugin struct {
 	RPCClient     *youplustoolkitrpc.YouPlusRPCClient
 	Client        *youplus.Client
@@ -24,9 +27,9 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 	// rpc
 	logger := e.LoggerPlugin.Logger.NewScope("youplusplugin")
 	logger.Info("init plugin")
-	enableRPC := e.ConfigProvider.Manager.GetBool("youplus.enablerpc")
+	enableRPC := e.ConfigProvider.Config().GetBool("youplus.enablerpc")
 	if enableRPC {
-		rpcAddr := e.ConfigProvider.Manager.GetString("youplus.rpc")
+		rpcAddr := e.ConfigProvider.Config().GetString("youplus.rpc")
 		logger.WithFields(map[string]interface{}{
 			"rpc.enable": enableRPC,
 			"rpc.addr":   rpcAddr,
@@ -37,10 +40,11 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 			return err
 		}
 		// entity
-		enableEntity := e.ConfigProvider.Manager.GetBool("youplus.entity.enable")
-		if enableEntity {
-			name := e.ConfigProvider.Manager.GetString("youplus.entity.name")
-			version := e.ConfigProvider.Manager.GetInt64("youplus.entity.version")
+		// 试运行时不注册 entity
+		enableEntity := e.ConfigProvider.Config().GetBool("youplus.entity.enable")
+		if enableEntity && !e.IsDryRun() {
+			name := e.ConfigProvider.Config().GetString("youplus.entity.name")
+			version := e.ConfigProvider.Config().GetInt64("youplus.entity.version")
 			logger.WithFields(map[string]interface{}{
 				"entity.enable":  enableEntity,
 				"entity.name":    name,
@@ -54,7 +58,7 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 			addrs, err := util.GetHostIpList()
 			urls := make([]string, 0)
 			for _, addr := range addrs {
-				urls = append(urls, fmt.Sprintf("http://%s%s", addr, e.ConfigProvider.Manager.GetString("addr")))
+				urls = append(urls, fmt.Sprintf("http://%s%s", addr, e.ConfigProvider.Config().GetString("addr")))
 			}
 			if err != nil {
 				logger.Fatal(err.Error())
@@ -77,13 +81,42 @@ func (p *Plugin) OnInit(e *harukap.HarukaAppEngine) error {
 	}
 	// http
 	p.Client = youplus.NewClient()
-	httpUrl := e.ConfigProvider.Manager.GetString("youplus.url")
+	httpUrl := e.ConfigProvider.Config().GetString("youplus.url")
 	logger.WithFields(map[string]interface{}{
 		"http.url": httpUrl,
 	}).Info("youplus http config")
 	p.Client.Init(httpUrl)
 	return nil
 }
+
+func (p *Plugin) PluginName() string {
+	return PluginName
+}
+
+func (p *Plugin) ConfigSchema() *config.Schema {
+	return &config.Schema{
+		Prefix:      "youplus",
+		Description: "youplus",
+		Fields: []config.Field{
+			{Key: "url", Type: config.FieldTypeString, Description: "youplus http url"},
+			{Key: "enablerpc", Type: config.FieldTypeBool, Description: "connect youplus rpc"},
+			{Key: "rpc", Type: config.FieldTypeString, Description: "youplus rpc address"},
+			{Key: "entity.enable", Type: config.FieldTypeBool, Description: "register as youplus entity"},
+			{Key: "entity.name", Type: config.FieldTypeString, Description: "entity name"},
+			{Key: "entity.version", Type: config.FieldTypeInt, Description: "entity version"},
+		},
+		Validate: func(provider *config.Provider) config.ValidationErrors {
+			errs := make(config.ValidationErrors, 0)
+			if provider.Config().GetBool("youplus.enablerpc") {
+				errs = append(errs, provider.RequireKeys("youplus.rpc")...)
+				if provider.Config().GetBool("youplus.entity.enable") {
+					errs = append(errs, provider.RequireKeys("youplus.entity.name")...)
+				}
+			}
+			return errs
+		},
+	}
+}
 func (p *Plugin) GetAuthInfo() (*commons.AuthInfo, error) {
 	authInfo := &commons.AuthInfo{
 		Type: commons.AuthTypeBase,
@@ -100,6 +133,12 @@ func (p *Plugin) AuthName() string {
 func (p *Plugin) TokenTypeName() string {
 	return "YouPlusService"
 }
+
+// IssuerUnverified 实现 auth.AuthPluginWithUnverifiedIssuer，YouPlus 签发的 token 由 AuthFromToken 向 YouPlus 校验
+func (p *Plugin) IssuerUnverified() bool {
+	return true
+}
+
 func (p *Plugin) GetAuthUserByToken(token string) (commons.AuthUser, error) {
 	if p.AuthFromToken == nil {
 		return nil, nil
@@ -120,3 +159,29 @@ func (p *Plugin) GetPluginConfig() map[string]interface{} {
 	}
 	return cfg
 }
+
+// HealthCheck 检查 YouPlus HTTP 服务是否可用
+func (p *Plugin) HealthCheck(ctx context.Context) error {
+	if p.Client == nil {
+		return harukap.ErrHealthCheckDisabled
+	}
+	info, err := p.Client.FetchInfo()
+	if err != nil {
+		return err
+	}
+	if !info.Success {
+		return fmt.Errorf("youplus info response success is false")
+	}
+	return nil
+}
+
+// OnShutdown 停止心跳并注销 entity
+func (p *Plugin) OnShutdown() error {
+	if p.Entity == nil {
+		return nil
+	}
+	if p.Entity.StopHeartbeatContextFunc != nil {
+		p.Entity.StopHeartbeatContextFunc()
+	}
+	return p.Entity.Unregister()
+}
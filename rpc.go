@@ -0,0 +1,133 @@
+package harukap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/allentom/harukap/rpc"
+	youlog2 "github.com/project-xpolaris/youplustoolkit/youlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// rpcAuthenticator RPCService 未设置 Authenticator 时使用引擎的 AuthModule
+func (e *HarukaAppEngine) rpcAuthenticator() rpc.TokenAuthenticator {
+	if e.RPCService.Authenticator != nil {
+		return e.RPCService.Authenticator
+	}
+	return e.AuthModule
+}
+
+// rpcServerOptions 默认拦截器依次为 panic 恢复、日志、认证，之后是 RPCService 中注册的拦截器
+func (e *HarukaAppEngine) rpcServerOptions(logger *youlog2.Scope) ([]grpc.ServerOption, error) {
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		rpc.RecoveryUnaryInterceptor(logger),
+		rpc.LoggingUnaryInterceptor(logger),
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		rpc.RecoveryStreamInterceptor(logger),
+		rpc.LoggingStreamInterceptor(logger),
+	}
+	if authenticator := e.rpcAuthenticator(); authenticator != nil {
+		unaryInterceptors = append(unaryInterceptors, rpc.AuthUnaryInterceptor(authenticator, e.RPCService.PublicMethods))
+		streamInterceptors = append(streamInterceptors, rpc.AuthStreamInterceptor(authenticator, e.RPCService.PublicMethods))
+	}
+	unaryInterceptors = append(unaryInterceptors, e.RPCService.UnaryInterceptors...)
+	streamInterceptors = append(streamInterceptors, e.RPCService.StreamInterceptors...)
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+	configer := e.ConfigProvider.Config()
+	if configer.GetBool("rpc.tls.enable") {
+		tlsConfig := &rpc.TLSConfig{
+			Cert: configer.GetString("rpc.tls.cert"),
+			Key:  configer.GetString("rpc.tls.key"),
+			CA:   configer.GetString("rpc.tls.ca"),
+		}
+		creds, err := tlsConfig.ServerCredentials()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.Creds(creds))
+		logger.WithFields(youlog2.Fields{
+			"mtls": tlsConfig.CA != "",
+		}).Info("rpc tls enabled")
+	}
+	return append(options, e.RPCService.ServerOptions...), nil
+}
+
+// RunRPC 启动 gRPC 服务并阻塞直到服务停止，同时注册标准健康检查服务与反射服务（rpc.reflection 为 false 时关闭）
+func (e *HarukaAppEngine) RunRPC() error {
+	logger := e.LoggerPlugin.Logger.NewScope("rpc")
+	rpcServer, lis, err := e.listenRPC(logger)
+	if err != nil {
+		return err
+	}
+	return e.serveRPC(logger, rpcServer, lis)
+}
+
+// listenRPC 创建 gRPC 服务并监听 rpc.addr，返回前将服务登记到引擎，之后的 Shutdown 一定能停止它
+func (e *HarukaAppEngine) listenRPC(logger *youlog2.Scope) (*grpc.Server, net.Listener, error) {
+	addr := e.ConfigProvider.Config().GetString("rpc.addr")
+	options, err := e.rpcServerOptions(logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen: %v", err)
+	}
+	rpcServer := grpc.NewServer(options...)
+	e.RPCService.OnRegister(rpcServer)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(rpcServer, healthServer)
+	configer := e.ConfigProvider.Config()
+	if !configer.IsSet("rpc.reflection") || configer.GetBool("rpc.reflection") {
+		reflection.Register(rpcServer)
+	}
+	e.lifecycleLock.Lock()
+	e.rpcServer = rpcServer
+	e.rpcHealth = healthServer
+	e.lifecycleLock.Unlock()
+	return rpcServer, lis, nil
+}
+
+// serveRPC 阻塞直到服务停止。登记前已经开始关闭时 Shutdown 看不到该服务，此时直接返回
+func (e *HarukaAppEngine) serveRPC(logger *youlog2.Scope, rpcServer *grpc.Server, lis net.Listener) error {
+	select {
+	case <-e.getStopSignal():
+		rpcServer.Stop()
+		lis.Close()
+		return nil
+	default:
+	}
+	logger.WithFields(youlog2.Fields{
+		"addr": lis.Addr().String(),
+	}).Info("rpc service listening")
+	err := rpcServer.Serve(lis)
+	if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("failed to serve: %v", err)
+	}
+	return nil
+}
+
+// syncRPCHealth 将引擎的健康状态同步到 gRPC 健康检查服务
+func (e *HarukaAppEngine) syncRPCHealth() {
+	e.OnHealthChange(func(report *HealthReport) {
+		e.lifecycleLock.Lock()
+		healthServer := e.rpcHealth
+		e.lifecycleLock.Unlock()
+		if healthServer == nil {
+			return
+		}
+		status := healthpb.HealthCheckResponse_SERVING
+		if !report.Healthy() {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		healthServer.SetServingStatus("", status)
+	})
+}
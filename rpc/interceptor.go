@@ -0,0 +1,186 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/allentom/harukap/commons"
+	"github.com/project-xpolaris/youplustoolkit/youlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TokenAuthenticator 解析请求携带的 token，auth.AuthModule 实现了该接口
+type TokenAuthenticator interface {
+	ParseToken(token string) (commons.AuthUser, error)
+}
+
+// AnonymousAuthenticator 可选接口：允许匿名访问时未携带 token 的请求也会放行
+type AnonymousAuthenticator interface {
+	AnonymousEnabled() bool
+}
+
+// builtinPublicPrefixes 健康检查与反射服务不需要认证
+var builtinPublicPrefixes = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.",
+}
+
+type authUserKey struct{}
+
+// ContextWithUser 将认证用户写入 context
+func ContextWithUser(ctx context.Context, user commons.AuthUser) context.Context {
+	return context.WithValue(ctx, authUserKey{}, user)
+}
+
+// UserFromContext 读取认证拦截器写入的用户，匿名请求返回 nil
+func UserFromContext(ctx context.Context) commons.AuthUser {
+	return ctx.Value(authUserKey{})
+}
+
+// RecoveryUnaryInterceptor 捕获处理函数中的 panic 并返回 Internal 错误
+func RecoveryUnaryInterceptor(logger *youlog.Scope) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.WithFields(youlog.Fields{
+					"method": info.FullMethod,
+					"stack":  string(debug.Stack()),
+				}).Error(fmt.Sprintf("rpc panic: %v", r))
+				err = status.Errorf(codes.Internal, "internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor 捕获流处理函数中的 panic 并返回 Internal 错误
+func RecoveryStreamInterceptor(logger *youlog.Scope) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.WithFields(youlog.Fields{
+					"method": info.FullMethod,
+					"stack":  string(debug.Stack()),
+				}).Error(fmt.Sprintf("rpc panic: %v", r))
+				err = status.Errorf(codes.Internal, "internal error")
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func logCall(logger *youlog.Scope, method string, start time.Time, err error) {
+	code := status.Code(err)
+	scope := logger.WithFields(youlog.Fields{
+		"method":   method,
+		"code":     code.String(),
+		"duration": time.Since(start).Milliseconds(),
+	})
+	if err != nil && code != codes.Canceled {
+		scope.Error(err.Error())
+		return
+	}
+	scope.Info("rpc call")
+}
+
+// LoggingUnaryInterceptor 记录每次调用的方法、状态码与耗时
+func LoggingUnaryInterceptor(logger *youlog.Scope) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(logger, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// LoggingStreamInterceptor 记录每个流的方法、状态码与耗时
+func LoggingStreamInterceptor(logger *youlog.Scope) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(logger, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func isPublicMethod(method string, publicMethods []string) bool {
+	for _, prefix := range builtinPublicPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	for _, publicMethod := range publicMethods {
+		if publicMethod == method {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenFromMetadata 从 authorization 元数据中读取 token
+func tokenFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(values[0], "Bearer ")
+}
+
+func authenticate(ctx context.Context, authenticator TokenAuthenticator, method string, publicMethods []string) (context.Context, error) {
+	if isPublicMethod(method, publicMethods) {
+		return ctx, nil
+	}
+	token := tokenFromMetadata(ctx)
+	if token == "" {
+		if anonymous, ok := authenticator.(AnonymousAuthenticator); ok && anonymous.AnonymousEnabled() {
+			return ctx, nil
+		}
+		return nil, status.Error(codes.Unauthenticated, "missing token")
+	}
+	user, err := authenticator.ParseToken(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+	}
+	return ContextWithUser(ctx, user), nil
+}
+
+// AuthUnaryInterceptor 校验 authorization 元数据中的 token，并将用户写入 context
+func AuthUnaryInterceptor(authenticator TokenAuthenticator, publicMethods []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		authCtx, err := authenticate(ctx, authenticator, info.FullMethod, publicMethods)
+		if err != nil {
+			return nil, err
+		}
+		return handler(authCtx, req)
+	}
+}
+
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// AuthStreamInterceptor 流式调用的认证拦截器
+func AuthStreamInterceptor(authenticator TokenAuthenticator, publicMethods []string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		authCtx, err := authenticate(ss.Context(), authenticator, info.FullMethod, publicMethods)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authServerStream{ServerStream: ss, ctx: authCtx})
+	}
+}
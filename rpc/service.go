@@ -7,6 +7,15 @@ import (
 type HarukaRPCService struct {
 	service    interface{}
 	OnRegister func(rpcServer *grpc.Server)
+	// ServerOptions 额外的 grpc.ServerOption，在引擎默认选项之后应用
+	ServerOptions []grpc.ServerOption
+	// UnaryInterceptors 与 StreamInterceptors 在默认的恢复、日志、认证拦截器之后执行
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+	// Authenticator 认证拦截器使用的认证方式，为空时使用 HarukaAppEngine.AuthModule，两者都为空时不认证
+	Authenticator TokenAuthenticator
+	// PublicMethods 不需要认证的完整方法名，如 /package.Service/Method
+	PublicMethods []string
 }
 
 func NewHarukaRPCService(service interface{}, OnRegister func(rpcServer *grpc.Server)) *HarukaRPCService {
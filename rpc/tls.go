@@ -0,0 +1,42 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// TLSConfig rpc.tls 配置，设置 CA 时要求客户端证书（mTLS）
+type TLSConfig struct {
+	Cert string
+	Key  string
+	CA   string
+}
+
+// ServerCredentials 按配置加载服务端证书
+func (c *TLSConfig) ServerCredentials() (credentials.TransportCredentials, error) {
+	certificate, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+	if err != nil {
+		return nil, fmt.Errorf("load rpc certificate failed: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if c.CA != "" {
+		raw, err := os.ReadFile(c.CA)
+		if err != nil {
+			return nil, fmt.Errorf("read rpc client ca failed: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(raw) {
+			return nil, fmt.Errorf("no certificate found in %s", c.CA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
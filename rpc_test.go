@@ -0,0 +1,113 @@
+package harukap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/allentom/harukap/commons"
+	"github.com/allentom/harukap/config"
+	"github.com/allentom/harukap/plugins/youlog"
+	"github.com/allentom/harukap/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type tokenAuthenticator struct{}
+
+func (tokenAuthenticator) ParseToken(token string) (commons.AuthUser, error) {
+	if token != "good" {
+		return nil, errors.New("bad token")
+	}
+	return "bob", nil
+}
+
+// pingServiceDesc 只有一个一元方法的测试服务
+var pingServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Ping",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Ping",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &emptypb.Empty{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return &emptypb.Empty{}, nil
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/test.Ping/Ping"}, handler)
+			},
+		},
+	},
+}
+
+func newRPCTestConn(t *testing.T, engine *HarukaAppEngine) *grpc.ClientConn {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("rpc:\n  addr: :0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := config.NewProvider(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.ConfigProvider = provider
+	engine.LoggerPlugin = &youlog.Plugin{}
+	if err = engine.LoggerPlugin.OnInit(provider); err != nil {
+		t.Fatal(err)
+	}
+	options, err := engine.rpcServerOptions(engine.LoggerPlugin.Logger.NewScope("rpc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(options...)
+	server.RegisterService(&pingServiceDesc, struct{}{})
+	lis := bufconn.Listen(1024 * 1024)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return conn
+}
+
+func TestRPCUsesEngineAuthModule(t *testing.T) {
+	engine := NewHarukaAppEngine()
+	engine.RPCService = rpc.NewHarukaRPCService(nil, nil)
+	engine.AuthModule = tokenAuthenticator{}
+	conn := newRPCTestConn(t, engine)
+
+	err := conn.Invoke(context.Background(), "/test.Ping/Ping", &emptypb.Empty{}, &emptypb.Empty{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unauthenticated without token, got %v", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer bad")
+	if err = conn.Invoke(ctx, "/test.Ping/Ping", &emptypb.Empty{}, &emptypb.Empty{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unauthenticated with bad token, got %v", err)
+	}
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer good")
+	if err = conn.Invoke(ctx, "/test.Ping/Ping", &emptypb.Empty{}, &emptypb.Empty{}); err != nil {
+		t.Fatalf("expected call with valid token to pass, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package harukap
+
+import (
+	"errors"
+	"io"
+
+	"github.com/allentom/harukap/config"
+	"github.com/allentom/harukap/plugins/youlog"
+)
+
+// engineConfigSchemas 引擎自身读取的配置项
+func engineConfigSchemas() []*config.Schema {
+	return []*config.Schema{
+		{
+			Description: "http service",
+			Fields: []config.Field{
+				{Key: "addr", Type: config.FieldTypeString, Description: "http listen address, e.g. :8000"},
+			},
+		},
+		{
+			Prefix:      "rpc",
+			Description: "grpc service",
+			Fields: []config.Field{
+				{Key: "addr", Type: config.FieldTypeString, Description: "grpc listen address"},
+				{Key: "reflection", Type: config.FieldTypeBool, Default: true, Description: "register grpc reflection service"},
+				{Key: "tls.enable", Type: config.FieldTypeBool, Description: "serve grpc over tls"},
+				{Key: "tls.cert", Type: config.FieldTypeString, Description: "server certificate file"},
+				{Key: "tls.key", Type: config.FieldTypeString, Description: "server private key file", Secret: true},
+				{Key: "tls.ca", Type: config.FieldTypeString, Description: "client ca file, enables mtls when set"},
+			},
+			Validate: func(provider *config.Provider) config.ValidationErrors {
+				if !provider.Config().GetBool("rpc.tls.enable") {
+					return nil
+				}
+				return provider.RequireKeys("rpc.tls.cert", "rpc.tls.key")
+			},
+		},
+		{
+			Prefix:      "service",
+			Description: "system service",
+			Fields: []config.Field{
+				{Key: "name", Type: config.FieldTypeString, Description: "system service name"},
+				{Key: "display", Type: config.FieldTypeString, Description: "system service display name"},
+			},
+		},
+		{
+			Prefix:      "shutdown",
+			Description: "graceful shutdown",
+			Fields: []config.Field{
+				{Key: "timeout", Type: config.FieldTypeInt, Description: "drain timeout in milliseconds", Default: int(DefaultShutdownTimeout.Milliseconds())},
+			},
+		},
+		{
+			Prefix:      "health",
+			Description: "health endpoints",
+			Fields: []config.Field{
+				{Key: "enable", Type: config.FieldTypeBool, Description: "mount /livez, /healthz and /readyz", Default: true},
+				{Key: "timeout", Type: config.FieldTypeInt, Description: "health check timeout in milliseconds", Default: int(DefaultHealthCheckTimeout.Milliseconds())},
+				{Key: "interval", Type: config.FieldTypeInt, Description: "health monitor interval in milliseconds", Default: int(DefaultHealthCheckInterval.Milliseconds())},
+			},
+		},
+		{
+			Prefix:      "config",
+			Description: "config file",
+			Fields: []config.Field{
+				{Key: "watch", Type: config.FieldTypeBool, Description: "reload config file on change", Default: true},
+			},
+		},
+		youlog.ConfigSchema,
+	}
+}
+
+// RegisterConfigSchema 注册应用自身的配置声明，与插件声明一起在启动前校验
+func (e *HarukaAppEngine) RegisterConfigSchema(schemas ...*config.Schema) {
+	e.configSchemas = append(e.configSchemas, schemas...)
+}
+
+// ConfigSchemas 返回引擎、应用与插件声明的所有配置
+func (e *HarukaAppEngine) ConfigSchemas() []*config.Schema {
+	schemas := engineConfigSchemas()
+	schemas = append(schemas, e.configSchemas...)
+	for _, plugin := range e.Plugins {
+		if sp, ok := plugin.(PluginWithConfigSchema); ok {
+			schemas = append(schemas, sp.ConfigSchema())
+		}
+	}
+	return schemas
+}
+
+// ValidateConfig 应用声明中的默认值并校验配置，一次返回所有问题。
+// 声明前缀下未声明的键只输出警告，避免带有旧配置项的配置文件在升级后无法启动
+func (e *HarukaAppEngine) ValidateConfig() error {
+	schemas := e.ConfigSchemas()
+	e.ConfigProvider.SetDefaultSchemas(schemas...)
+	if err := e.ConfigProvider.ValidateSchemas(schemas...); err != nil {
+		return err
+	}
+	for _, unknown := range e.ConfigProvider.UnknownKeys(schemas...) {
+		config.ConfigLogger.Warn(unknown.Error())
+	}
+	return nil
+}
+
+// ValidateConfigStrict 与 ValidateConfig 相同，但未声明的键也视为错误，供 config validate 命令使用
+func (e *HarukaAppEngine) ValidateConfigStrict() error {
+	schemas := e.ConfigSchemas()
+	e.ConfigProvider.SetDefaultSchemas(schemas...)
+	errs := make(config.ValidationErrors, 0)
+	if err := e.ConfigProvider.ValidateSchemas(schemas...); err != nil {
+		var validationErrs config.ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			return err
+		}
+		errs = append(errs, validationErrs...)
+	}
+	errs = append(errs, e.ConfigProvider.UnknownKeys(schemas...)...)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// WriteConfigReference 输出所有已声明配置项的参考表
+func (e *HarukaAppEngine) WriteConfigReference(w io.Writer) error {
+	return config.WriteReference(w, e.ConfigSchemas()...)
+}
+
+// DumpConfig 返回生效的配置（包含声明的默认值）与插件配置快照，敏感值已打码
+func (e *HarukaAppEngine) DumpConfig() map[string]interface{} {
+	schemas := e.ConfigSchemas()
+	e.ConfigProvider.SetDefaultSchemas(schemas...)
+	plugins := map[string]interface{}{}
+	for name, cfg := range e.GetPluginsConfig() {
+		plugins[name] = config.MaskSecrets(cfg, schemas...)
+	}
+	return map[string]interface{}{
+		"config":  e.ConfigProvider.MaskedSettings(schemas...),
+		"plugins": plugins,
+	}
+}
+
+// WriteConfigExample 按已注册插件的配置声明输出带注释的示例配置
+func (e *HarukaAppEngine) WriteConfigExample(w io.Writer) error {
+	return config.WriteExample(w, e.ConfigSchemas()...)
+}